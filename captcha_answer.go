@@ -6,21 +6,82 @@ import (
 	captchatoolsgo "github.com/Matthew17-21/Captcha-Tools/captchatools-go"
 )
 
-// captchaTokenValidity defines the duration for which a captcha token remains valid.
+// captchaTokenValidity defines the default duration for which a captcha token remains valid.
 // Once this duration has elapsed since solving, the token is considered expired.
 const captchaTokenValidity = 2 * time.Minute
 
+// Typical validity windows of the tokens returned for each captcha type. They can be
+// returned from a ValidityFunc for the harvesters solving that type of captcha.
+const (
+	RecaptchaV2Validity = 2 * time.Minute
+	RecaptchaV3Validity = 2 * time.Minute
+	HCaptchaValidity    = 2 * time.Minute
+	TurnstileValidity   = 5 * time.Minute
+)
+
 // CaptchaAnswer extends captchatools.CaptchaAnswer by adding metadata about when the captcha was solved.
 type CaptchaAnswer struct {
 	captchatoolsgo.CaptchaAnswer
-	solvedAt time.Time // Timestamp indicating when the captcha was solved
+	solvedAt    time.Time // Timestamp indicating when the captcha was solved
+	expiresAt   time.Time // Timestamp after which the token is no longer accepted
+	fingerprint string    // Fingerprint of the AdditionalData the captcha was solved with
+
+	harvester captchatoolsgo.Harvester // Harvester that solved the captcha
+	provider  string                   // Type of the harvester, for captchas solved elsewhere
+}
+
+// NewRemoteAnswer creates a CaptchaAnswer for a captcha solved elsewhere, such as a token
+// handed out by a token server, by the given type of harvester. It expires at expiresAt,
+// or captchaTokenValidity from now if expiresAt is zero.
+func NewRemoteAnswer(answer captchatoolsgo.CaptchaAnswer, provider string, expiresAt time.Time) *CaptchaAnswer {
+	return &CaptchaAnswer{
+		CaptchaAnswer: answer,
+		solvedAt:      time.Now(),
+		expiresAt:     expiresAt,
+		provider:      provider,
+	}
+}
+
+// Provider returns the type of the harvester that solved the captcha. It is empty if the
+// token wasn't harvested by a CaptchaSolve or created with NewRemoteAnswer.
+func (c CaptchaAnswer) Provider() string {
+	if c.harvester == nil {
+		return c.provider
+	}
+	return providerName(c.harvester)
 }
 
-// IsExpired checks whether the captcha token has expired based on its solve time.
-// It compares the current time with the solve time plus the allowed validity duration.
+// Fingerprint returns the Fingerprint of the AdditionalData the captcha was solved with.
+// It is empty if it was solved without AdditionalData.
+func (c CaptchaAnswer) Fingerprint() string {
+	return c.fingerprint
+}
+
+// ExpiresAt returns the time after which the token is no longer valid. Tokens without
+// a validity window of their own expire captchaTokenValidity after being solved.
+func (c CaptchaAnswer) ExpiresAt() time.Time {
+	if c.expiresAt.IsZero() {
+		return c.solvedAt.Add(captchaTokenValidity)
+	}
+	return c.expiresAt
+}
+
+// TimeLeft returns how long the token remains valid for. It is negative once the token
+// has expired.
+func (c CaptchaAnswer) TimeLeft() time.Duration {
+	return time.Until(c.ExpiresAt())
+}
+
+// IsExpired checks whether the captcha token has expired.
+// It compares the current time with the token's expiry time.
 func (c CaptchaAnswer) IsExpired() bool {
-	expirationTime := c.solvedAt.Add(captchaTokenValidity)
-	return time.Now().After(expirationTime)
+	return time.Now().After(c.ExpiresAt())
+}
+
+// withValidity sets the token to expire d after it was solved.
+func (c *CaptchaAnswer) withValidity(d time.Duration) *CaptchaAnswer {
+	c.expiresAt = c.solvedAt.Add(d)
+	return c
 }
 
 // toCaptchaAnswer converts captchatoolsgo.CaptchaAnswer to CaptchaAnswer
@@ -38,7 +99,7 @@ func newCaptchaAnswer(c *captchatoolsgo.CaptchaAnswer, solvedAt time.Time) *Capt
 		return &CaptchaAnswer{}
 	}
 	return &CaptchaAnswer{
-		*c,
-		solvedAt,
+		CaptchaAnswer: *c,
+		solvedAt:      solvedAt,
 	}
 }
@@ -96,3 +96,69 @@ func TestNewCaptchaAnswerWithNilInput(t *testing.T) {
 	require.Empty(t, result.Token)
 	require.True(t, result.solvedAt.IsZero())
 }
+
+func TestNewRemoteAnswer(t *testing.T) {
+	t.Run("keeps the provider and expiry", func(t *testing.T) {
+		expiresAt := time.Now().Add(time.Minute)
+
+		result := NewRemoteAnswer(captchatoolsgo.CaptchaAnswer{Token: "token"}, "*captchatools.Capmonster", expiresAt)
+
+		require.Equal(t, "token", result.Token)
+		require.Equal(t, "*captchatools.Capmonster", result.Provider())
+		require.Equal(t, expiresAt, result.ExpiresAt())
+		require.False(t, result.IsExpired())
+	})
+
+	t.Run("defaults to the token validity", func(t *testing.T) {
+		result := NewRemoteAnswer(captchatoolsgo.CaptchaAnswer{Token: "token"}, "", time.Time{})
+
+		require.WithinDuration(t, time.Now().Add(captchaTokenValidity), result.ExpiresAt(), time.Second)
+	})
+}
+
+func TestExpiresAt(t *testing.T) {
+	solvedAt := time.Now()
+
+	t.Run("defaults to the token validity", func(t *testing.T) {
+		ca := CaptchaAnswer{solvedAt: solvedAt}
+		require.Equal(t, solvedAt.Add(captchaTokenValidity), ca.ExpiresAt())
+	})
+
+	t.Run("uses the token's own validity window", func(t *testing.T) {
+		ca := (&CaptchaAnswer{solvedAt: solvedAt}).withValidity(TurnstileValidity)
+		require.Equal(t, solvedAt.Add(TurnstileValidity), ca.ExpiresAt())
+		require.False(t, ca.IsExpired())
+	})
+
+	t.Run("short validity window expires", func(t *testing.T) {
+		ca := (&CaptchaAnswer{solvedAt: solvedAt.Add(-time.Minute)}).withValidity(30 * time.Second)
+		require.True(t, ca.IsExpired())
+	})
+}
+
+func TestTimeLeft(t *testing.T) {
+	tests := []struct {
+		Id       string
+		SolvedAt time.Time
+		Validity time.Duration
+		Min, Max time.Duration
+	}{
+		{Id: "fresh", SolvedAt: time.Now(), Validity: time.Minute, Min: 59 * time.Second, Max: time.Minute},
+		{Id: "half used", SolvedAt: time.Now().Add(-30 * time.Second), Validity: time.Minute, Min: 29 * time.Second, Max: 30 * time.Second},
+		{Id: "expired", SolvedAt: time.Now().Add(-2 * time.Minute), Validity: time.Minute, Min: -61 * time.Second, Max: -time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Id, func(t *testing.T) {
+			// Create captcha answer
+			ca := (&CaptchaAnswer{solvedAt: tt.SolvedAt}).withValidity(tt.Validity)
+
+			// Run function
+			result := ca.TimeLeft()
+
+			// Assert
+			require.GreaterOrEqual(t, result, tt.Min)
+			require.LessOrEqual(t, result, tt.Max)
+		})
+	}
+}
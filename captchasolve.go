@@ -2,9 +2,9 @@ package captchasolve
 
 import (
 	"context"
+	"sync"
 
 	captchatoolsgo "github.com/Matthew17-21/Captcha-Tools/captchatools-go"
-	"github.com/Matthew17-21/CaptchaSolve/internal/queue"
 )
 
 type CaptchaSolve interface {
@@ -16,23 +16,115 @@ type CaptchaSolve interface {
 	// Additional data can be provided if required by the harvesting service.
 	//
 	// Returns a valid CaptchaAnswer and nil error if successful, or nil and an error
-	// if token retrieval fails or is cancelled.
+	// if token retrieval fails or is cancelled. Errors can be inspected with errors.Is
+	// and errors.As, see ErrNoHarvesters, ErrPoolClosed, *RoundError and *HarvesterError.
 	GetToken(context.Context, ...*captchatoolsgo.AdditionalData) (*CaptchaAnswer, error)
 
 	// ClearTokens removes all pre-harvested tokens from the internal queue.
 	// This is useful when you want to ensure fresh tokens are retrieved on
 	// subsequent GetToken calls or when you need to clear potentially stale tokens.
 	ClearTokens() // Clears all pre-harvested tokens
+
+	// Start launches the background workers, such as the prefill worker configured with
+	// WithPrefill. The workers run until the context is cancelled or the solver is shut down.
+	Start(context.Context) error
+
+	// Close shuts the solver down immediately, cancelling in-flight solves. It returns once
+	// every goroutine started by the solver has exited.
+	Close() error
+
+	// Shutdown gracefully shuts the solver down according to its ShutdownPolicy. It returns
+	// once every goroutine started by the solver has exited, or the context's error if the
+	// context is done first.
+	Shutdown(context.Context) error
+
+	// HarvesterStatus returns the health of every configured harvester. Harvesters whose
+	// API key is out of funds, invalid or banned are quarantined until a balance check,
+	// run by the worker launched with Start, shows they can be used again.
+	HarvesterStatus() []HarvesterStatus
+
+	// Stats returns the success and failure counts and the solve latency of every
+	// configured harvester, for dashboards and selection strategies.
+	Stats() []HarvesterStats
+
+	// PoolStatus returns the number of queued tokens, waiting callers and in-flight solves.
+	PoolStatus() PoolStatus
+
+	// ReportBadToken reports a token returned by GetToken that the site rejected. It is
+	// counted as a failure of the harvester that solved it.
+	ReportBadToken(*CaptchaAnswer) error
+
+	// Update swaps the harvesters, pool limits and other settings of the solver for those
+	// described by the options, keeping queued tokens and letting in-flight solves finish.
+	Update(...ClientOption) error
 }
 
 type captchasolve struct {
 	config
+
+	// queue holds the tokens solved without AdditionalData, and keyed those solved with
+	// AdditionalData, by fingerprint. Keyed queues are removed once empty. Guarded by mu.
 	queue tokenQueue
+	keyed map[string]tokenQueue
+
+	// tokenLog records the changes made to the queues when a token file is set.
+	tokenLog *tokenLog
+
+	// mu guards the hand-off between the queue and the waiters so that a harvested
+	// token is either given to a waiting caller or enqueued, never both.
+	mu sync.Mutex
+
+	// waiters holds the callers blocked in GetToken, oldest first.
+	waiters []*waiter
+
+	// inFlight is the number of solves that have been started but not finished yet, and
+	// keyedInFlight the number of those solving with AdditionalData, by fingerprint.
+	inFlight      int
+	keyedInFlight map[string]int
+
+	// solvesSpent is the number of solves taken from the solve budget.
+	solvesSpent int
+
+	// health holds the health record of the harvesters, by index. Guarded by mu.
+	health map[int]*harvesterHealth
+
+	// sem bounds the number of concurrently running solves to maxGoroutines. It is
+	// created on first use and replaced when Update changes the limit. Guarded by mu.
+	sem chan struct{}
+
+	// refill wakes up the prefill worker when a token is taken from the queue.
+	refill chan struct{}
+
+	// ctx bounds the lifetime of every goroutine started by the instance and is
+	// cancelled on shutdown.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// closed is set once the instance has been shut down. Guarded by mu.
+	closed bool
+
+	// stopWorkers stops the background workers launched by Start, which run until
+	// workersCtx is done. prefilling is set once the prefill worker is running, which
+	// Update may do after Start. Guarded by mu.
+	stopWorkers context.CancelFunc
+	workersCtx  context.Context
+	prefilling  bool
+
+	// workers and solves track the background workers and running solves so shutdown
+	// can wait for them to exit.
+	workers sync.WaitGroup
+	solves  sync.WaitGroup
 }
 
 // New initializes a CaptchaSolve with default configuration and then applies any provided
-// option functions to customize the configuration. It creates an empty token queue and
-// returns the fully initialized instance ready for use.
+// option functions to customize the configuration. It creates an empty token queue bounded
+// to the configured max capacity and returns the fully initialized instance ready for use.
+//
+// New doesn't validate the configuration; use NewE to have configuration problems, such
+// as a missing harvester, reported up front.
+//
+// Background workers, such as the prefill worker, are only launched by Start. Close or
+// Shutdown must be called to release the goroutines started by the instance.
 //
 // Example:
 //
@@ -40,7 +132,38 @@ type captchasolve struct {
 //	    WithMaxGoroutines(5),
 //	    WithLogger(customLogger),
 //	)
+//	defer solver.Close()
 func New(opts ...ClientOption) CaptchaSolve {
+	c, err := newCaptchaSolve(newConfig(opts...))
+	if err != nil {
+		WithFields(c.logger, "error", err).Error("Queued tokens won't be kept")
+	}
+	return c
+}
+
+// NewE is like New, but returns an error describing every problem with the configuration
+// instead of a CaptchaSolve that can't harvest tokens.
+//
+// Example:
+//
+//	solver, err := NewE(WithHarvester(harvester))
+//	if err != nil {
+//	    // errors.Is(err, ErrNoHarvesters) if no harvester was given
+//	}
+func NewE(opts ...ClientOption) (CaptchaSolve, error) {
+	cfg := newConfig(opts...)
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	c, err := newCaptchaSolve(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// newConfig creates the default config and applies the options to it.
+func newConfig(opts ...ClientOption) config {
 	// Create default config
 	cfg := defaultConfig()
 
@@ -49,49 +172,110 @@ func New(opts ...ClientOption) CaptchaSolve {
 		optFunc(&cfg)
 	}
 
-	// Return the instance
-	return &captchasolve{
-		queue:  queue.NewSliceQueue[*CaptchaAnswer](),
+	// Keep the tokens of the token file across restarts unless told otherwise
+	if cfg.tokenFile != "" && !cfg.shutdownPolicySet {
+		cfg.shutdownPolicy = ShutdownDrain
+	}
+
+	// The prefill target can't exceed what the queue can hold
+	if cfg.maxCapacity > 0 && cfg.prefillMax > cfg.maxCapacity {
+		cfg.logger.Warn("Prefill target %d exceeds max capacity, using %d", cfg.prefillMax, cfg.maxCapacity)
+		cfg.prefillMax = cfg.maxCapacity
+		cfg.prefillMin = min(cfg.prefillMin, cfg.maxCapacity)
+	}
+	return cfg
+}
+
+// newCaptchaSolve creates an instance using the given config. If the token file can't be
+// opened, the instance is returned with an error and its tokens aren't persisted.
+func newCaptchaSolve(cfg config) (*captchasolve, error) {
+	c := &captchasolve{
 		config: cfg,
+		refill: make(chan struct{}, 1),
 	}
+	c.queue = c.newQueue()
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+
+	// Reload the tokens queued by the previous instance
+	if c.tokenFile != "" {
+		if err := c.openTokenFile(); err != nil {
+			return c, err
+		}
+	}
+
+	// Return the instance
+	return c, nil
 }
 
 // GetToken retrieves a valid captcha token, either from the pre-harvested queue or by
 // starting new harvesters if needed.
 //
 // The function first attempts to get a pre-harvested token from the queue. If none are
-// available, it starts background harvesters to generate new tokens. It continuously
-// checks the queue for new tokens until either:
-//   - A valid token is found
+// available, the caller is registered as a waiter, background solves are started for any
+// waiters not already covered by an in-flight solve and the call blocks until either:
+//   - A harvested token is handed to it
+//   - A solve fails and no other in-flight solve is left to cover the caller, in which
+//     case a *RoundError matching ErrAllHarvestersFailed is returned
 //   - The context is cancelled
+//   - The solver is shut down, in which case ErrPoolClosed is returned
+//
+// If the queue is empty and no harvesters are configured, ErrNoHarvesters is returned
+// right away.
+//
+// Waiters are served in FIFO order: every harvested token goes to the oldest waiting
+// caller, and only lands in the queue when nobody is waiting.
 //
-// The function is non-blocking on harvester initialization, allowing multiple concurrent
-// calls to GetToken. Harvesters run in the background and add tokens to the queue as
-// they become available.
-func (c *captchasolve) GetToken(ctx context.Context, additional ...*captchatoolsgo.AdditionalData) (*CaptchaAnswer, error) {
-	// Attempt to get a token from queue
-	token, err := c.getValidTokenFromQueue()
+// Tokens are only handed to callers whose AdditionalData has the same Fingerprint as the
+// AdditionalData the token was solved with.
+func (c *captchasolve) GetToken(ctx context.Context, additional ...*captchatoolsgo.AdditionalData) (token *CaptchaAnswer, err error) {
+	ctx, span := c.startSpan(ctx, "captchasolve.GetToken")
+	defer func() {
+		if token != nil {
+			span.SetAttributes(attrTokenID.Int(token.Id()), tokenAge(token))
+		}
+		endSpan(span, err)
+	}()
+
+	// Attempt to get a token from queue, registering as a waiter on a miss.
+	// Both happen under the lock so a token can't slip into the queue in between.
+	fingerprint := Fingerprint(additional...)
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil, ErrPoolClosed
+	}
+	token, err = c.getValidTokenFromQueue(fingerprint)
 	if err == nil {
+		c.reportPoolLocked()
+		c.mu.Unlock()
+		span.SetAttributes(attrFromQueue.Bool(true))
+		c.reportServed()
+		c.signalRefill()
 		return token, nil
 	}
+	if len(c.harvesters) == 0 {
+		c.mu.Unlock()
+		return nil, ErrNoHarvesters
+	}
+	w := newWaiter(fingerprint)
+	c.addWaiter(w)
+	c.reportPoolLocked()
+	c.mu.Unlock()
+	span.SetAttributes(attrFromQueue.Bool(false))
 
-	// Start captcha harvesters
-	go c.startHarvesters(ctx, additional...)
-
-	// While ctx not cancelled, return first token from queue
-	for {
-		// Make sure ctx is not cancelled
-		select {
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		default: // So it doesn;t block
-		}
+	// Start as many solves as needed to cover the waiters
+	c.dispatch(ctx, additional...)
 
-		// Return the first token from queue
-		token, err := c.getValidTokenFromQueue()
-		if err == nil {
-			return token, nil
+	// Wait until a token is handed to us or ctx is cancelled
+	select {
+	case res := <-w.ch:
+		if res.err == nil {
+			c.reportServed()
 		}
+		return res.token, res.err
+	case <-ctx.Done():
+		c.cancelWaiter(w)
+		return nil, ctx.Err()
 	}
 }
 
@@ -12,6 +12,37 @@ import (
 	"github.com/test-go/testify/mock"
 )
 
+func TestNewE(t *testing.T) {
+	t.Run("creates instance from valid config", func(t *testing.T) {
+		// Act
+		solver, err := NewE(WithHarvester(&fakeHarvester{}))
+
+		// Assert
+		require.NoError(t, err)
+		require.NotNil(t, solver)
+	})
+
+	t.Run("reports missing harvesters", func(t *testing.T) {
+		// Act
+		solver, err := NewE()
+
+		// Assert
+		require.ErrorIs(t, err, ErrNoHarvesters)
+		require.Nil(t, solver)
+	})
+
+	t.Run("reports every problem", func(t *testing.T) {
+		// Act
+		_, err := NewE(WithHarvester(&fakeHarvester{}), WithHarvester(nil), WithStrategy(nil), WithLogger(nil))
+
+		// Assert
+		require.ErrorContains(t, err, "harvester #2 is nil")
+		require.ErrorContains(t, err, "strategy is nil")
+		require.ErrorContains(t, err, "logger is nil")
+		require.NotErrorIs(t, err, ErrNoHarvesters)
+	})
+}
+
 func TestNew(t *testing.T) {
 	t.Run("creates default instance", func(t *testing.T) {
 		// Act
@@ -120,17 +151,17 @@ func TestGetToken(t *testing.T) {
 		// Arrange
 		mockQueue := new(mockQueue)
 		mockQueue.On("Dequeue").Return(nil, errors.New("queue empty"))
+		mockQueue.On("Clear").Return()
 
-		solver := &captchasolve{
-			queue: mockQueue,
-		}
-		solver.logger = NewSilentLogger()
+		solver := New(WithHarvester(&fakeHarvester{delay: time.Hour})).(*captchasolve)
+		solver.queue = mockQueue
 
 		ctx, cancel := context.WithCancel(context.Background())
 		cancel() // Cancel immediately
 
 		// Act
 		token, err := solver.GetToken(ctx)
+		solver.Close()
 
 		// Assert
 		assert.Error(t, err)
@@ -139,7 +170,7 @@ func TestGetToken(t *testing.T) {
 		mockQueue.AssertExpectations(t)
 	})
 
-	t.Run("retries queue until valid token is available", func(t *testing.T) {
+	t.Run("waits for a token handed off after a queue miss", func(t *testing.T) {
 		// Arrange
 		mockQueue := new(mockQueue)
 		expectedToken := &CaptchaAnswer{
@@ -147,17 +178,21 @@ func TestGetToken(t *testing.T) {
 			solvedAt:      time.Now(),
 		}
 
-		// First call returns empty, second call returns valid token
+		// Queue is only checked once, the token is handed off directly afterwards
 		mockQueue.On("Dequeue").Return(nil, errors.New("queue empty")).Once()
-		mockQueue.On("Dequeue").Return(expectedToken, nil).Once()
+		mockQueue.On("Clear").Return()
 
-		solver := &captchasolve{
-			queue: mockQueue,
-		}
-		solver.logger = NewSilentLogger()
+		solver := New(WithHarvester(&fakeHarvester{delay: time.Hour})).(*captchasolve)
+		solver.queue = mockQueue
+
+		go func() {
+			waitForWaiters(t, solver, 1)
+			solver.deliver(expectedToken)
+		}()
 
 		// Act
 		token, err := solver.GetToken(context.Background())
+		solver.Close()
 
 		// Assert
 		assert.NoError(t, err)
@@ -168,34 +203,129 @@ func TestGetToken(t *testing.T) {
 	t.Run("starts harvesters when queue is empty", func(t *testing.T) {
 		// Arrange
 		mockQueue := new(mockQueue)
-		mockHarvester := new(MockHarvester)
-		expectedToken := &CaptchaAnswer{
-			CaptchaAnswer: captchatoolsgo.CaptchaAnswer{Token: "harvested-token"},
-			solvedAt:      time.Now(),
-		}
+		mockHarvester := new(mockHarvester)
 
-		// Queue is initially empty, then gets token from harvester
+		// Queue is initially empty, then the harvested token is handed off directly
 		mockQueue.On("Dequeue").Return(nil, errors.New("queue empty")).Once()
-		mockQueue.On("Dequeue").Return(expectedToken, nil).Once()
 
 		mockHarvester.On("GetTokenWithContext", mock.Anything, mock.Anything).Return(
 			&captchatoolsgo.CaptchaAnswer{Token: "harvested-token"},
 			nil,
 		)
 
-		solver := &captchasolve{
-			queue: mockQueue,
-		}
-		solver.harvesters = make([]captchatoolsgo.Harvester, 0)
-		solver.logger = NewSilentLogger()
+		mockQueue.On("Clear").Return()
+
+		solver := New(WithHarvester(mockHarvester), WithMaxGoroutines(1)).(*captchasolve)
+		solver.queue = mockQueue
 
 		// Act
 		token, err := solver.GetToken(context.Background())
+		solver.Close()
 
 		// Assert
 		assert.NoError(t, err)
-		assert.Equal(t, expectedToken, token)
+		assert.Equal(t, "harvested-token", token.Token)
 		mockQueue.AssertExpectations(t)
+		mockHarvester.AssertExpectations(t)
+	})
+
+	t.Run("hands tokens to waiters in FIFO order", func(t *testing.T) {
+		// Arrange
+		solver := New(WithHarvester(&fakeHarvester{delay: time.Hour})).(*captchasolve)
+		defer solver.Close()
+		const numWaiters = 5
+
+		// Register waiters one at a time so their order is known
+		results := make([]chan *CaptchaAnswer, numWaiters)
+		for i := 0; i < numWaiters; i++ {
+			results[i] = make(chan *CaptchaAnswer, 1)
+			go func(out chan<- *CaptchaAnswer) {
+				token, err := solver.GetToken(context.Background())
+				assert.NoError(t, err)
+				out <- token
+			}(results[i])
+			waitForWaiters(t, solver, i+1)
+		}
+
+		// Act
+		for i := 0; i < numWaiters; i++ {
+			solver.deliver(&CaptchaAnswer{CaptchaAnswer: captchatoolsgo.CaptchaAnswer{Token: string(rune('a' + i))}})
+		}
+
+		// Assert
+		for i := 0; i < numWaiters; i++ {
+			token := <-results[i]
+			assert.Equal(t, string(rune('a'+i)), token.Token)
+		}
+		require.Zero(t, solver.queue.Len())
+	})
+
+	t.Run("token handed to a cancelled waiter is passed on", func(t *testing.T) {
+		// Arrange
+		solver := New().(*captchasolve)
+		expectedToken := &CaptchaAnswer{CaptchaAnswer: captchatoolsgo.CaptchaAnswer{Token: "valid-token"}, solvedAt: time.Now()}
+
+		// Simulate a token handed to a waiter right as its caller gives up
+		w := newWaiter("")
+		solver.addWaiter(w)
+		solver.deliver(expectedToken)
+
+		// Act
+		solver.cancelWaiter(w)
+
+		// Assert
+		token, err := solver.queue.Dequeue()
+		require.NoError(t, err)
+		require.Equal(t, expectedToken, token)
+	})
+
+	t.Run("fails fast without harvesters", func(t *testing.T) {
+		// Arrange
+		solver := New().(*captchasolve)
+
+		// Act
+		token, err := solver.GetToken(context.Background())
+
+		// Assert
+		require.ErrorIs(t, err, ErrNoHarvesters)
+		require.Nil(t, token)
+		require.Empty(t, solver.waiters)
+	})
+
+	t.Run("surfaces failed solves to the waiting caller", func(t *testing.T) {
+		// Arrange
+		providerErr := errors.New("ERROR_CAPTCHA_UNSOLVABLE")
+		solver := New(WithHarvester(&fakeHarvester{err: providerErr})).(*captchasolve)
+		defer solver.Close()
+
+		// Act
+		token, err := solver.GetToken(context.Background())
+
+		// Assert
+		require.Nil(t, token)
+		require.ErrorIs(t, err, ErrAllHarvestersFailed)
+		require.ErrorIs(t, err, providerErr)
+		var harvesterErr *HarvesterError
+		require.ErrorAs(t, err, &harvesterErr)
+		require.Equal(t, 0, harvesterErr.Index)
+	})
+
+	t.Run("failed solve only fails uncovered waiters", func(t *testing.T) {
+		// Arrange
+		solver := New().(*captchasolve)
+		oldest, newest := newWaiter(""), newWaiter("")
+		solver.addWaiter(oldest)
+		solver.addWaiter(newest)
+		solver.inFlight = 1
+
+		// Act
+		solver.failUncoveredWaiterLocked("", ErrBudgetExceeded)
+		solver.failUncoveredWaiterLocked("", ErrBudgetExceeded)
+
+		// Assert
+		res := <-newest.ch
+		require.ErrorIs(t, res.err, ErrBudgetExceeded)
+		require.Equal(t, []*waiter{oldest}, solver.waiters, "oldest waiter is still covered by the in-flight solve")
 	})
 
 	t.Run("handles nil additional data", func(t *testing.T) {
@@ -221,3 +351,13 @@ func TestGetToken(t *testing.T) {
 		mockQueue.AssertExpectations(t)
 	})
 }
+
+// waitForWaiters blocks until the solver has at least n callers waiting for a token.
+func waitForWaiters(t *testing.T, c *captchasolve, n int) {
+	t.Helper()
+	require.Eventually(t, func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return len(c.waiters) >= n
+	}, time.Second, time.Millisecond)
+}
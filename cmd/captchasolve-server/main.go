@@ -0,0 +1,154 @@
+// Command captchasolve-server serves captcha tokens over HTTP, and optionally gRPC, for
+// the sites of a configuration file, so programs that can't link the library can request
+// them from a local service. See the server and grpcserver packages for the endpoints.
+//
+// Usage:
+//
+//	CAPTCHASOLVE_API_KEYS=secret captchasolve-server -config captchasolve.yaml -addr 127.0.0.1:8080 -grpc-addr 127.0.0.1:9090
+//
+// CAPTCHASOLVE_API_KEYS holds the comma-separated API keys requests must carry. Every
+// request is accepted if it is empty. The configuration file is reloaded every -watch
+// interval, if set, without dropping the queued tokens.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Matthew17-21/CaptchaSolve"
+	"github.com/Matthew17-21/CaptchaSolve/grpcserver"
+	"github.com/Matthew17-21/CaptchaSolve/server"
+	"google.golang.org/grpc"
+)
+
+// shutdownTimeout bounds how long the server waits for requests and solves in progress
+// when stopped.
+const shutdownTimeout = 30 * time.Second
+
+// options holds the command-line flags.
+type options struct {
+	configPath string
+	addr       string
+	grpcAddr   string
+	watch      time.Duration
+	maxWait    time.Duration
+}
+
+func main() {
+	var opts options
+	flag.StringVar(&opts.configPath, "config", "captchasolve.yaml", "path of the configuration file (YAML, JSON or TOML)")
+	flag.StringVar(&opts.addr, "addr", "127.0.0.1:8080", "address to serve HTTP on")
+	flag.StringVar(&opts.grpcAddr, "grpc-addr", "", "address to serve gRPC on; gRPC isn't served if empty")
+	flag.DurationVar(&opts.watch, "watch", 0, "how often to reload the configuration file; 0 disables reloading")
+	flag.DurationVar(&opts.maxWait, "max-wait", 2*time.Minute, "longest time an HTTP token request may wait for a token")
+	flag.Parse()
+
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := run(ctx, logger, opts); err != nil {
+		logger.Error("Server failed", "error", err)
+		os.Exit(1)
+	}
+}
+
+// run serves the sites configured in the file at opts.configPath until ctx is done.
+func run(ctx context.Context, logger *slog.Logger, opts options) error {
+	registry, err := captchasolve.LoadConfig(opts.configPath)
+	if err != nil {
+		return err
+	}
+	shutdownRegistry := sync.OnceFunc(func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := registry.Shutdown(shutdownCtx); err != nil {
+			logger.Error("Failed to shut the solvers down", "error", err)
+		}
+	})
+	defer shutdownRegistry()
+	if err := registry.Start(ctx); err != nil {
+		return err
+	}
+	if opts.watch > 0 {
+		go registry.WatchConfig(ctx, opts.configPath, opts.watch)
+	}
+
+	apiKeys := strings.FieldsFunc(os.Getenv("CAPTCHASOLVE_API_KEYS"), func(r rune) bool { return r == ',' || r == ' ' })
+	if len(apiKeys) == 0 {
+		logger.Warn("CAPTCHASOLVE_API_KEYS is empty, every request is accepted")
+	}
+	solverLogger := captchasolve.NewSlogLogger(logger)
+
+	// Token requests long-poll, so only reading the headers is bounded
+	srv := &http.Server{
+		Addr: opts.addr,
+		Handler: server.New(registry,
+			server.WithAPIKeys(apiKeys...),
+			server.WithMaxWait(opts.maxWait),
+			server.WithLogger(solverLogger),
+		),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	errs := make(chan error, 2)
+	go func() { errs <- fmt.Errorf("serving HTTP on %s: %w", opts.addr, srv.ListenAndServe()) }()
+	logger.Info("Serving tokens over HTTP", "addr", opts.addr, "sites", registry.IDs())
+
+	var grpcServer *grpc.Server
+	if opts.grpcAddr != "" {
+		listener, err := net.Listen("tcp", opts.grpcAddr)
+		if err != nil {
+			srv.Close()
+			return fmt.Errorf("serving gRPC: %w", err)
+		}
+		grpcServer = grpc.NewServer()
+		grpcserver.New(registry, grpcserver.WithAPIKeys(apiKeys...), grpcserver.WithLogger(solverLogger)).Register(grpcServer)
+		go func() { errs <- fmt.Errorf("serving gRPC on %s: %w", opts.grpcAddr, grpcServer.Serve(listener)) }()
+		logger.Info("Serving tokens over gRPC", "addr", opts.grpcAddr)
+	}
+
+	select {
+	case err := <-errs:
+		if grpcServer != nil {
+			grpcServer.Stop()
+		}
+		srv.Close()
+		return err
+	case <-ctx.Done():
+	}
+	logger.Info("Shutting down")
+
+	// Shut the solvers down alongside the servers so waiting requests are answered rather
+	// than waited for
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		shutdownRegistry()
+	}()
+	if grpcServer != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			grpcServer.GracefulStop()
+		}()
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	err = srv.Shutdown(shutdownCtx)
+	wg.Wait()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
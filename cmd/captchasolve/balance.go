@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"text/tabwriter"
+)
+
+// balance prints the balance of every provider of the site, checking them concurrently.
+func balance(ctx context.Context, e *env, args []string) error {
+	var sf siteFlags
+	fs := newFlagSet(e, "balance", &sf)
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	harvesters, types, err := e.loadProviders(sf.config, sf.site)
+	if err != nil {
+		return err
+	}
+	balances := make([]float32, len(harvesters))
+	errs := make([]error, len(harvesters))
+	var wg sync.WaitGroup
+	for i, h := range harvesters {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			balances[i], errs[i] = h.GetBalance()
+		}()
+	}
+	wg.Wait()
+
+	w := tabwriter.NewWriter(e.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "#\tPROVIDER\tBALANCE")
+	failed := 0
+	for i := range harvesters {
+		if errs[i] != nil {
+			fmt.Fprintf(w, "%d\t%s\terror: %v\n", i+1, types[i], errs[i])
+			failed++
+			continue
+		}
+		fmt.Fprintf(w, "%d\t%s\t%.4f\n", i+1, types[i], balances[i])
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d balance checks failed", failed, len(harvesters))
+	}
+	return nil
+}
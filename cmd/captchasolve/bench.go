@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Matthew17-21/CaptchaSolve"
+	"github.com/Matthew17-21/CaptchaSolve/metrics"
+)
+
+// histogramWidth is the width of the longest bar of the latency histograms.
+const histogramWidth = 40
+
+// bench solves n captchas, at most concurrency at a time, and prints the latency of every
+// provider.
+func bench(ctx context.Context, e *env, args []string) error {
+	var sf siteFlags
+	fs := newFlagSet(e, "bench", &sf)
+	n := fs.Int("n", 10, "number of captchas to solve")
+	concurrency := fs.Int("concurrency", 1, "number of captchas to solve at once")
+	timeout := fs.Duration("timeout", defaultTimeout, "how long to wait for each token")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if *n < 1 || *concurrency < 1 {
+		return fmt.Errorf("-n and -concurrency must be at least 1, got %d and %d", *n, *concurrency)
+	}
+
+	// Surplus solves would serve later requests from the queue, hiding their latency. The
+	// solves are spread over every harvester, without hedging, so each gets benchmarked
+	// and none is called twice for a token.
+	recorder := &latencyRecorder{}
+	solver, site, err := e.loadSolver(sf.config, sf.site,
+		captchasolve.WithMetrics(recorder),
+		captchasolve.WithSurplus(0),
+		captchasolve.WithStrategy(captchasolve.RoundRobin()),
+		captchasolve.WithHedging(captchasolve.HedgePolicy{}),
+	)
+	if err != nil {
+		return err
+	}
+	defer solver.Close()
+
+	fmt.Fprintf(e.stderr, "Solving %d captchas for %s, %d at a time...\n", *n, site, *concurrency)
+	start := time.Now()
+	jobs := make(chan struct{})
+	var (
+		mu     sync.Mutex
+		failed = make(map[string]int) // Errors of GetToken, by message
+		wg     sync.WaitGroup
+	)
+	for i := 0; i < *concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range jobs {
+				solveCtx, cancel := context.WithTimeout(ctx, *timeout)
+				_, err := solver.GetToken(solveCtx)
+				cancel()
+				if err != nil {
+					mu.Lock()
+					failed[err.Error()]++
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for i := 0; i < *n && ctx.Err() == nil; i++ {
+		jobs <- struct{}{}
+	}
+	close(jobs)
+	wg.Wait()
+
+	recorder.print(e.stdout, time.Since(start))
+	messages := make([]string, 0, len(failed))
+	errs := 0
+	for msg, count := range failed {
+		messages = append(messages, msg)
+		errs += count
+	}
+	slices.Sort(messages)
+	if errs > 0 {
+		fmt.Fprintln(e.stdout)
+	}
+	for _, msg := range messages {
+		fmt.Fprintf(e.stdout, "%d× %s\n", failed[msg], msg)
+	}
+	if errs > 0 {
+		return fmt.Errorf("%d of %d solves failed", errs, *n)
+	}
+	return ctx.Err()
+}
+
+// latencyRecorder is a captchasolve.Metrics recording the latency and failures of every
+// harvester.
+type latencyRecorder struct {
+	mu         sync.Mutex
+	harvesters map[int]*harvesterLatency
+}
+
+// harvesterLatency holds what a latencyRecorder recorded about a harvester.
+type harvesterLatency struct {
+	provider  string
+	latencies []time.Duration
+	failures  map[captchasolve.ErrorClass]int
+}
+
+var _ captchasolve.Metrics = (*latencyRecorder)(nil)
+
+// harvester returns the record of the harvester at index. r.mu must be held.
+func (r *latencyRecorder) harvester(index int, provider string) *harvesterLatency {
+	if r.harvesters == nil {
+		r.harvesters = make(map[int]*harvesterLatency)
+	}
+	h, ok := r.harvesters[index]
+	if !ok {
+		h = &harvesterLatency{provider: provider, failures: make(map[captchasolve.ErrorClass]int)}
+		r.harvesters[index] = h
+	}
+	return h
+}
+
+// TokenHarvested implements captchasolve.Metrics.
+func (r *latencyRecorder) TokenHarvested(index int, provider string, latency time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	h := r.harvester(index, provider)
+	h.latencies = append(h.latencies, latency)
+}
+
+// HarvesterFailed implements captchasolve.Metrics.
+func (r *latencyRecorder) HarvesterFailed(index int, provider string, class captchasolve.ErrorClass) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.harvester(index, provider).failures[class]++
+}
+
+// The pool isn't benchmarked.
+func (r *latencyRecorder) PoolChanged(int, int, int)    {}
+func (r *latencyRecorder) TokenServed()                 {}
+func (r *latencyRecorder) TokensExpired(int)            {}
+func (r *latencyRecorder) TokensDiscarded(int)          {}
+func (r *latencyRecorder) HarvesterRetried(int, string) {}
+
+// print prints the latency percentiles and histogram of every harvester that was called.
+func (r *latencyRecorder) print(w io.Writer, elapsed time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	indexes := make([]int, 0, len(r.harvesters))
+	for i := range r.harvesters {
+		indexes = append(indexes, i)
+	}
+	slices.Sort(indexes)
+
+	fmt.Fprintf(w, "Done in %v\n", elapsed.Round(time.Millisecond))
+	for _, i := range indexes {
+		h := r.harvesters[i]
+		failures := 0
+		var classes []string
+		for class, n := range h.failures {
+			failures += n
+			classes = append(classes, fmt.Sprintf("%s: %d", class, n))
+		}
+		slices.Sort(classes)
+		fmt.Fprintf(w, "\n#%d %s: %d solved, %d failed", i+1, h.provider, len(h.latencies), failures)
+		if len(classes) > 0 {
+			fmt.Fprintf(w, " (%s)", strings.Join(classes, ", "))
+		}
+		fmt.Fprintln(w)
+		if len(h.latencies) == 0 {
+			continue
+		}
+
+		latencies := slices.Clone(h.latencies)
+		slices.Sort(latencies)
+		fmt.Fprintf(w, "  min %v  p50 %v  p90 %v  p99 %v  max %v\n",
+			round(latencies[0]), round(percentile(latencies, 0.5)), round(percentile(latencies, 0.9)),
+			round(percentile(latencies, 0.99)), round(latencies[len(latencies)-1]))
+		printHistogram(w, latencies)
+	}
+}
+
+// printHistogram prints the histogram of the sorted latencies over metrics.SolveBuckets,
+// from the first to the last bucket holding a latency.
+func printHistogram(w io.Writer, latencies []time.Duration) {
+	counts := make([]int, len(metrics.SolveBuckets)+1) // The last bucket is unbounded
+	for _, latency := range latencies {
+		bucket, _ := slices.BinarySearch(metrics.SolveBuckets, latency.Seconds())
+		counts[bucket]++
+	}
+	first, last := len(counts), 0
+	for i, n := range counts {
+		if n > 0 {
+			first, last = min(first, i), i
+		}
+	}
+	highest := slices.Max(counts)
+	for i := first; i <= last; i++ {
+		label := "> " + bucketLabel(metrics.SolveBuckets[len(metrics.SolveBuckets)-1])
+		if i < len(metrics.SolveBuckets) {
+			label = "≤ " + bucketLabel(metrics.SolveBuckets[i])
+		}
+		bar := strings.Repeat("█", counts[i]*histogramWidth/highest)
+		fmt.Fprintf(w, "  %-7s %s %d\n", label, bar, counts[i])
+	}
+}
+
+// bucketLabel formats the upper bound of a bucket, in seconds.
+func bucketLabel(seconds float64) string {
+	return round(time.Duration(seconds * float64(time.Second))).String()
+}
+
+// percentile returns the p-th percentile, between 0 and 1, of sorted durations.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	return sorted[int(p*float64(len(sorted)-1)+0.5)]
+}
+
+// round rounds d for display.
+func round(d time.Duration) time.Duration {
+	if d >= time.Second {
+		return d.Round(100 * time.Millisecond)
+	}
+	return d.Round(time.Millisecond)
+}
@@ -0,0 +1,190 @@
+// Command captchasolve solves captchas and inspects the providers of the sites of a
+// configuration file, for debugging API keys and providers without writing code.
+//
+// Usage:
+//
+//	captchasolve solve   [-config file] [-site id] [-json] [-timeout 3m]
+//	captchasolve warm    [-config file] [-site id] [-json] [-timeout 3m] [-n 5]
+//	captchasolve balance [-config file] [-site id]
+//	captchasolve bench   [-config file] [-site id] [-timeout 3m] [-n 10] [-concurrency 1]
+//
+// The site can be left out when the configuration file has a single site. Its token_file
+// is ignored, so the tool can be pointed at the configuration of a running server.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	captchatoolsgo "github.com/Matthew17-21/Captcha-Tools/captchatools-go"
+	"github.com/Matthew17-21/CaptchaSolve"
+)
+
+// defaultTimeout bounds how long a solve may take unless set with -timeout.
+const defaultTimeout = 3 * time.Minute
+
+// errUsage is returned when the command line is wrong, once the usage has been printed.
+var errUsage = errors.New("usage")
+
+// command is a subcommand of the tool.
+type command struct {
+	name    string
+	summary string
+	run     func(ctx context.Context, env *env, args []string) error
+}
+
+var commands = []command{
+	{name: "solve", summary: "solve one captcha and print its token", run: solve},
+	{name: "warm", summary: "solve n captchas concurrently and print their tokens as they arrive", run: warm},
+	{name: "balance", summary: "print the balance of every provider", run: balance},
+	{name: "bench", summary: "solve n captchas and print the latency of every provider", run: bench},
+}
+
+// env is what the commands read from and write to.
+type env struct {
+	stdout io.Writer
+	stderr io.Writer
+
+	// loadSolver creates a solver for the site of a configuration file, applying opts
+	// after its settings, returning it with the ID of the site. loadProviders creates the
+	// harvesters of its providers and returns them with their type. Tests replace them to
+	// use fake harvesters.
+	loadSolver    func(path, site string, opts ...captchasolve.ClientOption) (captchasolve.CaptchaSolve, string, error)
+	loadProviders func(path, site string) ([]captchatoolsgo.Harvester, []string, error)
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	e := &env{stdout: os.Stdout, stderr: os.Stderr, loadSolver: loadSolver, loadProviders: loadProviders}
+	if err := run(ctx, e, os.Args[1:]); err != nil {
+		if errors.Is(err, errUsage) {
+			os.Exit(2)
+		}
+		fmt.Fprintln(os.Stderr, "captchasolve:", err)
+		os.Exit(1)
+	}
+}
+
+// run runs the subcommand named by args[0] with the rest of args.
+func run(ctx context.Context, e *env, args []string) error {
+	if len(args) > 0 {
+		for _, cmd := range commands {
+			if cmd.name == args[0] {
+				return cmd.run(ctx, e, args[1:])
+			}
+		}
+		fmt.Fprintf(e.stderr, "unknown command %q\n", args[0])
+	}
+	fmt.Fprintln(e.stderr, "Usage: captchasolve <command> [flags]\n\nCommands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(e.stderr, "  %-8s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(e.stderr, "\nRun captchasolve <command> -h for the flags of a command.")
+	return errUsage
+}
+
+// siteFlags are the flags every command takes.
+type siteFlags struct {
+	config string
+	site   string
+}
+
+// newFlagSet returns the flag set of the named command, with the flags every command
+// takes bound to sf.
+func newFlagSet(e *env, name string, sf *siteFlags) *flag.FlagSet {
+	fs := flag.NewFlagSet("captchasolve "+name, flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	fs.StringVar(&sf.config, "config", "captchasolve.yaml", "path of the configuration file (YAML, JSON or TOML)")
+	fs.StringVar(&sf.site, "site", "", "ID of the site; may be left out if the file has a single site")
+	return fs
+}
+
+// parse parses the arguments of a command, returning errUsage if they are wrong.
+func parse(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(fs.Output(), "unexpected arguments: %v\n", fs.Args())
+		fs.Usage()
+		return errUsage
+	}
+	return nil
+}
+
+// siteConfig reads the configuration file at path and returns the configuration of site,
+// or of its only site if site is empty, with the ID of the site.
+func siteConfig(path, site string) (*captchasolve.FileConfig, string, error) {
+	fc, err := captchasolve.ReadConfig(path)
+	if err != nil {
+		return nil, "", err
+	}
+	if site == "" {
+		if len(fc.Sites) != 1 {
+			return nil, "", fmt.Errorf("%s has %d sites, pick one with -site", path, len(fc.Sites))
+		}
+		for id := range fc.Sites {
+			site = id
+		}
+	}
+	if _, ok := fc.Sites[site]; !ok {
+		return nil, "", fmt.Errorf("%w: %q isn't configured in %s", captchasolve.ErrUnknownSite, site, path)
+	}
+	return fc, site, nil
+}
+
+// solverConfig reads the configuration file at path, keeping only the site the solvers of
+// the commands are created for, as siteConfig picks it. The token file of the site is left
+// alone, as it may be in use by a server running with the same file.
+func solverConfig(path, site string) (*captchasolve.FileConfig, string, error) {
+	fc, site, err := siteConfig(path, site)
+	if err != nil {
+		return nil, "", err
+	}
+	sc := fc.Sites[site]
+	sc.TokenFile = ""
+	fc.Sites = map[string]captchasolve.SiteConfig{site: sc}
+	return fc, site, nil
+}
+
+// loadSolver creates a solver for the site of the configuration file at path, applying
+// opts after its settings, and returns it with the ID of the site.
+func loadSolver(path, site string, opts ...captchasolve.ClientOption) (captchasolve.CaptchaSolve, string, error) {
+	fc, site, err := solverConfig(path, site)
+	if err != nil {
+		return nil, "", err
+	}
+	registry, err := fc.Registry(opts...)
+	if err != nil {
+		return nil, "", err
+	}
+	solver, _ := registry.Solver(site)
+	return solver, site, nil
+}
+
+// loadProviders creates the harvesters of the providers of the site of the configuration
+// file at path, returning them with their type.
+func loadProviders(path, site string) ([]captchatoolsgo.Harvester, []string, error) {
+	fc, site, err := siteConfig(path, site)
+	if err != nil {
+		return nil, nil, err
+	}
+	sc := fc.Sites[site]
+	harvesters, err := sc.Harvesters(site)
+	if err != nil {
+		return nil, nil, err
+	}
+	types := make([]string, len(sc.Providers))
+	for i, pc := range sc.Providers {
+		types[i] = pc.Type
+	}
+	return harvesters, types, nil
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	captchatoolsgo "github.com/Matthew17-21/Captcha-Tools/captchatools-go"
+	"github.com/Matthew17-21/CaptchaSolve"
+	"github.com/Matthew17-21/CaptchaSolve/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeHarvester is a harvester returning numbered tokens and a fixed balance.
+type fakeHarvester struct {
+	calls      atomic.Int32
+	balance    float32
+	balanceErr error
+}
+
+func (f *fakeHarvester) GetToken(additional ...*captchatoolsgo.AdditionalData) (*captchatoolsgo.CaptchaAnswer, error) {
+	return f.GetTokenWithContext(context.Background(), additional...)
+}
+
+func (f *fakeHarvester) GetTokenWithContext(context.Context, ...*captchatoolsgo.AdditionalData) (*captchatoolsgo.CaptchaAnswer, error) {
+	n := f.calls.Add(1)
+	return &captchatoolsgo.CaptchaAnswer{Token: "token-" + strconv.Itoa(int(n)), UserAgent: "agent"}, nil
+}
+
+func (f *fakeHarvester) GetBalance() (float32, error) { return f.balance, f.balanceErr }
+
+// newEnv returns an env whose "retailer" site is solved by harvesters, and the buffers
+// its output is written to.
+func newEnv(harvesters ...*fakeHarvester) (*env, *bytes.Buffer, *bytes.Buffer) {
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	e := &env{
+		stdout: stdout,
+		stderr: stderr,
+		loadSolver: func(_, _ string, opts ...captchasolve.ClientOption) (captchasolve.CaptchaSolve, string, error) {
+			var all []captchasolve.ClientOption
+			for _, h := range harvesters {
+				all = append(all, captchasolve.WithHarvester(h))
+			}
+			return captchasolve.New(append(all, opts...)...), "retailer", nil
+		},
+		loadProviders: func(_, _ string) ([]captchatoolsgo.Harvester, []string, error) {
+			hs := make([]captchatoolsgo.Harvester, len(harvesters))
+			types := make([]string, len(harvesters))
+			for i, h := range harvesters {
+				hs[i], types[i] = h, "fake"
+			}
+			return hs, types, nil
+		},
+	}
+	return e, stdout, stderr
+}
+
+func TestRun(t *testing.T) {
+	t.Run("prints the usage without a command", func(t *testing.T) {
+		e, _, stderr := newEnv()
+
+		err := run(context.Background(), e, nil)
+
+		require.ErrorIs(t, err, errUsage)
+		assert.Contains(t, stderr.String(), "Usage: captchasolve <command>")
+		assert.Contains(t, stderr.String(), "balance")
+	})
+
+	t.Run("rejects unknown commands", func(t *testing.T) {
+		e, _, stderr := newEnv()
+
+		err := run(context.Background(), e, []string{"harvest"})
+
+		require.ErrorIs(t, err, errUsage)
+		assert.Contains(t, stderr.String(), `unknown command "harvest"`)
+	})
+
+	t.Run("rejects extra arguments", func(t *testing.T) {
+		e, _, stderr := newEnv(&fakeHarvester{})
+
+		err := run(context.Background(), e, []string{"solve", "retailer"})
+
+		require.ErrorIs(t, err, errUsage)
+		assert.Contains(t, stderr.String(), "unexpected arguments: [retailer]")
+	})
+}
+
+func TestSolve(t *testing.T) {
+	t.Run("prints the raw token", func(t *testing.T) {
+		e, stdout, _ := newEnv(&fakeHarvester{})
+
+		err := run(context.Background(), e, []string{"solve"})
+
+		require.NoError(t, err)
+		assert.Equal(t, "token-1\n", stdout.String())
+	})
+
+	t.Run("prints the token as JSON", func(t *testing.T) {
+		e, stdout, _ := newEnv(&fakeHarvester{})
+
+		err := run(context.Background(), e, []string{"solve", "-json"})
+
+		require.NoError(t, err)
+		var token server.TokenResponse
+		require.NoError(t, json.Unmarshal(stdout.Bytes(), &token))
+		assert.Equal(t, "retailer", token.Site)
+		assert.Equal(t, "token-1", token.Token)
+		assert.Equal(t, "agent", token.UserAgent)
+		assert.Equal(t, "*main.fakeHarvester", token.Provider)
+		assert.WithinDuration(t, time.Now().Add(2*time.Minute), token.ExpiresAt, 5*time.Second)
+	})
+}
+
+func TestWarm(t *testing.T) {
+	// Arrange
+	h := &fakeHarvester{}
+	e, stdout, _ := newEnv(h)
+
+	// Act
+	err := run(context.Background(), e, []string{"warm", "-n", "3"})
+
+	// Assert
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"token-1", "token-2", "token-3"}, strings.Fields(stdout.String()))
+	assert.Equal(t, int32(3), h.calls.Load())
+}
+
+func TestBalance(t *testing.T) {
+	t.Run("prints every balance", func(t *testing.T) {
+		e, stdout, _ := newEnv(&fakeHarvester{balance: 12.5}, &fakeHarvester{balance: 0.25})
+
+		err := run(context.Background(), e, []string{"balance"})
+
+		require.NoError(t, err)
+		assert.Equal(t, "#  PROVIDER  BALANCE\n1  fake      12.5000\n2  fake      0.2500\n", stdout.String())
+	})
+
+	t.Run("prints the failed checks", func(t *testing.T) {
+		e, stdout, _ := newEnv(&fakeHarvester{balance: 1}, &fakeHarvester{balanceErr: errors.New("invalid key")})
+
+		err := run(context.Background(), e, []string{"balance"})
+
+		require.EqualError(t, err, "1 of 2 balance checks failed")
+		assert.Contains(t, stdout.String(), "2  fake      error: invalid key")
+	})
+}
+
+func TestBench(t *testing.T) {
+	t.Run("prints the latency of the harvester", func(t *testing.T) {
+		// Arrange
+		h := &fakeHarvester{}
+		e, stdout, _ := newEnv(h)
+
+		// Act
+		err := run(context.Background(), e, []string{"bench", "-n", "4", "-concurrency", "2"})
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, int32(4), h.calls.Load(), "surplus solves should be disabled")
+		assert.Contains(t, stdout.String(), "#1 *main.fakeHarvester: 4 solved, 0 failed")
+		assert.Contains(t, stdout.String(), "≤ 1s")
+	})
+
+	t.Run("spreads the solves over every harvester", func(t *testing.T) {
+		// Arrange, with a site failing over by priority and hedging every solve
+		h1, h2 := &fakeHarvester{}, &fakeHarvester{}
+		e, stdout, _ := newEnv(h1, h2)
+		load := e.loadSolver
+		e.loadSolver = func(path, site string, opts ...captchasolve.ClientOption) (captchasolve.CaptchaSolve, string, error) {
+			return load(path, site, append([]captchasolve.ClientOption{
+				captchasolve.WithStrategy(captchasolve.PriorityFailover(3)),
+				captchasolve.WithHedging(captchasolve.HedgePolicy{MaxHedges: 1}),
+			}, opts...)...)
+		}
+
+		// Act
+		err := run(context.Background(), e, []string{"bench", "-n", "4"})
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, int32(2), h1.calls.Load())
+		assert.Equal(t, int32(2), h2.calls.Load())
+		assert.Contains(t, stdout.String(), "#1 *main.fakeHarvester: 2 solved, 0 failed")
+		assert.Contains(t, stdout.String(), "#2 *main.fakeHarvester: 2 solved, 0 failed")
+	})
+}
+
+func TestPrintHistogram(t *testing.T) {
+	// Arrange
+	var out bytes.Buffer
+	latencies := []time.Duration{2 * time.Second, 3 * time.Second, 4 * time.Second, 12 * time.Second, 200 * time.Second}
+
+	// Act
+	printHistogram(&out, latencies)
+
+	// Assert
+	bar := strings.Repeat("█", histogramWidth)
+	half := strings.Repeat("█", histogramWidth/2)
+	assert.Equal(t, "  ≤ 2.5s  "+half+" 1\n"+
+		"  ≤ 5s    "+bar+" 2\n"+
+		"  ≤ 10s    0\n"+
+		"  ≤ 15s   "+half+" 1\n"+
+		"  ≤ 20s    0\n"+
+		"  ≤ 30s    0\n"+
+		"  ≤ 45s    0\n"+
+		"  ≤ 1m0s   0\n"+
+		"  ≤ 1m30s  0\n"+
+		"  ≤ 2m0s   0\n"+
+		"  ≤ 3m0s   0\n"+
+		"  > 3m0s  "+half+" 1\n", out.String())
+}
+
+func TestSiteConfig(t *testing.T) {
+	// Arrange
+	const site = `{"type": "v2", "site_key": "key", "url": "https://retailer.example", "providers": [{"type": "capmonster", "api_key": "secret"}]}`
+	dir := t.TempDir()
+	single := filepath.Join(dir, "single.json")
+	require.NoError(t, os.WriteFile(single, []byte(`{"sites": {"retailer": `+site+`}}`), 0o600))
+	multiple := filepath.Join(dir, "multiple.json")
+	require.NoError(t, os.WriteFile(multiple, []byte(`{"sites": {"retailer": `+site+`, "shop": `+site+`}}`), 0o600))
+
+	// Act & Assert
+	_, id, err := siteConfig(single, "")
+	require.NoError(t, err)
+	assert.Equal(t, "retailer", id)
+
+	_, id, err = siteConfig(multiple, "shop")
+	require.NoError(t, err)
+	assert.Equal(t, "shop", id)
+
+	_, _, err = siteConfig(multiple, "")
+	require.ErrorContains(t, err, "has 2 sites, pick one with -site")
+
+	_, _, err = siteConfig(single, "shop")
+	require.ErrorIs(t, err, captchasolve.ErrUnknownSite)
+}
+
+func TestSolverConfig(t *testing.T) {
+	// Arrange
+	site := func(tokenFile string) string {
+		return `{"type": "v2", "site_key": "key", "url": "https://retailer.example", "token_file": "` + tokenFile +
+			`", "providers": [{"type": "capmonster", "api_key": "secret"}]}`
+	}
+	path := filepath.Join(t.TempDir(), "config.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"sites": {"retailer": `+site("retailer.jsonl")+`, "shop": `+site("shop.jsonl")+`}}`), 0o600))
+
+	// Act
+	fc, id, err := solverConfig(path, "shop")
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, "shop", id)
+	require.Len(t, fc.Sites, 1, "the other sites should be left alone")
+	assert.Empty(t, fc.Sites["shop"].TokenFile, "the token file of the server shouldn't be used")
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/Matthew17-21/CaptchaSolve"
+	"github.com/Matthew17-21/CaptchaSolve/server"
+)
+
+// solve solves one captcha and prints its token.
+func solve(ctx context.Context, e *env, args []string) error {
+	var sf siteFlags
+	fs := newFlagSet(e, "solve", &sf)
+	asJSON := fs.Bool("json", false, "print the token and its details as JSON")
+	timeout := fs.Duration("timeout", defaultTimeout, "how long to wait for the token")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	solver, site, err := e.loadSolver(sf.config, sf.site)
+	if err != nil {
+		return err
+	}
+	defer solver.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+	token, err := solver.GetToken(ctx)
+	if err != nil {
+		return err
+	}
+	return printToken(e.stdout, site, token, *asJSON)
+}
+
+// warm solves n captchas concurrently, printing their tokens as they arrive. Every solve
+// is attempted even if some fail, and the errors are printed as they occur.
+func warm(ctx context.Context, e *env, args []string) error {
+	var sf siteFlags
+	fs := newFlagSet(e, "warm", &sf)
+	n := fs.Int("n", 5, "number of tokens to solve")
+	asJSON := fs.Bool("json", false, "print every token and its details as a line of JSON")
+	timeout := fs.Duration("timeout", defaultTimeout, "how long to wait for the tokens")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if *n < 1 {
+		return fmt.Errorf("-n must be at least 1, got %d", *n)
+	}
+
+	solver, site, err := e.loadSolver(sf.config, sf.site)
+	if err != nil {
+		return err
+	}
+	defer solver.Close()
+
+	// Wait for every token at once, so they are solved within the concurrency limit of
+	// the site
+	ctx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+	var mu sync.Mutex // Serializes the output
+	failed := 0
+	var wg sync.WaitGroup
+	for i := 0; i < *n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			token, err := solver.GetToken(ctx)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				fmt.Fprintln(e.stderr, "error:", err)
+				failed++
+				return
+			}
+			if err := printToken(e.stdout, site, token, *asJSON); err != nil {
+				fmt.Fprintln(e.stderr, "error:", err)
+				failed++
+			}
+		}()
+	}
+	wg.Wait()
+	if failed > 0 {
+		return fmt.Errorf("%d of %d solves failed", failed, *n)
+	}
+	return nil
+}
+
+// printToken prints token, raw or as a line of JSON with its details.
+func printToken(w io.Writer, site string, token *captchasolve.CaptchaAnswer, asJSON bool) error {
+	if !asJSON {
+		_, err := fmt.Fprintln(w, token.Token)
+		return err
+	}
+	return json.NewEncoder(w).Encode(server.TokenResponse{
+		Site:      site,
+		Token:     token.Token,
+		UserAgent: token.UserAgent,
+		ID:        token.Id(),
+		Provider:  token.Provider(),
+		ExpiresAt: token.ExpiresAt(),
+	})
+}
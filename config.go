@@ -1,21 +1,97 @@
 package captchasolve
 
-import captchatools "github.com/Matthew17-21/Captcha-Tools/captchatools-go"
+import (
+	"errors"
+	"fmt"
+	"time"
 
-const defaultMaxCapacity = 25
+	captchatools "github.com/Matthew17-21/Captcha-Tools/captchatools-go"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	defaultMaxCapacity   = 25
+	defaultMaxGoroutines = 10
+)
 
 type config struct {
-	// maxCapacity defines the maximum number of captcha tokens that can be held in the system.
+	// maxCapacity defines the maximum number of captcha tokens that can be held in the system,
+	// whatever their fingerprint.
 	maxCapacity int
 
 	// maxGoroutines specifies the maximum number of goroutines allowed to run concurrently
 	// for solving captchas. This helps control resource usage and parallel processing.
 	maxGoroutines int
 
+	// surplus is the number of extra solves kept in flight on top of the waiting callers.
+	// Tokens nobody is waiting for when they arrive are added to the queue.
+	surplus int
+
+	// prefillMin and prefillMax bound the number of tokens kept ready in the queue by the
+	// prefill worker. Once fewer than prefillMin tokens are queued, new solves are started
+	// to bring the queue back up to prefillMax. Prefilling is disabled when prefillMax is 0.
+	prefillMin int
+	prefillMax int
+
 	// harvesters is a slice of Harvester instances from the captchatools package.
 	// These are used to fetch or generate captcha tokens as needed.
 	harvesters []captchatools.Harvester
 
+	// harvesterSettings holds the settings given to WithHarvester, by harvester index.
+	harvesterSettings []harvesterSettings
+
+	// strategy picks the harvester used for each solve.
+	strategy Strategy
+
+	// hedge configures hedged solves, which start backup harvesters when a solve is slow.
+	hedge HedgePolicy
+
+	// retry is the RetryPolicy of the harvesters that don't have one of their own.
+	retry RetryPolicy
+
+	// solveBudget is the maximum number of solves the instance may start over its
+	// lifetime. There is no limit when it is 0.
+	solveBudget int
+
+	// tokenValidity is how long tokens remain valid for after being solved, unless
+	// validityFunc returns a validity window of its own.
+	tokenValidity time.Duration
+	validityFunc  ValidityFunc
+
+	// expiryMargin is how long a token must remain valid for to be handed to a caller,
+	// so callers never get a token that expires before it can be submitted.
+	expiryMargin time.Duration
+
+	// sweepInterval is how often expired tokens are evicted from the queue in bulk.
+	// The sweeper is disabled when it is 0. When sweepRefill is set, evicted tokens are
+	// replaced with new solves.
+	sweepInterval time.Duration
+	sweepRefill   bool
+
+	// quarantineRecheck is how long a quarantined harvester waits before its balance is
+	// checked again. The wait doubles after every failed check, up to quarantineMaxRecheck.
+	quarantineRecheck    time.Duration
+	quarantineMaxRecheck time.Duration
+
+	// shutdownPolicy controls what happens to in-flight solves and queued tokens on shutdown.
+	// shutdownPolicySet records whether it was set with WithShutdownPolicy, as it defaults
+	// to ShutdownDrain when there is a token file.
+	shutdownPolicy    ShutdownPolicy
+	shutdownPolicySet bool
+
+	// tokenFile is the path of the log the queued tokens are kept in across restarts.
+	// Tokens are only kept in memory when it is empty.
+	tokenFile string
+
+	// metrics receives measurements of the pool and the harvesters when set.
+	metrics Metrics
+
+	// tracer creates the spans of the solver when set.
+	tracer trace.Tracer
+
+	// captchaType is the type of the captcha the harvesters solve, recorded on the spans.
+	captchaType string
+
 	// logger is an instance of the Logger interface used for logging system events,
 	// debugging information, and error messages.
 	logger Logger
@@ -23,8 +99,47 @@ type config struct {
 
 func defaultConfig() config {
 	return config{
-		maxCapacity: defaultMaxCapacity,
-		harvesters:  make([]captchatools.Harvester, 0),
-		logger:      NewSilentLogger(),
+		maxCapacity:          defaultMaxCapacity,
+		maxGoroutines:        defaultMaxGoroutines,
+		tokenValidity:        captchaTokenValidity,
+		expiryMargin:         defaultExpiryMargin,
+		sweepInterval:        defaultSweepInterval,
+		quarantineRecheck:    defaultQuarantineRecheck,
+		quarantineMaxRecheck: defaultQuarantineMaxRecheck,
+		harvesters:           make([]captchatools.Harvester, 0),
+		strategy:             RoundRobin(),
+		logger:               NewSilentLogger(),
+	}
+}
+
+// validate reports every problem with the configuration that would keep the client from
+// harvesting tokens.
+func (c config) validate() error {
+	var errs []error
+	if len(c.harvesters) == 0 {
+		errs = append(errs, ErrNoHarvesters)
 	}
+	for i, h := range c.harvesters {
+		if h == nil {
+			errs = append(errs, fmt.Errorf("captchasolve: harvester #%d is nil", i+1))
+		}
+	}
+	if c.strategy == nil {
+		errs = append(errs, errors.New("captchasolve: strategy is nil"))
+	}
+	if c.logger == nil {
+		errs = append(errs, errors.New("captchasolve: logger is nil"))
+	}
+	return errors.Join(errs...)
+}
+
+// harvesterSettings holds the settings of a single harvester.
+type harvesterSettings struct {
+	// retryPolicy overrides the client's RetryPolicy for the harvester when set.
+	retryPolicy RetryPolicy
+
+	// priority, weight and cost describe the harvester to the client's Strategy.
+	priority int
+	weight   int
+	cost     float64
 }
@@ -0,0 +1,573 @@
+package captchasolve
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	captchatoolsgo "github.com/Matthew17-21/Captcha-Tools/captchatools-go"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultFailoverAfter is the number of consecutive failures after which the
+// "priority-failover" strategy moves on to the next harvester, unless a site sets its own.
+const defaultFailoverAfter = 3
+
+// FileConfig is the schema of the configuration files read by LoadConfig. Settings left
+// out, or set to 0, use the same defaults as the matching ClientOption.
+//
+// Example, in YAML:
+//
+//	logging:
+//	  level: info
+//	  format: json
+//	sites:
+//	  retailer:
+//	    type: v2
+//	    site_key: 6Lc...
+//	    url: https://retailer.example/checkout
+//	    capacity: 25
+//	    prefill: {min: 2, max: 5}
+//	    validity: 110s
+//	    strategy: priority-failover
+//	    providers:
+//	      - type: capmonster
+//	        api_key: env:CAPMONSTER_KEY
+//	        priority: 0
+//	        cost: 0.8
+//	      - type: 2captcha
+//	        api_key: file:/run/secrets/2captcha
+//	        priority: 1
+//	        cost: 2.99
+type FileConfig struct {
+	Logging LoggingConfig         `json:"logging" yaml:"logging" toml:"logging"`
+	Sites   map[string]SiteConfig `json:"sites" yaml:"sites" toml:"sites"` // Sites by the ID tokens are requested with
+}
+
+// LoggingConfig configures the logger shared by every site. Nothing is logged unless a
+// level is set.
+type LoggingConfig struct {
+	Level  string `json:"level" yaml:"level" toml:"level"`    // debug, info, warn or error
+	Format string `json:"format" yaml:"format" toml:"format"` // text (the default) or json
+}
+
+// SiteConfig configures the solver of a site.
+type SiteConfig struct {
+	Type      string  `json:"type" yaml:"type" toml:"type"`                // v2, v3, hcaptcha, image or turnstile
+	SiteKey   string  `json:"site_key" yaml:"site_key" toml:"site_key"`    // Site key of the captcha
+	URL       string  `json:"url" yaml:"url" toml:"url"`                   // URL of the page the captcha is on
+	Action    string  `json:"action" yaml:"action" toml:"action"`          // Action of reCAPTCHA v3 captchas
+	MinScore  float32 `json:"min_score" yaml:"min_score" toml:"min_score"` // Minimum score of reCAPTCHA v3 tokens
+	Invisible bool    `json:"invisible" yaml:"invisible" toml:"invisible"` // Whether the reCAPTCHA is invisible
+
+	Capacity     int           `json:"capacity" yaml:"capacity" toml:"capacity"`                // See WithMaxCapacity
+	MaxSolves    int           `json:"max_solves" yaml:"max_solves" toml:"max_solves"`          // See WithMaxGoroutines
+	Surplus      int           `json:"surplus" yaml:"surplus" toml:"surplus"`                   // See WithSurplus
+	SolveBudget  int           `json:"solve_budget" yaml:"solve_budget" toml:"solve_budget"`    // See WithSolveBudget
+	Prefill      PrefillConfig `json:"prefill" yaml:"prefill" toml:"prefill"`                   // See WithPrefill
+	Validity     Duration      `json:"validity" yaml:"validity" toml:"validity"`                // See WithTokenValidity
+	ExpiryMargin Duration      `json:"expiry_margin" yaml:"expiry_margin" toml:"expiry_margin"` // See WithExpiryMargin
+
+	// TokenFile keeps the queued tokens of the site across restarts, see WithTokenFile.
+	// Sites with a token file drain on shutdown, see ShutdownDrain. It is only read when
+	// the solver is created, not when the configuration is reloaded.
+	TokenFile string `json:"token_file" yaml:"token_file" toml:"token_file"`
+
+	// Strategy picks the harvester used for each solve: round-robin (the default),
+	// priority-failover, weighted-random, lowest-cost or lowest-latency. FailoverAfter is
+	// the number of consecutive failures after which priority-failover moves on, 3 by default.
+	Strategy      string `json:"strategy" yaml:"strategy" toml:"strategy"`
+	FailoverAfter int    `json:"failover_after" yaml:"failover_after" toml:"failover_after"`
+
+	Providers []ProviderConfig `json:"providers" yaml:"providers" toml:"providers"`
+}
+
+// PrefillConfig is the number of tokens kept ready for a site. See WithPrefill.
+type PrefillConfig struct {
+	Min int `json:"min" yaml:"min" toml:"min"`
+	Max int `json:"max" yaml:"max" toml:"max"`
+}
+
+// ProviderConfig configures a harvester of a site.
+//
+// APIKey is either the API key itself or a reference to it: "env:NAME" reads it from the
+// NAME environment variable and "file:PATH" from the file at PATH, so keys don't have to
+// be stored in the configuration file.
+type ProviderConfig struct {
+	Type     string  `json:"type" yaml:"type" toml:"type"`          // anticaptcha, capmonster, 2captcha, capsolver or captchaai
+	APIKey   string  `json:"api_key" yaml:"api_key" toml:"api_key"` // API key or reference to it
+	Priority int     `json:"priority" yaml:"priority" toml:"priority"`
+	Weight   int     `json:"weight" yaml:"weight" toml:"weight"`
+	Cost     float64 `json:"cost" yaml:"cost" toml:"cost"` // Cost of a solve, for the lowest-cost strategy
+}
+
+// Duration is a time.Duration written in configuration files as a string such as "90s"
+// or "2m".
+type Duration time.Duration
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// FieldError is a problem with a field of a configuration file. Path is the location of
+// the field, such as "sites.retailer.providers[1].api_key".
+type FieldError struct {
+	Path string
+	Err  error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Path, e.Err)
+}
+
+func (e *FieldError) Unwrap() error { return e.Err }
+
+// LoadConfig reads the configuration file at path and returns a Registry with a solver
+// for every site it describes. The format of the file is picked from its extension:
+// .yaml, .yml, .json or .toml. Every problem with the file is reported at once, as
+// *FieldError values joined together.
+//
+// The options are applied to the solver of every site after the file's settings, for
+// example to add metrics or tracing.
+//
+// Example:
+//
+//	registry, err := LoadConfig("captchasolve.yaml", WithMetrics(metrics.New(prometheus.DefaultRegisterer)))
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	defer registry.Close()
+//	token, err := registry.GetToken(ctx, "retailer")
+func LoadConfig(path string, opts ...ClientOption) (*Registry, error) {
+	fc, err := ReadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	return fc.Registry(opts...)
+}
+
+// ReadConfig reads and validates the configuration file at path. See LoadConfig.
+func ReadConfig(path string) (*FileConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("captchasolve: reading config: %w", err)
+	}
+	return parseConfigFile(path, data)
+}
+
+// parseConfigFile parses and validates data, read from the configuration file at path.
+func parseConfigFile(path string, data []byte) (*FileConfig, error) {
+	format := strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	fc, err := ParseConfig(data, format)
+	if err != nil {
+		return nil, fmt.Errorf("captchasolve: config %s: %w", path, err)
+	}
+	return fc, nil
+}
+
+// ParseConfig parses and validates a configuration in the given format: yaml, yml, json
+// or toml. Unknown fields are reported as errors so typos don't go unnoticed.
+func ParseConfig(data []byte, format string) (*FileConfig, error) {
+	var fc FileConfig
+	switch format {
+	case "yaml", "yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(&fc); err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+	case "json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&fc); err != nil {
+			return nil, err
+		}
+	case "toml":
+		md, err := toml.Decode(string(data), &fc)
+		if err != nil {
+			return nil, err
+		}
+		var errs []error
+		for _, key := range md.Undecoded() {
+			errs = append(errs, &FieldError{Path: key.String(), Err: errors.New("unknown field")})
+		}
+		if len(errs) > 0 {
+			return nil, errors.Join(errs...)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported config format %q", format)
+	}
+	if err := fc.Validate(); err != nil {
+		return nil, err
+	}
+	return &fc, nil
+}
+
+// Validate reports every problem with the configuration, API keys that can't be resolved
+// included.
+func (fc *FileConfig) Validate() error {
+	var errs []error
+	if _, err := fc.logger(); err != nil {
+		errs = append(errs, err)
+	}
+	if len(fc.Sites) == 0 {
+		errs = append(errs, &FieldError{Path: "sites", Err: errors.New("no sites configured")})
+	}
+	tokenFiles := make(map[string]string) // Site using each token file
+	for _, id := range fc.siteIDs() {
+		if err := fc.Sites[id].validate(id); err != nil {
+			errs = append(errs, err)
+		}
+		if path := fc.Sites[id].TokenFile; path != "" {
+			path = filepath.Clean(path)
+			if other, ok := tokenFiles[path]; ok {
+				errs = append(errs, &FieldError{Path: "sites." + id + ".token_file", Err: fmt.Errorf("already used by site %q", other)})
+			}
+			tokenFiles[path] = id
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Registry returns a Registry with a solver for every site of the configuration. The
+// options are applied to every solver after the configuration's settings, including when
+// the Registry is reloaded.
+func (fc *FileConfig) Registry(opts ...ClientOption) (*Registry, error) {
+	registry := NewRegistry()
+	registry.configOpts = opts
+	if err := registry.Reload(fc); err != nil {
+		registry.Close()
+		return nil, err
+	}
+	return registry, nil
+}
+
+// siteIDs returns the IDs of the configured sites, sorted so problems are always
+// reported in the same order.
+func (fc *FileConfig) siteIDs() []string {
+	ids := make([]string, 0, len(fc.Sites))
+	for id := range fc.Sites {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// logger returns the Logger described by the logging settings.
+func (fc *FileConfig) logger() (Logger, error) {
+	if fc.Logging.Level == "" {
+		return NewSilentLogger(), nil
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(fc.Logging.Level)); err != nil {
+		return nil, &FieldError{Path: "logging.level", Err: fmt.Errorf("unknown level %q", fc.Logging.Level)}
+	}
+	switch fc.Logging.Format {
+	case "", "text":
+		return NewLoggerWithLevel(level), nil
+	case "json":
+		return NewSlogLogger(slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: level}))), nil
+	default:
+		return nil, &FieldError{Path: "logging.format", Err: fmt.Errorf("unknown format %q", fc.Logging.Format)}
+	}
+}
+
+// Site returns the Site the configuration is for.
+func (sc SiteConfig) Site() Site {
+	return Site{CaptchaType: sc.Type, SiteKey: sc.SiteKey, URL: sc.URL, Action: sc.Action}
+}
+
+// resolved returns a copy of the configuration with the API keys of the providers
+// resolved. Keys that can't be resolved are left empty.
+func (sc SiteConfig) resolved() SiteConfig {
+	sc.Providers = slices.Clone(sc.Providers)
+	for i := range sc.Providers {
+		sc.Providers[i].APIKey, _ = resolveSecret(sc.Providers[i].APIKey)
+	}
+	return sc
+}
+
+// validate reports every problem with the configuration of the site registered under id.
+func (sc SiteConfig) validate(id string) error {
+	path := "sites." + id
+	var errs []error
+	fieldErr := func(field string, format string, args ...any) {
+		errs = append(errs, &FieldError{Path: path + "." + field, Err: fmt.Errorf(format, args...)})
+	}
+
+	// Captcha
+	switch _, ok := captchaTypes[sc.Type]; {
+	case sc.Type == "":
+		fieldErr("type", "required")
+	case !ok:
+		fieldErr("type", "unknown captcha type %q", sc.Type)
+	}
+	if sc.Type != "image" {
+		if sc.SiteKey == "" {
+			fieldErr("site_key", "required")
+		}
+		if sc.URL == "" {
+			fieldErr("url", "required")
+		}
+	}
+	if sc.MinScore < 0 || sc.MinScore > 1 {
+		fieldErr("min_score", "must be between 0 and 1, got %v", sc.MinScore)
+	}
+
+	// Pool
+	for _, field := range []struct {
+		name  string
+		value int
+	}{
+		{"capacity", sc.Capacity},
+		{"max_solves", sc.MaxSolves},
+		{"surplus", sc.Surplus},
+		{"solve_budget", sc.SolveBudget},
+		{"prefill.min", sc.Prefill.Min},
+		{"failover_after", sc.FailoverAfter},
+	} {
+		if field.value < 0 {
+			fieldErr(field.name, "must not be negative, got %d", field.value)
+		}
+	}
+	capacity := defaultMaxCapacity
+	if sc.Capacity != 0 {
+		capacity = sc.Capacity
+	}
+	if sc.Prefill.Max < sc.Prefill.Min {
+		fieldErr("prefill.max", "must not be less than prefill.min (%d), got %d", sc.Prefill.Min, sc.Prefill.Max)
+	} else if sc.Prefill.Max > capacity {
+		fieldErr("prefill.max", "must not exceed the capacity (%d), got %d", capacity, sc.Prefill.Max)
+	}
+	if sc.Validity < 0 {
+		fieldErr("validity", "must not be negative, got %v", time.Duration(sc.Validity))
+	}
+	if sc.ExpiryMargin < 0 {
+		fieldErr("expiry_margin", "must not be negative, got %v", time.Duration(sc.ExpiryMargin))
+	}
+	if _, ok := strategies[sc.Strategy]; !ok {
+		fieldErr("strategy", "unknown strategy %q", sc.Strategy)
+	}
+
+	// Providers
+	if len(sc.Providers) == 0 {
+		fieldErr("providers", "no providers configured")
+	}
+	for i, pc := range sc.Providers {
+		if err := pc.validate(fmt.Sprintf("%s.providers[%d]", path, i)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// options validates the configuration of the site registered under id and returns the
+// ClientOptions it describes along with its harvesters. The harvesters of the previous
+// configuration of the site are reused for the providers that didn't change, so Update
+// keeps their health; the others are created.
+func (sc SiteConfig) options(id string, previous SiteConfig, reuse []captchatoolsgo.Harvester) ([]ClientOption, []captchatoolsgo.Harvester, error) {
+	if err := sc.validate(id); err != nil {
+		return nil, nil, err
+	}
+
+	harvesters, err := sc.harvesters(id, previous, reuse)
+	if err != nil {
+		return nil, nil, err
+	}
+	opts := []ClientOption{WithCaptchaType(sc.Type)}
+	for i, pc := range sc.Providers {
+		opts = append(opts, WithHarvester(harvesters[i],
+			WithHarvesterPriority(pc.Priority),
+			WithHarvesterWeight(pc.Weight),
+			WithHarvesterCost(pc.Cost),
+		))
+	}
+	if sc.Capacity != 0 {
+		opts = append(opts, WithMaxCapacity(sc.Capacity))
+	}
+	if sc.MaxSolves != 0 {
+		opts = append(opts, WithMaxGoroutines(sc.MaxSolves))
+	}
+	if sc.Validity != 0 {
+		opts = append(opts, WithTokenValidity(time.Duration(sc.Validity)))
+	}
+	if sc.ExpiryMargin != 0 {
+		opts = append(opts, WithExpiryMargin(time.Duration(sc.ExpiryMargin)))
+	}
+	if sc.TokenFile != "" {
+		opts = append(opts, WithTokenFile(sc.TokenFile))
+	}
+	failoverAfter := defaultFailoverAfter
+	if sc.FailoverAfter > 0 {
+		failoverAfter = sc.FailoverAfter
+	}
+	return append(opts,
+		WithSurplus(sc.Surplus),
+		WithSolveBudget(sc.SolveBudget),
+		WithPrefill(sc.Prefill.Min, sc.Prefill.Max),
+		WithStrategy(strategies[sc.Strategy](failoverAfter)),
+	), harvesters, nil
+}
+
+// Harvesters creates the harvesters of the providers configured for the site registered
+// under id, in order, for example to check their balance. The configuration must be
+// valid.
+func (sc SiteConfig) Harvesters(id string) ([]captchatoolsgo.Harvester, error) {
+	return sc.harvesters(id, SiteConfig{}, nil)
+}
+
+// harvesters creates the harvesters of the providers configured for the site registered
+// under id, in order. The harvester reuse[i] of the previous configuration is kept
+// instead when the provider at i and the captcha it solves are the same in both.
+func (sc SiteConfig) harvesters(id string, previous SiteConfig, reuse []captchatoolsgo.Harvester) ([]captchatoolsgo.Harvester, error) {
+	sameCaptcha := previous.Site() == sc.Site() && previous.Invisible == sc.Invisible && previous.MinScore == sc.MinScore
+	harvesters := make([]captchatoolsgo.Harvester, len(sc.Providers))
+	for i, pc := range sc.Providers {
+		if sameCaptcha && i < len(reuse) && i < len(previous.Providers) && previous.Providers[i] == pc {
+			harvesters[i] = reuse[i]
+			continue
+		}
+		h, err := pc.harvester(sc)
+		if err != nil {
+			return nil, &FieldError{Path: fmt.Sprintf("sites.%s.providers[%d]", id, i), Err: err}
+		}
+		harvesters[i] = h
+	}
+	return harvesters, nil
+}
+
+// validate reports every problem with the configuration of the provider at path.
+func (pc ProviderConfig) validate(path string) error {
+	var errs []error
+	fieldErr := func(field string, err error) {
+		errs = append(errs, &FieldError{Path: path + "." + field, Err: err})
+	}
+
+	switch _, ok := providerSites[pc.Type]; {
+	case pc.Type == "":
+		fieldErr("type", errors.New("required"))
+	case !ok:
+		fieldErr("type", fmt.Errorf("unknown provider %q", pc.Type))
+	}
+	if _, err := resolveSecret(pc.APIKey); err != nil {
+		fieldErr("api_key", err)
+	}
+	if pc.Weight < 0 {
+		fieldErr("weight", fmt.Errorf("must not be negative, got %d", pc.Weight))
+	}
+	if pc.Cost < 0 {
+		fieldErr("cost", fmt.Errorf("must not be negative, got %v", pc.Cost))
+	}
+	return errors.Join(errs...)
+}
+
+// harvester creates the harvester of the provider for the captcha of sc. The
+// configuration must be valid.
+func (pc ProviderConfig) harvester(sc SiteConfig) (captchatoolsgo.Harvester, error) {
+	apiKey, err := resolveSecret(pc.APIKey)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &captchatoolsgo.Config{
+		Api_key:            apiKey,
+		Sitekey:            sc.SiteKey,
+		CaptchaURL:         sc.URL,
+		Action:             sc.Action,
+		IsInvisibleCaptcha: sc.Invisible,
+		MinScore:           sc.MinScore,
+	}
+	captchaTypes[sc.Type](cfg)
+	return newHarvester(pc.Type, cfg)
+}
+
+// resolveSecret returns the secret a reference points to: "env:NAME" is read from the
+// NAME environment variable and "file:PATH" from the file at PATH. Other values are the
+// secret itself.
+func resolveSecret(ref string) (string, error) {
+	var secret string
+	switch {
+	case strings.HasPrefix(ref, "env:"):
+		name := strings.TrimPrefix(ref, "env:")
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		secret = value
+	case strings.HasPrefix(ref, "file:"):
+		data, err := os.ReadFile(strings.TrimPrefix(ref, "file:"))
+		if err != nil {
+			return "", err
+		}
+		secret = strings.TrimSpace(string(data))
+	default:
+		secret = ref
+	}
+	if secret == "" {
+		return "", errors.New("required")
+	}
+	return secret, nil
+}
+
+// captchaTypes sets the captcha type of a harvester's config, by the name used in
+// configuration files.
+var captchaTypes = map[string]func(*captchatoolsgo.Config){
+	"v2":        func(c *captchatoolsgo.Config) { c.CaptchaType = captchatoolsgo.V2Captcha },
+	"v3":        func(c *captchatoolsgo.Config) { c.CaptchaType = captchatoolsgo.V3Captcha },
+	"hcaptcha":  func(c *captchatoolsgo.Config) { c.CaptchaType = captchatoolsgo.HCaptcha },
+	"image":     func(c *captchatoolsgo.Config) { c.CaptchaType = captchatoolsgo.ImageCaptcha },
+	"turnstile": func(c *captchatoolsgo.Config) { c.CaptchaType = captchatoolsgo.CFTurnstile },
+}
+
+// providerSites creates a harvester of a provider, by the name used in configuration files.
+var providerSites = map[string]func(*captchatoolsgo.Config) (captchatoolsgo.Harvester, error){
+	"anticaptcha": func(c *captchatoolsgo.Config) (captchatoolsgo.Harvester, error) {
+		return captchatoolsgo.NewHarvester(captchatoolsgo.AnticaptchaSite, c)
+	},
+	"capmonster": func(c *captchatoolsgo.Config) (captchatoolsgo.Harvester, error) {
+		return captchatoolsgo.NewHarvester(captchatoolsgo.CapmonsterSite, c)
+	},
+	"2captcha": func(c *captchatoolsgo.Config) (captchatoolsgo.Harvester, error) {
+		return captchatoolsgo.NewHarvester(captchatoolsgo.TwoCaptchaSite, c)
+	},
+	"capsolver": func(c *captchatoolsgo.Config) (captchatoolsgo.Harvester, error) {
+		return captchatoolsgo.NewHarvester(captchatoolsgo.CapsolverSite, c)
+	},
+	"captchaai": func(c *captchatoolsgo.Config) (captchatoolsgo.Harvester, error) {
+		return captchatoolsgo.NewHarvester(captchatoolsgo.CaptchaAISite, c)
+	},
+}
+
+// newHarvester creates a harvester of the named provider. Tests replace it to avoid
+// creating real harvesters.
+var newHarvester = func(provider string, cfg *captchatoolsgo.Config) (captchatoolsgo.Harvester, error) {
+	return providerSites[provider](cfg)
+}
+
+// strategies creates a Strategy, by the name used in configuration files. Only
+// priority-failover uses the number of failures to fail over after.
+var strategies = map[string]func(failoverAfter int) Strategy{
+	"":                  func(int) Strategy { return RoundRobin() },
+	"round-robin":       func(int) Strategy { return RoundRobin() },
+	"priority-failover": PriorityFailover,
+	"weighted-random":   func(int) Strategy { return WeightedRandom() },
+	"lowest-cost":       func(int) Strategy { return LowestCost() },
+	"lowest-latency":    func(int) Strategy { return LowestLatency() },
+}
@@ -0,0 +1,340 @@
+package captchasolve
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	captchatoolsgo "github.com/Matthew17-21/Captcha-Tools/captchatools-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const yamlConfig = `
+logging:
+  level: warn
+sites:
+  retailer:
+    type: v2
+    site_key: key
+    url: https://retailer.example
+    capacity: 10
+    prefill: {min: 1, max: 3}
+    validity: 90s
+    strategy: priority-failover
+    providers:
+      - type: capmonster
+        api_key: capmonster-key
+        priority: 1
+        cost: 0.8
+      - type: 2captcha
+        api_key: env:TEST_2CAPTCHA_KEY
+        weight: 2
+`
+
+const jsonConfig = `{
+  "logging": {"level": "warn"},
+  "sites": {
+    "retailer": {
+      "type": "v2",
+      "site_key": "key",
+      "url": "https://retailer.example",
+      "capacity": 10,
+      "prefill": {"min": 1, "max": 3},
+      "validity": "90s",
+      "strategy": "priority-failover",
+      "providers": [
+        {"type": "capmonster", "api_key": "capmonster-key", "priority": 1, "cost": 0.8},
+        {"type": "2captcha", "api_key": "env:TEST_2CAPTCHA_KEY", "weight": 2}
+      ]
+    }
+  }
+}`
+
+const tomlConfig = `
+[logging]
+level = "warn"
+
+[sites.retailer]
+type = "v2"
+site_key = "key"
+url = "https://retailer.example"
+capacity = 10
+prefill = {min = 1, max = 3}
+validity = "90s"
+strategy = "priority-failover"
+
+[[sites.retailer.providers]]
+type = "capmonster"
+api_key = "capmonster-key"
+priority = 1
+cost = 0.8
+
+[[sites.retailer.providers]]
+type = "2captcha"
+api_key = "env:TEST_2CAPTCHA_KEY"
+weight = 2
+`
+
+// fakeProviders makes configuration files create fake harvesters, recording the config
+// of every harvester created.
+func fakeProviders(t *testing.T) *[]*captchatoolsgo.Config {
+	t.Helper()
+	var configs []*captchatoolsgo.Config
+	original := newHarvester
+	newHarvester = func(_ string, cfg *captchatoolsgo.Config) (captchatoolsgo.Harvester, error) {
+		configs = append(configs, cfg)
+		return &fakeHarvester{}, nil
+	}
+	t.Cleanup(func() { newHarvester = original })
+	return &configs
+}
+
+// writeConfig writes a configuration file with the given name and contents to a temporary
+// directory, returning its path.
+func writeConfig(t *testing.T, name, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(contents), 0o600))
+	return path
+}
+
+func TestParseConfig(t *testing.T) {
+	t.Setenv("TEST_2CAPTCHA_KEY", "2captcha-key")
+	expected := &FileConfig{
+		Logging: LoggingConfig{Level: "warn"},
+		Sites: map[string]SiteConfig{
+			"retailer": {
+				Type:     "v2",
+				SiteKey:  "key",
+				URL:      "https://retailer.example",
+				Capacity: 10,
+				Prefill:  PrefillConfig{Min: 1, Max: 3},
+				Validity: Duration(90 * time.Second),
+				Strategy: "priority-failover",
+				Providers: []ProviderConfig{
+					{Type: "capmonster", APIKey: "capmonster-key", Priority: 1, Cost: 0.8},
+					{Type: "2captcha", APIKey: "env:TEST_2CAPTCHA_KEY", Weight: 2},
+				},
+			},
+		},
+	}
+
+	for format, data := range map[string]string{"yaml": yamlConfig, "json": jsonConfig, "toml": tomlConfig} {
+		t.Run(format, func(t *testing.T) {
+			// Act
+			fc, err := ParseConfig([]byte(data), format)
+
+			// Assert
+			require.NoError(t, err)
+			require.Equal(t, expected, fc)
+		})
+	}
+
+	t.Run("reports unknown fields", func(t *testing.T) {
+		for format, data := range map[string]string{
+			"yaml": "sites:\n  retailer:\n    capcity: 10\n",
+			"json": `{"sites": {"retailer": {"capcity": 10}}}`,
+			"toml": "[sites.retailer]\ncapcity = 10\n",
+		} {
+			_, err := ParseConfig([]byte(data), format)
+			require.ErrorContains(t, err, "capcity", format)
+		}
+	})
+
+	t.Run("reports unsupported formats", func(t *testing.T) {
+		_, err := ParseConfig([]byte(yamlConfig), "ini")
+
+		require.ErrorContains(t, err, `unsupported config format "ini"`)
+	})
+}
+
+func TestFileConfig_Validate(t *testing.T) {
+	t.Run("reports every problem with its path", func(t *testing.T) {
+		// Arrange
+		fc := &FileConfig{
+			Logging: LoggingConfig{Level: "loud"},
+			Sites: map[string]SiteConfig{
+				"retailer": {
+					Type:      "v4",
+					Capacity:  2,
+					Prefill:   PrefillConfig{Min: 1, Max: 3},
+					Strategy:  "cheapest",
+					Providers: []ProviderConfig{{Type: "capmonster"}, {Type: "deathbycaptcha", APIKey: "key", Cost: -1}},
+				},
+				"shop": {Type: "image"},
+			},
+		}
+
+		// Act
+		err := fc.Validate()
+
+		// Assert
+		paths := fieldPaths(t, err)
+		assert.ElementsMatch(t, []string{
+			"logging.level",
+			"sites.retailer.type",
+			"sites.retailer.site_key",
+			"sites.retailer.url",
+			"sites.retailer.prefill.max",
+			"sites.retailer.strategy",
+			"sites.retailer.providers[0].api_key",
+			"sites.retailer.providers[1].type",
+			"sites.retailer.providers[1].cost",
+			"sites.shop.providers",
+		}, paths)
+		require.ErrorContains(t, err, `sites.retailer.type: unknown captcha type "v4"`)
+	})
+
+	t.Run("rejects token files shared by sites", func(t *testing.T) {
+		site := SiteConfig{
+			Type:      "v2",
+			SiteKey:   "key",
+			URL:       "https://example.com",
+			TokenFile: "tokens.jsonl",
+			Providers: []ProviderConfig{{Type: "capmonster", APIKey: "key"}},
+		}
+		other := site
+		other.TokenFile = "./tokens.jsonl"
+
+		err := (&FileConfig{Sites: map[string]SiteConfig{"a": site, "b": other}}).Validate()
+
+		require.ErrorContains(t, err, "token_file: already used by site")
+	})
+
+	t.Run("requires sites", func(t *testing.T) {
+		err := (&FileConfig{}).Validate()
+
+		var fieldErr *FieldError
+		require.ErrorAs(t, err, &fieldErr)
+		require.Equal(t, "sites", fieldErr.Path)
+	})
+}
+
+// fieldPaths returns the paths of the *FieldError values held by err.
+func fieldPaths(t *testing.T, err error) []string {
+	t.Helper()
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		var paths []string
+		for _, err := range joined.Unwrap() {
+			paths = append(paths, fieldPaths(t, err)...)
+		}
+		return paths
+	}
+	var fieldErr *FieldError
+	require.ErrorAs(t, err, &fieldErr)
+	return []string{fieldErr.Path}
+}
+
+func TestResolveSecret(t *testing.T) {
+	t.Setenv("TEST_SECRET", "from-env")
+	secretFile := writeConfig(t, "secret", "from-file\n")
+
+	tests := []struct {
+		ref       string
+		expected  string
+		expectErr string
+	}{
+		{ref: "literal", expected: "literal"},
+		{ref: "env:TEST_SECRET", expected: "from-env"},
+		{ref: "file:" + secretFile, expected: "from-file"},
+		{ref: "env:TEST_MISSING_SECRET", expectErr: "environment variable TEST_MISSING_SECRET is not set"},
+		{ref: "file:" + secretFile + ".missing", expectErr: "no such file"},
+		{ref: "", expectErr: "required"},
+	}
+	for _, tt := range tests {
+		secret, err := resolveSecret(tt.ref)
+		if tt.expectErr != "" {
+			require.ErrorContains(t, err, tt.expectErr, tt.ref)
+			continue
+		}
+		require.NoError(t, err, tt.ref)
+		require.Equal(t, tt.expected, secret, tt.ref)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	t.Run("builds a solver per site", func(t *testing.T) {
+		// Arrange
+		t.Setenv("TEST_2CAPTCHA_KEY", "2captcha-key")
+		configs := fakeProviders(t)
+		path := writeConfig(t, "captchasolve.yml", yamlConfig)
+
+		// Act
+		registry, err := LoadConfig(path, WithSolveBudget(5))
+		require.NoError(t, err)
+		defer registry.Close()
+
+		// Assert
+		require.Equal(t, []string{"retailer"}, registry.IDs())
+		site, _ := registry.Site("retailer")
+		require.Equal(t, Site{CaptchaType: "v2", SiteKey: "key", URL: "https://retailer.example"}, site)
+
+		require.Len(t, *configs, 2)
+		assert.Equal(t, "capmonster-key", (*configs)[0].Api_key)
+		assert.Equal(t, "2captcha-key", (*configs)[1].Api_key)
+		assert.Equal(t, captchatoolsgo.V2Captcha, (*configs)[0].CaptchaType)
+		assert.Equal(t, "https://retailer.example", (*configs)[0].CaptchaURL)
+
+		solver, _ := registry.Solver("retailer")
+		cfg := solver.(*captchasolve).config
+		assert.Equal(t, 10, cfg.maxCapacity)
+		assert.Equal(t, 1, cfg.prefillMin)
+		assert.Equal(t, 3, cfg.prefillMax)
+		assert.Equal(t, 90*time.Second, cfg.tokenValidity)
+		assert.Equal(t, 5, cfg.solveBudget, "options are applied after the file's settings")
+		assert.Equal(t, PriorityFailover(defaultFailoverAfter), cfg.strategy)
+		assert.Equal(t, []harvesterSettings{{priority: 1, cost: 0.8}, {weight: 2}}, cfg.harvesterSettings)
+
+		token, err := registry.GetToken(context.Background(), "retailer")
+		require.NoError(t, err)
+		require.Equal(t, "fake-token", token.Token)
+	})
+
+	t.Run("reports the file with its problems", func(t *testing.T) {
+		// Arrange
+		fakeProviders(t)
+		path := writeConfig(t, "captchasolve.json", `{"sites": {"retailer": {"type": "v2"}}}`)
+
+		// Act
+		registry, err := LoadConfig(path)
+
+		// Assert
+		require.Nil(t, registry)
+		require.ErrorContains(t, err, path)
+		require.ErrorContains(t, err, "sites.retailer.site_key: required")
+		require.ErrorContains(t, err, "sites.retailer.providers: no providers configured")
+	})
+
+	t.Run("reports missing files", func(t *testing.T) {
+		_, err := LoadConfig(filepath.Join(t.TempDir(), "missing.toml"))
+
+		require.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
+
+func TestSiteConfig_Harvesters(t *testing.T) {
+	t.Run("creates the harvesters in order", func(t *testing.T) {
+		// Arrange
+		configs := fakeProviders(t)
+		sc := siteConfig("key", "capmonster-key")
+		sc.Providers = append(sc.Providers, ProviderConfig{Type: "2captcha", APIKey: "2captcha-key"})
+
+		// Act
+		harvesters, err := sc.Harvesters("retailer")
+
+		// Assert
+		require.NoError(t, err)
+		require.Len(t, harvesters, 2)
+		assert.Equal(t, "capmonster-key", (*configs)[0].Api_key)
+		assert.Equal(t, "2captcha-key", (*configs)[1].Api_key)
+	})
+
+	t.Run("reports the provider that failed", func(t *testing.T) {
+		_, err := siteConfig("key", "env:TEST_MISSING_KEY").Harvesters("retailer")
+
+		require.ErrorContains(t, err, "sites.retailer.providers[0]: environment variable TEST_MISSING_KEY is not set")
+	})
+}
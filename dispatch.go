@@ -0,0 +1,131 @@
+package captchasolve
+
+import (
+	"context"
+	"slices"
+
+	captchatoolsgo "github.com/Matthew17-21/Captcha-Tools/captchatools-go"
+)
+
+// demand returns how many new solves for tokens with the given fingerprint are needed
+// so that every caller waiting for one is covered by an in-flight solve, plus the
+// configured surplus, or to top the pool up when prefilling is enabled. Prefilling only
+// applies to tokens solved without AdditionalData. c.mu must be held.
+func (c *captchasolve) demand(fingerprint string) int {
+	n := c.waitersFor(fingerprint) + c.surplus - c.inFlightFor(fingerprint)
+	if fingerprint == "" {
+		n = max(n, c.prefillDemand())
+	}
+	return n
+}
+
+// dispatch launches as many new solves as there are unsatisfied waiters (plus the
+// configured surplus or prefill target), instead of launching every harvester for
+// every caller.
+//
+// Solves are detached from the cancellation of ctx and only cancelled when the instance
+// shuts down: a solve covers whichever caller is waiting longest when it finishes, so
+// one caller giving up must not cancel a solve other callers are counting on. Tokens
+// nobody is waiting for go to the queue.
+func (c *captchasolve) dispatch(ctx context.Context, additional ...*captchatoolsgo.AdditionalData) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.startSolvesLocked(ctx, c.demand(Fingerprint(additional...)), additional...)
+}
+
+// startSolvesLocked launches n new solves in the background. c.mu must be held.
+func (c *captchasolve) startSolvesLocked(ctx context.Context, n int, additional ...*captchatoolsgo.AdditionalData) {
+	if c.closed || n <= 0 {
+		return
+	}
+	if len(c.harvesters) == 0 {
+		WithFields(c.logger, "error", ErrNoHarvesters).Error("Can't start any solves")
+		return
+	}
+	c.addInFlightLocked(Fingerprint(additional...), n)
+	c.reportPoolLocked()
+	c.solves.Add(1)
+
+	ctx, cancel := c.detach(ctx)
+	go func() {
+		defer c.solves.Done()
+		defer cancel()
+		c.startHarvesters(ctx, n, additional...)
+	}()
+}
+
+// spendBudget takes one solve from the budget set with WithSolveBudget, reporting false
+// once the budget has been spent.
+func (c *captchasolve) spendBudget() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.solveBudget <= 0 {
+		return true
+	}
+	if c.solvesSpent >= c.solveBudget {
+		return false
+	}
+	c.solvesSpent++
+	return true
+}
+
+// acquire blocks until one of the maxGoroutines solve slots is free, or returns
+// ctx.Err() if ctx is done first. The returned function frees the slot.
+func (c *captchasolve) acquire(ctx context.Context) (release func(), err error) {
+	c.mu.Lock()
+	if c.sem == nil {
+		c.sem = make(chan struct{}, max(c.maxGoroutines, 1))
+	}
+	sem := c.sem // Update may replace it while we wait
+	c.mu.Unlock()
+	select {
+	case sem <- struct{}{}:
+		return func() { <-sem }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// nextHarvester returns the harvester, and its index, picked by the configured Strategy
+// for the next solve. Quarantined harvesters, and those at the excluded indexes, are
+// skipped; false is returned if no harvester is left.
+func (c *captchasolve) nextHarvester(exclude ...int) (int, captchatoolsgo.Harvester, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	candidates := c.candidatesLocked(exclude)
+	if len(candidates) == 0 {
+		return 0, nil, false
+	}
+
+	pick := 0
+	if c.strategy != nil {
+		pick = c.strategy.Pick(candidates)
+	}
+	if pick < 0 || pick >= len(candidates) {
+		c.logger.Warn("Strategy picked candidate %d out of %d, using the first one", pick, len(candidates))
+		pick = 0
+	}
+	return candidates[pick].Index, candidates[pick].Harvester, true
+}
+
+// candidatesLocked describes the harvesters that aren't quarantined or excluded. c.mu
+// must be held.
+func (c *captchasolve) candidatesLocked(exclude []int) []Candidate {
+	candidates := make([]Candidate, 0, len(c.harvesters))
+	for i, h := range c.harvesters {
+		if c.quarantinedLocked(i) || slices.Contains(exclude, i) {
+			continue
+		}
+		cand := Candidate{Index: i, Harvester: h}
+		if i < len(c.harvesterSettings) {
+			s := c.harvesterSettings[i]
+			cand.Priority, cand.Weight, cand.Cost = s.priority, s.weight, s.cost
+		}
+		if health, ok := c.health[i]; ok {
+			cand.Latency, cand.Failures = health.latency, health.failures
+		}
+		cand.Stats = c.statsLocked(i)
+		candidates = append(candidates, cand)
+	}
+	return candidates
+}
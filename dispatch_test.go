@@ -0,0 +1,128 @@
+package captchasolve
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	captchatoolsgo "github.com/Matthew17-21/Captcha-Tools/captchatools-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeHarvester is a captchatoolsgo.Harvester that counts its calls and returns a token
+// after an optional delay, or err if set.
+type fakeHarvester struct {
+	calls atomic.Int32
+	delay time.Duration
+	err   error
+}
+
+func (f *fakeHarvester) GetToken(additional ...*captchatoolsgo.AdditionalData) (*captchatoolsgo.CaptchaAnswer, error) {
+	return f.GetTokenWithContext(context.Background(), additional...)
+}
+
+func (f *fakeHarvester) GetTokenWithContext(ctx context.Context, _ ...*captchatoolsgo.AdditionalData) (*captchatoolsgo.CaptchaAnswer, error) {
+	f.calls.Add(1)
+	select {
+	case <-time.After(f.delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &captchatoolsgo.CaptchaAnswer{Token: "fake-token"}, nil
+}
+
+func (f *fakeHarvester) GetBalance() (float32, error) { return 1, nil }
+
+func TestDispatch_OneSolvePerWaiter(t *testing.T) {
+	// Arrange
+	h1 := &fakeHarvester{delay: 50 * time.Millisecond}
+	h2 := &fakeHarvester{delay: 50 * time.Millisecond}
+	solver := New(WithHarvester(h1), WithHarvester(h2)).(*captchasolve)
+	defer solver.Close()
+	const numCallers = 20
+
+	// Act
+	var wg sync.WaitGroup
+	for i := 0; i < numCallers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			token, err := solver.GetToken(context.Background())
+			assert.NoError(t, err)
+			assert.NotNil(t, token)
+		}()
+	}
+	wg.Wait()
+
+	// Assert
+	require.EqualValues(t, numCallers, h1.calls.Load()+h2.calls.Load())
+	require.Equal(t, h1.calls.Load(), h2.calls.Load(), "solves should be spread across harvesters")
+	require.Zero(t, solver.queue.Len())
+}
+
+func TestDispatch_Surplus(t *testing.T) {
+	// Arrange
+	h := &fakeHarvester{}
+	solver := New(WithHarvester(h), WithSurplus(2)).(*captchasolve)
+	defer solver.Close()
+
+	// Act
+	token, err := solver.GetToken(context.Background())
+
+	// Assert
+	require.NoError(t, err)
+	require.NotNil(t, token)
+	require.Eventually(t, func() bool { return solver.queue.Len() == 2 }, time.Second, time.Millisecond)
+	require.EqualValues(t, 3, h.calls.Load())
+}
+
+func TestDispatch_CoveredWaitersDontLaunchSolves(t *testing.T) {
+	// Arrange
+	h := &fakeHarvester{}
+	solver := New(WithHarvester(h)).(*captchasolve)
+	defer solver.Close()
+	solver.addWaiter(newWaiter(""))
+	solver.inFlight = 1
+
+	// Act
+	solver.dispatch(context.Background())
+
+	// Assert
+	require.Zero(t, h.calls.Load())
+	require.Equal(t, 1, solver.inFlight)
+}
+
+func TestDispatch_NoHarvesters(t *testing.T) {
+	// Arrange
+	solver := New().(*captchasolve)
+	solver.addWaiter(newWaiter(""))
+
+	// Act
+	solver.dispatch(context.Background())
+
+	// Assert
+	require.Zero(t, solver.inFlight)
+}
+
+func TestDispatch_SolveBudget(t *testing.T) {
+	// Arrange
+	h := &fakeHarvester{}
+	solver := New(WithHarvester(h), WithSolveBudget(2)).(*captchasolve)
+	defer solver.Close()
+
+	// Act
+	solver.mu.Lock()
+	solver.inFlight = 3
+	solver.mu.Unlock()
+	solver.startHarvesters(context.Background(), 3)
+
+	// Assert
+	require.EqualValues(t, 2, h.calls.Load())
+	require.False(t, solver.spendBudget(), "budget should be spent")
+}
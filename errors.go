@@ -0,0 +1,164 @@
+package captchasolve
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrNoHarvesters is returned when a token is requested from a CaptchaSolve that has
+	// no harvesters configured.
+	ErrNoHarvesters = errors.New("captchasolve: no harvesters configured")
+
+	// ErrAllHarvestersFailed is matched by the *RoundError returned when every solve of
+	// a round failed.
+	ErrAllHarvestersFailed = errors.New("captchasolve: every harvester failed")
+
+	// ErrAllHarvestersQuarantined is returned when every configured harvester has been
+	// quarantined, for example because every API key is out of funds.
+	ErrAllHarvestersQuarantined = errors.New("captchasolve: every harvester is quarantined")
+
+	// ErrPoolClosed is returned when a CaptchaSolve is used after it has been shut down.
+	ErrPoolClosed = errors.New("captchasolve: pool is closed")
+
+	// ErrBudgetExceeded is returned once the solve budget set with WithSolveBudget has
+	// been spent.
+	ErrBudgetExceeded = errors.New("captchasolve: solve budget exceeded")
+
+	// ErrUnknownSite is returned when a token is requested from a Registry for a site
+	// that isn't registered.
+	ErrUnknownSite = errors.New("captchasolve: unknown site")
+
+	// ErrSiteExists is returned when registering a site with a Registry under an ID, or
+	// for a Site, that is already registered.
+	ErrSiteExists = errors.New("captchasolve: site already registered")
+
+	// ErrTokenRejected is recorded against a harvester whose token was reported with
+	// ReportBadToken, and is classified as ErrorClassRejected.
+	ErrTokenRejected = errors.New("captchasolve: token was rejected by the site")
+
+	// ErrUnknownToken is returned when reporting a token that wasn't harvested by a
+	// CaptchaSolve.
+	ErrUnknownToken = errors.New("captchasolve: token wasn't harvested by a solver")
+
+	// errNilToken is reported when a harvester returns neither a token nor an error.
+	errNilToken = errors.New("captchasolve: harvester returned a nil token")
+)
+
+// HarvesterError is returned when a harvester fails to solve a captcha. It wraps the
+// error returned by the harvester.
+type HarvesterError struct {
+	Index    int    // Position of the harvester in the order it was configured
+	Provider string // Type of the harvester
+	Attempts int    // Number of calls made to the harvester, retries included
+	Err      error  // Error returned by the harvester on the last attempt
+}
+
+func (e *HarvesterError) Error() string {
+	return fmt.Sprintf("captchasolve: harvester #%d (%s): %v", e.Index+1, e.Provider, e.Err)
+}
+
+func (e *HarvesterError) Unwrap() error { return e.Err }
+
+// Class returns the class of the error returned by the harvester.
+func (e *HarvesterError) Class() ErrorClass { return ClassifyError(e.Err) }
+
+// RoundError is returned when every solve of a round failed. It holds the error of every
+// solve, which can be inspected with errors.Is and errors.As, and matches
+// ErrAllHarvestersFailed.
+type RoundError struct {
+	Errors []error
+}
+
+func (e *RoundError) Error() string {
+	if len(e.Errors) == 0 {
+		return ErrAllHarvestersFailed.Error()
+	}
+	return fmt.Sprintf("%v: %v", ErrAllHarvestersFailed, errors.Join(e.Errors...))
+}
+
+func (e *RoundError) Is(target error) bool { return target == ErrAllHarvestersFailed }
+
+func (e *RoundError) Unwrap() []error { return e.Errors }
+
+// ErrorClass categorizes the errors returned by captcha providers.
+type ErrorClass int
+
+const (
+	// ErrorClassUnknown is any error that doesn't fall in one of the other classes.
+	ErrorClassUnknown ErrorClass = iota
+
+	// ErrorClassNoBalance means the API key is out of funds.
+	ErrorClassNoBalance
+
+	// ErrorClassInvalidKey means the API key is wrong or has been disabled.
+	ErrorClassInvalidKey
+
+	// ErrorClassBanned means the provider has banned the IP address or the account.
+	ErrorClassBanned
+
+	// ErrorClassUnsolvable means the provider's workers couldn't solve the captcha.
+	ErrorClassUnsolvable
+
+	// ErrorClassTimeout means the solve timed out or was cancelled.
+	ErrorClassTimeout
+
+	// ErrorClassRejected means a token returned by the provider was rejected by the site,
+	// as reported with ReportBadToken.
+	ErrorClassRejected
+)
+
+func (e ErrorClass) String() string {
+	switch e {
+	case ErrorClassNoBalance:
+		return "no_balance"
+	case ErrorClassInvalidKey:
+		return "invalid_key"
+	case ErrorClassBanned:
+		return "banned"
+	case ErrorClassUnsolvable:
+		return "unsolvable"
+	case ErrorClassTimeout:
+		return "timeout"
+	case ErrorClassRejected:
+		return "rejected"
+	default:
+		return "unknown"
+	}
+}
+
+// errorPatterns maps the error codes and messages reported by the supported providers
+// to the class of error they belong to.
+var errorPatterns = []struct {
+	class    ErrorClass
+	patterns []string
+}{
+	{ErrorClassNoBalance, []string{"zero_balance", "no balance", "insufficient", "out of funds"}},
+	{ErrorClassInvalidKey, []string{"key_does_not_exist", "wrong_user_key", "wrong api key", "incorrect api key", "invalid api key", "account_suspended"}},
+	{ErrorClassBanned, []string{"ip_banned", "ip_not_allowed", "ip_blocked", "banned"}},
+	{ErrorClassUnsolvable, []string{"captcha_unsolvable", "unsolvable"}},
+}
+
+// ClassifyError returns the class of an error returned by a harvester.
+func ClassifyError(err error) ErrorClass {
+	if err == nil {
+		return ErrorClassUnknown
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return ErrorClassTimeout
+	}
+	if errors.Is(err, ErrTokenRejected) {
+		return ErrorClassRejected
+	}
+	msg := strings.ToLower(err.Error())
+	for _, p := range errorPatterns {
+		for _, pattern := range p.patterns {
+			if strings.Contains(msg, pattern) {
+				return p.class
+			}
+		}
+	}
+	return ErrorClassUnknown
+}
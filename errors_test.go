@@ -0,0 +1,74 @@
+package captchasolve
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected ErrorClass
+	}{
+		{err: nil, expected: ErrorClassUnknown},
+		{err: errors.New("something went wrong"), expected: ErrorClassUnknown},
+		{err: errors.New("ERROR_ZERO_BALANCE"), expected: ErrorClassNoBalance},
+		{err: errors.New("no balance"), expected: ErrorClassNoBalance},
+		{err: errors.New("ERROR_KEY_DOES_NOT_EXIST"), expected: ErrorClassInvalidKey},
+		{err: errors.New("ERROR_WRONG_USER_KEY"), expected: ErrorClassInvalidKey},
+		{err: errors.New("ERROR_IP_BANNED"), expected: ErrorClassBanned},
+		{err: errors.New("ERROR_CAPTCHA_UNSOLVABLE"), expected: ErrorClassUnsolvable},
+		{err: fmt.Errorf("error getting token: %w", errors.New("ERROR_ZERO_BALANCE")), expected: ErrorClassNoBalance},
+		{err: context.DeadlineExceeded, expected: ErrorClassTimeout},
+		{err: fmt.Errorf("wrapped: %w", context.Canceled), expected: ErrorClassTimeout},
+		{err: ErrTokenRejected, expected: ErrorClassRejected},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.err), func(t *testing.T) {
+			require.Equal(t, tt.expected, ClassifyError(tt.err))
+		})
+	}
+}
+
+func TestErrorClass_String(t *testing.T) {
+	require.Equal(t, "no_balance", ErrorClassNoBalance.String())
+	require.Equal(t, "unknown", ErrorClass(100).String())
+}
+
+func TestHarvesterError(t *testing.T) {
+	// Arrange
+	providerErr := errors.New("ERROR_ZERO_BALANCE")
+	err := error(&HarvesterError{Index: 1, Provider: "*captchasolve.fakeHarvester", Err: providerErr})
+
+	// Assert
+	require.ErrorIs(t, err, providerErr)
+	require.Equal(t, "captchasolve: harvester #2 (*captchasolve.fakeHarvester): ERROR_ZERO_BALANCE", err.Error())
+
+	var harvesterErr *HarvesterError
+	require.ErrorAs(t, fmt.Errorf("wrapped: %w", err), &harvesterErr)
+	require.Equal(t, 1, harvesterErr.Index)
+	require.Equal(t, ErrorClassNoBalance, harvesterErr.Class())
+}
+
+func TestRoundError(t *testing.T) {
+	// Arrange
+	timeoutErr := &HarvesterError{Index: 0, Provider: "a", Err: context.DeadlineExceeded}
+	fundsErr := &HarvesterError{Index: 1, Provider: "b", Err: errors.New("ERROR_ZERO_BALANCE")}
+	err := error(&RoundError{Errors: []error{timeoutErr, fundsErr, ErrBudgetExceeded}})
+
+	// Assert
+	require.ErrorIs(t, err, ErrAllHarvestersFailed)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.ErrorIs(t, err, ErrBudgetExceeded)
+	require.NotErrorIs(t, err, ErrPoolClosed)
+
+	var harvesterErr *HarvesterError
+	require.ErrorAs(t, err, &harvesterErr)
+	require.Equal(t, timeoutErr, harvesterErr)
+	require.Contains(t, err.Error(), "ERROR_ZERO_BALANCE")
+}
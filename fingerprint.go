@@ -0,0 +1,184 @@
+package captchasolve
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	captchatoolsgo "github.com/Matthew17-21/Captcha-Tools/captchatools-go"
+)
+
+// Fingerprint returns the fingerprint of the AdditionalData, such as the proxy, user agent
+// and rqdata, a token is solved with. Tokens are only handed to callers requesting them
+// with AdditionalData of the same fingerprint, so a token solved through one proxy is
+// never used with another.
+//
+// The fingerprint is empty when no AdditionalData is given, or only nil or empty ones.
+func Fingerprint(additional ...*captchatoolsgo.AdditionalData) string {
+	data := make([]*captchatoolsgo.AdditionalData, 0, len(additional))
+	for _, d := range additional {
+		if d != nil && !isEmptyAdditionalData(d) {
+			data = append(data, d)
+		}
+	}
+	if len(data) == 0 {
+		return ""
+	}
+
+	// Encoding every field rather than picking known ones keeps the fingerprint
+	// accurate should new fields be added to AdditionalData
+	b, err := json.Marshal(data)
+	if err != nil {
+		b = []byte(fmt.Sprintf("%#v", data))
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// emptyAdditionalData is the encoding of AdditionalData without any field set.
+var emptyAdditionalData, _ = json.Marshal(captchatoolsgo.AdditionalData{})
+
+// isEmptyAdditionalData reports whether no field of d is set.
+func isEmptyAdditionalData(d *captchatoolsgo.AdditionalData) bool {
+	b, err := json.Marshal(d)
+	return err == nil && string(b) == string(emptyAdditionalData)
+}
+
+// queueFor returns the queue holding the tokens with the given fingerprint, or nil if
+// there are none. Tokens solved without AdditionalData are held in c.queue, the others
+// in a queue of their own. c.mu must be held.
+func (c *captchasolve) queueFor(fingerprint string) tokenQueue {
+	if fingerprint == "" {
+		return c.queue
+	}
+	if q, ok := c.keyed[fingerprint]; ok {
+		return q
+	}
+	return nil
+}
+
+// enqueueLocked adds a token to the queue of its fingerprint, creating the queue if
+// needed. The max capacity bounds the tokens held across every queue: once it is reached,
+// the oldest token of the queue is discarded to make room if that queue is full, and the
+// oldest queued token otherwise. c.mu must be held.
+func (c *captchasolve) enqueueLocked(token *CaptchaAnswer) error {
+	if c.maxCapacity > 0 {
+		n := 0
+		if q := c.queueFor(token.fingerprint); q != nil && q.Len() >= c.maxCapacity {
+			if _, err := q.Dequeue(); err == nil {
+				n++
+			}
+		}
+		n += c.discardOldestLocked(c.queuedLocked() - c.maxCapacity + 1)
+		if n > 0 {
+			WithFields(c.logger, "count", n).Warn("Discarded the oldest tokens to make room in the pool")
+			c.reportDiscarded(n)
+		}
+	}
+
+	// Discarding tokens may have removed the queue of the token
+	q := c.queueFor(token.fingerprint)
+	if q == nil {
+		if c.keyed == nil {
+			c.keyed = make(map[string]tokenQueue)
+		}
+		q = c.newQueue()
+		c.keyed[token.fingerprint] = q
+	}
+	return q.Enqueue(token)
+}
+
+// forEachQueueLocked calls f with every queue, removing the keyed queues left empty
+// afterwards. c.mu must be held.
+func (c *captchasolve) forEachQueueLocked(f func(q tokenQueue)) {
+	f(c.queue)
+	for fingerprint, q := range c.keyed {
+		f(q)
+		if q.Len() == 0 {
+			delete(c.keyed, fingerprint)
+		}
+	}
+}
+
+// discardOldestLocked removes the n oldest queued tokens, whatever their fingerprint, and
+// returns the number of tokens removed. c.mu must be held.
+func (c *captchasolve) discardOldestLocked(n int) int {
+	removed := 0
+	for ; removed < n; removed++ {
+		// The oldest token is at the head of one of the queues
+		var oldest *CaptchaAnswer
+		var oldestQueue tokenQueue
+		oldestFingerprint := ""
+		check := func(fingerprint string, q tokenQueue) {
+			if tkn, err := q.Peek(); err == nil && (oldest == nil || tkn.solvedAt.Before(oldest.solvedAt)) {
+				oldest, oldestQueue, oldestFingerprint = tkn, q, fingerprint
+			}
+		}
+		check("", c.queue)
+		for fingerprint, q := range c.keyed {
+			check(fingerprint, q)
+		}
+		if oldest == nil {
+			break
+		}
+		if _, err := oldestQueue.Dequeue(); err != nil {
+			break
+		}
+		if oldestFingerprint != "" && oldestQueue.Len() == 0 {
+			delete(c.keyed, oldestFingerprint)
+		}
+	}
+	return removed
+}
+
+// queuedLocked returns the number of queued tokens, whatever their fingerprint. c.mu must
+// be held.
+func (c *captchasolve) queuedLocked() int {
+	n := c.queue.Len()
+	for _, q := range c.keyed {
+		n += q.Len()
+	}
+	return n
+}
+
+// waitersFor returns the number of callers waiting for a token with the given
+// fingerprint. c.mu must be held.
+func (c *captchasolve) waitersFor(fingerprint string) int {
+	n := 0
+	for _, w := range c.waiters {
+		if w.fingerprint == fingerprint {
+			n++
+		}
+	}
+	return n
+}
+
+// inFlightFor returns the number of in-flight solves for tokens with the given
+// fingerprint. c.mu must be held.
+func (c *captchasolve) inFlightFor(fingerprint string) int {
+	if fingerprint != "" {
+		return c.keyedInFlight[fingerprint]
+	}
+	n := c.inFlight
+	for _, keyed := range c.keyedInFlight {
+		n -= keyed
+	}
+	return n
+}
+
+// addInFlightLocked adds n, which may be negative, to the number of in-flight solves
+// for tokens with the given fingerprint. c.mu must be held.
+func (c *captchasolve) addInFlightLocked(fingerprint string, n int) {
+	c.inFlight += n
+	if fingerprint == "" {
+		return
+	}
+	if c.keyedInFlight == nil {
+		c.keyedInFlight = make(map[string]int)
+	}
+	c.keyedInFlight[fingerprint] += n
+	if c.keyedInFlight[fingerprint] == 0 {
+		delete(c.keyedInFlight, fingerprint)
+	}
+}
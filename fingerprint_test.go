@@ -0,0 +1,245 @@
+package captchasolve
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	captchatoolsgo "github.com/Matthew17-21/Captcha-Tools/captchatools-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFingerprint(t *testing.T) {
+	sessionA := &captchatoolsgo.AdditionalData{UserAgent: "ua-a", Proxy: &captchatoolsgo.Proxy{}}
+	sessionB := &captchatoolsgo.AdditionalData{UserAgent: "ua-b"}
+
+	t.Run("is empty without additional data", func(t *testing.T) {
+		assert.Empty(t, Fingerprint())
+		assert.Empty(t, Fingerprint(nil))
+		assert.Empty(t, Fingerprint(&captchatoolsgo.AdditionalData{}))
+	})
+
+	t.Run("is stable", func(t *testing.T) {
+		copyA := *sessionA
+		assert.Equal(t, Fingerprint(sessionA), Fingerprint(&copyA))
+		assert.Equal(t, Fingerprint(sessionA), Fingerprint(nil, sessionA), "nil data should be ignored")
+		assert.Len(t, Fingerprint(sessionA), 64)
+	})
+
+	t.Run("differs between sessions", func(t *testing.T) {
+		assert.NotEqual(t, Fingerprint(sessionA), Fingerprint(sessionB))
+		assert.NotEqual(t, Fingerprint(sessionB), Fingerprint(&captchatoolsgo.AdditionalData{UserAgent: "ua-b", RQData: "rq"}))
+	})
+}
+
+// enqueueKeyedToken adds a valid token solved with the given additional data to the pool.
+func enqueueKeyedToken(t *testing.T, c *captchasolve, token string, additional *captchatoolsgo.AdditionalData) {
+	t.Helper()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	tkn := &CaptchaAnswer{CaptchaAnswer: captchatoolsgo.CaptchaAnswer{Token: token}, solvedAt: time.Now(), fingerprint: Fingerprint(additional)}
+	require.NoError(t, c.enqueueLocked(tkn))
+}
+
+func TestGetToken_Fingerprint(t *testing.T) {
+	sessionA := &captchatoolsgo.AdditionalData{UserAgent: "ua-a"}
+	sessionB := &captchatoolsgo.AdditionalData{UserAgent: "ua-b"}
+
+	t.Run("only serves tokens of the same fingerprint", func(t *testing.T) {
+		// Arrange
+		c := New(WithHarvester(&fakeHarvester{delay: time.Hour})).(*captchasolve)
+		defer c.Close()
+		enqueueTokens(c, 1, 0)
+		enqueueKeyedToken(t, c, "token-a", sessionA)
+		enqueueKeyedToken(t, c, "token-b", sessionB)
+
+		// Act
+		tokenB, errB := c.GetToken(context.Background(), sessionB)
+		tokenA, errA := c.GetToken(context.Background(), sessionA)
+		token, err := c.GetToken(context.Background())
+
+		// Assert
+		require.NoError(t, errB)
+		require.NoError(t, errA)
+		require.NoError(t, err)
+		assert.Equal(t, "token-b", tokenB.Token)
+		assert.Equal(t, "token-a", tokenA.Token)
+		assert.Equal(t, "valid", token.Token)
+		assert.Empty(t, c.keyed, "empty keyed queues should be removed")
+	})
+
+	t.Run("doesn't serve unkeyed tokens to sessions", func(t *testing.T) {
+		// Arrange
+		c := New(WithHarvester(&fakeHarvester{})).(*captchasolve)
+		defer c.Close()
+		enqueueTokens(c, 1, 0)
+
+		// Act
+		token, err := c.GetToken(context.Background(), sessionA)
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, "fake-token", token.Token)
+		assert.Equal(t, Fingerprint(sessionA), token.Fingerprint())
+		assert.Equal(t, 1, c.queue.Len())
+	})
+
+	t.Run("hands tokens to waiters of the same fingerprint", func(t *testing.T) {
+		// Arrange
+		c := New(WithHarvester(&fakeHarvester{delay: time.Hour})).(*captchasolve)
+		defer c.Close()
+		results := make(chan *CaptchaAnswer, 1)
+		go c.GetToken(context.Background(), sessionA) // Waits until Close
+		waitForWaiters(t, c, 1)
+		go func() {
+			token, err := c.GetToken(context.Background(), sessionB)
+			assert.NoError(t, err)
+			results <- token
+		}()
+		waitForWaiters(t, c, 2)
+
+		// Act
+		c.deliver(&CaptchaAnswer{CaptchaAnswer: captchatoolsgo.CaptchaAnswer{Token: "token-b"}, fingerprint: Fingerprint(sessionB)})
+
+		// Assert
+		assert.Equal(t, "token-b", (<-results).Token, "the newer waiter of session B should get the token")
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		require.Len(t, c.waiters, 1)
+		assert.Equal(t, Fingerprint(sessionA), c.waiters[0].fingerprint)
+	})
+}
+
+func TestDemand_Fingerprint(t *testing.T) {
+	sessionA := &captchatoolsgo.AdditionalData{UserAgent: "ua-a"}
+	c := New(WithSurplus(1)).(*captchasolve)
+	c.addWaiter(newWaiter(""))
+	c.addWaiter(newWaiter(Fingerprint(sessionA)))
+	c.addWaiter(newWaiter(Fingerprint(sessionA)))
+	c.addInFlightLocked(Fingerprint(sessionA), 1)
+
+	assert.Equal(t, 2, c.demand(""), "solves for sessions shouldn't cover other waiters")
+	assert.Equal(t, 2, c.demand(Fingerprint(sessionA)))
+	assert.Equal(t, 0, c.inFlightFor(""))
+
+	c.addInFlightLocked(Fingerprint(sessionA), -1)
+	assert.Empty(t, c.keyedInFlight)
+	assert.Zero(t, c.inFlight)
+}
+
+func TestFailUncoveredWaiterLocked_Fingerprint(t *testing.T) {
+	sessionA := &captchatoolsgo.AdditionalData{UserAgent: "ua-a"}
+	c := New().(*captchasolve)
+	waiterA, unkeyed := newWaiter(Fingerprint(sessionA)), newWaiter("")
+	c.addWaiter(waiterA)
+	c.addWaiter(unkeyed)
+
+	c.failUncoveredWaiterLocked(Fingerprint(sessionA), ErrBudgetExceeded)
+
+	res := <-waiterA.ch
+	require.ErrorIs(t, res.err, ErrBudgetExceeded)
+	assert.Equal(t, []*waiter{unkeyed}, c.waiters)
+}
+
+func TestKeyedQueues(t *testing.T) {
+	sessionA := &captchatoolsgo.AdditionalData{UserAgent: "ua-a"}
+
+	t.Run("expired tokens are swept", func(t *testing.T) {
+		c := New().(*captchasolve)
+		c.mu.Lock()
+		c.enqueueLocked(&CaptchaAnswer{solvedAt: time.Now().Add(-time.Hour), fingerprint: Fingerprint(sessionA)})
+		c.mu.Unlock()
+
+		assert.Equal(t, 1, c.sweep(context.Background()))
+		assert.Empty(t, c.keyed)
+	})
+
+	t.Run("are cleared", func(t *testing.T) {
+		c := New().(*captchasolve)
+		enqueueTokens(c, 1, 0)
+		enqueueKeyedToken(t, c, "token-a", sessionA)
+
+		c.ClearTokens()
+
+		assert.Zero(t, c.queuedLocked())
+		assert.Empty(t, c.keyed)
+	})
+
+	t.Run("are bounded to the max capacity", func(t *testing.T) {
+		c := New(WithMaxCapacity(1)).(*captchasolve)
+		enqueueKeyedToken(t, c, "token-a", sessionA)
+		enqueueKeyedToken(t, c, "newer-a", sessionA)
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		q := c.keyed[Fingerprint(sessionA)]
+		require.NotNil(t, q, "the queue should be kept when its oldest token makes room")
+		require.Equal(t, 1, q.Len())
+		tkn, _ := q.Peek()
+		assert.Equal(t, "newer-a", tkn.Token)
+	})
+
+	t.Run("keep their queue when its last token is discarded", func(t *testing.T) {
+		c := New(WithMaxCapacity(2)).(*captchasolve)
+		enqueueKeyedToken(t, c, "token-a", sessionA)
+		enqueueTokens(c, 1, 0)
+		enqueueKeyedToken(t, c, "newer-a", sessionA)
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		assert.Equal(t, 2, c.queuedLocked())
+		require.NotNil(t, c.keyed[Fingerprint(sessionA)])
+		assert.Equal(t, 1, c.keyed[Fingerprint(sessionA)].Len())
+	})
+
+	t.Run("share the max capacity", func(t *testing.T) {
+		// Arrange
+		metrics := &recordingMetrics{}
+		c := New(WithMaxCapacity(2), WithMetrics(metrics)).(*captchasolve)
+		solvedAt := time.Now()
+
+		// Act
+		c.mu.Lock()
+		for i := 0; i < 50; i++ {
+			fingerprint := Fingerprint(&captchatoolsgo.AdditionalData{UserAgent: fmt.Sprintf("ua-%d", i)})
+			tkn := &CaptchaAnswer{CaptchaAnswer: captchatoolsgo.CaptchaAnswer{Token: fmt.Sprint(i)}, solvedAt: solvedAt.Add(time.Duration(i) * time.Millisecond), fingerprint: fingerprint}
+			require.NoError(t, c.enqueueLocked(tkn))
+		}
+		queued := c.queuedLocked()
+		var kept []string
+		for _, q := range c.keyed {
+			tkn, err := q.Peek()
+			require.NoError(t, err)
+			kept = append(kept, tkn.Token)
+		}
+		c.mu.Unlock()
+
+		// Assert
+		assert.Equal(t, 2, queued)
+		assert.ElementsMatch(t, []string{"48", "49"}, kept, "the oldest tokens should be discarded")
+		assert.Equal(t, 48, metrics.discarded)
+	})
+}
+
+func TestEnqueueLocked_FullQueue(t *testing.T) {
+	// Arrange
+	metrics := &recordingMetrics{}
+	c := New(WithMaxCapacity(2), WithMetrics(metrics)).(*captchasolve)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, token := range []string{"oldest", "older"} {
+		require.NoError(t, c.enqueueLocked(&CaptchaAnswer{CaptchaAnswer: captchatoolsgo.CaptchaAnswer{Token: token}, solvedAt: time.Now()}))
+	}
+
+	// Act
+	err := c.enqueueLocked(&CaptchaAnswer{CaptchaAnswer: captchatoolsgo.CaptchaAnswer{Token: "newest"}, solvedAt: time.Now()})
+
+	// Assert
+	require.NoError(t, err, "the new token should take the place of the oldest one")
+	require.Equal(t, 2, c.queue.Len())
+	tkn, _ := c.queue.Peek()
+	assert.Equal(t, "older", tkn.Token)
+	assert.Equal(t, 1, metrics.discarded)
+}
@@ -0,0 +1,191 @@
+// Package grpcserver exposes CaptchaSolve solvers as the gRPC service defined in
+// proto/captchasolve/v1/captchasolve.proto, for programs that are gRPC-first. The
+// deadline of every call is passed on to the solver, so a caller giving up stops waiting
+// for a token right away.
+//
+// Example:
+//
+//	registry, err := captchasolve.LoadConfig("captchasolve.yaml")
+//	...
+//	s := grpc.NewServer()
+//	grpcserver.New(registry, grpcserver.WithAPIKeys(os.Getenv("CAPTCHASOLVE_API_KEY"))).Register(s)
+//	s.Serve(listener)
+package grpcserver
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Matthew17-21/CaptchaSolve"
+	"github.com/Matthew17-21/CaptchaSolve/internal/serving"
+	captchasolvev1 "github.com/Matthew17-21/CaptchaSolve/proto/captchasolve/v1"
+	"github.com/Matthew17-21/CaptchaSolve/server"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Server implements the CaptchaSolve gRPC service over the solvers of a
+// captchasolve.Registry or server.Sites.
+type Server struct {
+	captchasolvev1.UnimplementedCaptchaSolveServer
+
+	solvers server.Solvers
+	apiKeys serving.Keys
+	logger  captchasolve.Logger
+	served  serving.Tokens // Tokens handed out, so they can be reported
+}
+
+// Option customizes a Server.
+type Option func(s *Server)
+
+// WithAPIKeys makes the Server reject calls that don't carry one of keys, in the
+// authorization metadata as a bearer token or in the x-api-key metadata. Empty keys are
+// ignored. Every call is accepted if no key is set.
+func WithAPIKeys(keys ...string) Option {
+	return func(s *Server) {
+		s.apiKeys = append(s.apiKeys, serving.NewKeys(keys...)...)
+	}
+}
+
+// WithLogger sets the logger the Server reports failed calls to. Nothing is logged by
+// default.
+func WithLogger(l captchasolve.Logger) Option {
+	return func(s *Server) {
+		if l != nil {
+			s.logger = l
+		}
+	}
+}
+
+// New creates a Server serving tokens from solvers.
+func New(solvers server.Solvers, opts ...Option) *Server {
+	s := &Server{solvers: solvers, logger: captchasolve.NewSilentLogger()}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Register registers the service with r, such as a *grpc.Server.
+func (s *Server) Register(r grpc.ServiceRegistrar) {
+	captchasolvev1.RegisterCaptchaSolveServer(r, s)
+}
+
+// GetToken implements captchasolvev1.CaptchaSolveServer, waiting for a token until the
+// deadline of the call.
+func (s *Server) GetToken(ctx context.Context, req *captchasolvev1.GetTokenRequest) (*captchasolvev1.Token, error) {
+	solver, err := s.solver(ctx, req.GetSite())
+	if err != nil {
+		return nil, err
+	}
+	return s.getToken(ctx, req.GetSite(), solver)
+}
+
+// SubscribeTokens implements captchasolvev1.CaptchaSolveServer, sending tokens as they're
+// harvested until the call is cancelled, max_tokens were sent or getting a token fails.
+func (s *Server) SubscribeTokens(req *captchasolvev1.SubscribeTokensRequest, stream grpc.ServerStreamingServer[captchasolvev1.Token]) error {
+	ctx := stream.Context()
+	solver, err := s.solver(ctx, req.GetSite())
+	if err != nil {
+		return err
+	}
+	for sent := int32(0); req.GetMaxTokens() <= 0 || sent < req.GetMaxTokens(); sent++ {
+		token, err := s.getToken(ctx, req.GetSite(), solver)
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(token); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReportToken implements captchasolvev1.CaptchaSolveServer, reporting a token handed out
+// for the site as rejected.
+func (s *Server) ReportToken(ctx context.Context, req *captchasolvev1.ReportTokenRequest) (*captchasolvev1.ReportTokenResponse, error) {
+	solver, err := s.solver(ctx, req.GetSite())
+	if err != nil {
+		return nil, err
+	}
+	if req.GetToken() == "" {
+		return nil, status.Error(codes.InvalidArgument, "the token is required")
+	}
+	token, ok := s.served.Take(req.GetSite(), req.GetToken())
+	if !ok {
+		return nil, status.Error(codes.NotFound, "the token wasn't handed out for this site or has expired")
+	}
+	if err := solver.ReportBadToken(token); err != nil {
+		captchasolve.WithFields(s.logger, "site", req.GetSite(), "error", err).Warn("Failed to report a token")
+		return nil, toStatus(err)
+	}
+	return &captchasolvev1.ReportTokenResponse{}, nil
+}
+
+// getToken gets a token for site from solver, remembering it so it can be reported.
+func (s *Server) getToken(ctx context.Context, site string, solver captchasolve.CaptchaSolve) (*captchasolvev1.Token, error) {
+	token, err := solver.GetToken(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			captchasolve.WithFields(s.logger, "site", site, "error", err).Warn("Failed to get a token")
+		}
+		return nil, toStatus(err)
+	}
+	s.served.Add(site, token)
+	return &captchasolvev1.Token{
+		Site:      site,
+		Token:     token.Token,
+		UserAgent: token.UserAgent,
+		Id:        int64(token.Id()),
+		Provider:  token.Provider(),
+		ExpiresAt: timestamppb.New(token.ExpiresAt()),
+	}, nil
+}
+
+// solver checks the API key of the call and returns the solver of site.
+func (s *Server) solver(ctx context.Context, site string) (captchasolve.CaptchaSolve, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if !s.apiKeys.Allow(serving.KeyFromHeaders(first(md, "authorization"), first(md, "x-api-key"))) {
+		return nil, status.Error(codes.Unauthenticated, "missing or invalid API key")
+	}
+	if site == "" {
+		return nil, status.Error(codes.InvalidArgument, "the site is required")
+	}
+	solver, ok := s.solvers.Solver(site)
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "unknown site %q", site)
+	}
+	return solver, nil
+}
+
+// first returns the first value of the metadata key, if any.
+func first(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// toStatus converts an error of a solver to a gRPC status error.
+func toStatus(err error) error {
+	code := codes.Internal
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		code = codes.DeadlineExceeded
+	case errors.Is(err, context.Canceled):
+		code = codes.Canceled
+	case errors.Is(err, captchasolve.ErrBudgetExceeded):
+		code = codes.ResourceExhausted
+	case errors.Is(err, captchasolve.ErrAllHarvestersFailed),
+		errors.Is(err, captchasolve.ErrPoolClosed),
+		errors.Is(err, captchasolve.ErrNoHarvesters),
+		errors.Is(err, captchasolve.ErrAllHarvestersQuarantined):
+		code = codes.Unavailable
+	case errors.Is(err, captchasolve.ErrUnknownSite):
+		code = codes.NotFound
+	}
+	return status.Error(code, err.Error())
+}
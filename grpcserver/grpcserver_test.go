@@ -0,0 +1,203 @@
+package grpcserver
+
+import (
+	"context"
+	"io"
+	"net"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	captchatoolsgo "github.com/Matthew17-21/Captcha-Tools/captchatools-go"
+	"github.com/Matthew17-21/CaptchaSolve"
+	captchasolvev1 "github.com/Matthew17-21/CaptchaSolve/proto/captchasolve/v1"
+	"github.com/Matthew17-21/CaptchaSolve/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// fakeHarvester is a harvester returning numbered tokens after a delay.
+type fakeHarvester struct {
+	calls atomic.Int32
+	delay time.Duration
+}
+
+func (f *fakeHarvester) GetToken(additional ...*captchatoolsgo.AdditionalData) (*captchatoolsgo.CaptchaAnswer, error) {
+	return f.GetTokenWithContext(context.Background(), additional...)
+}
+
+func (f *fakeHarvester) GetTokenWithContext(ctx context.Context, _ ...*captchatoolsgo.AdditionalData) (*captchatoolsgo.CaptchaAnswer, error) {
+	n := f.calls.Add(1)
+	select {
+	case <-time.After(f.delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return &captchatoolsgo.CaptchaAnswer{Token: "token-" + strconv.Itoa(int(n))}, nil
+}
+
+func (f *fakeHarvester) GetBalance() (float32, error) { return 1, nil }
+
+// newClient serves a solver for the "retailer" site, backed by h, over an in-memory
+// connection and returns a client of the service and the solver.
+func newClient(t *testing.T, h captchatoolsgo.Harvester, opts ...Option) (captchasolvev1.CaptchaSolveClient, captchasolve.CaptchaSolve) {
+	t.Helper()
+	solver := captchasolve.New(captchasolve.WithHarvester(h))
+	t.Cleanup(func() { solver.Close() })
+
+	listener := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	New(server.Sites{"retailer": solver}, opts...).Register(s)
+	go s.Serve(listener)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return captchasolvev1.NewCaptchaSolveClient(conn), solver
+}
+
+func TestServer_GetToken(t *testing.T) {
+	t.Run("returns a token", func(t *testing.T) {
+		// Arrange
+		client, _ := newClient(t, &fakeHarvester{})
+
+		// Act
+		token, err := client.GetToken(context.Background(), &captchasolvev1.GetTokenRequest{Site: "retailer"})
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, "retailer", token.GetSite())
+		assert.Equal(t, "token-1", token.GetToken())
+		assert.Equal(t, "*grpcserver.fakeHarvester", token.GetProvider())
+		assert.WithinDuration(t, time.Now().Add(2*time.Minute), token.GetExpiresAt().AsTime(), 5*time.Second)
+	})
+
+	t.Run("stops waiting at the deadline", func(t *testing.T) {
+		// Arrange
+		client, solver := newClient(t, &fakeHarvester{delay: time.Hour})
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		// Act
+		_, err := client.GetToken(ctx, &captchasolvev1.GetTokenRequest{Site: "retailer"})
+
+		// Assert
+		require.Equal(t, codes.DeadlineExceeded, status.Code(err))
+		require.Eventually(t, func() bool { return solver.PoolStatus().Waiters == 0 }, time.Second, time.Millisecond,
+			"the deadline should reach the solver")
+	})
+
+	t.Run("reports bad requests", func(t *testing.T) {
+		client, _ := newClient(t, &fakeHarvester{})
+
+		_, err := client.GetToken(context.Background(), &captchasolvev1.GetTokenRequest{})
+		require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+		_, err = client.GetToken(context.Background(), &captchasolvev1.GetTokenRequest{Site: "shop"})
+		require.Equal(t, codes.NotFound, status.Code(err))
+	})
+}
+
+func TestServer_APIKeys(t *testing.T) {
+	client, _ := newClient(t, &fakeHarvester{}, WithAPIKeys("secret"))
+	request := &captchasolvev1.GetTokenRequest{Site: "retailer"}
+
+	tests := []struct {
+		name     string
+		metadata []string
+		code     codes.Code
+	}{
+		{name: "no key", code: codes.Unauthenticated},
+		{name: "wrong key", metadata: []string{"x-api-key", "guess"}, code: codes.Unauthenticated},
+		{name: "x-api-key", metadata: []string{"x-api-key", "secret"}, code: codes.OK},
+		{name: "bearer token", metadata: []string{"authorization", "Bearer secret"}, code: codes.OK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := metadata.AppendToOutgoingContext(context.Background(), tt.metadata...)
+
+			_, err := client.GetToken(ctx, request)
+
+			require.Equal(t, tt.code, status.Code(err))
+		})
+	}
+}
+
+func TestServer_SubscribeTokens(t *testing.T) {
+	t.Run("streams tokens as they're harvested", func(t *testing.T) {
+		// Arrange
+		client, _ := newClient(t, &fakeHarvester{})
+
+		// Act
+		stream, err := client.SubscribeTokens(context.Background(), &captchasolvev1.SubscribeTokensRequest{Site: "retailer", MaxTokens: 3})
+		require.NoError(t, err)
+		var tokens []string
+		for {
+			token, err := stream.Recv()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			tokens = append(tokens, token.GetToken())
+		}
+
+		// Assert
+		require.Equal(t, []string{"token-1", "token-2", "token-3"}, tokens)
+	})
+
+	t.Run("stops once cancelled", func(t *testing.T) {
+		// Arrange
+		h := &fakeHarvester{delay: 10 * time.Millisecond}
+		client, solver := newClient(t, h)
+		ctx, cancel := context.WithCancel(context.Background())
+		stream, err := client.SubscribeTokens(ctx, &captchasolvev1.SubscribeTokensRequest{Site: "retailer"})
+		require.NoError(t, err)
+		_, err = stream.Recv()
+		require.NoError(t, err)
+
+		// Act
+		cancel()
+
+		// Assert
+		_, err = stream.Recv()
+		require.Equal(t, codes.Canceled, status.Code(err))
+		require.Eventually(t, func() bool {
+			pool := solver.PoolStatus()
+			return pool.Waiters == 0 && pool.InFlight == 0
+		}, time.Second, time.Millisecond)
+		calls := h.calls.Load()
+		time.Sleep(50 * time.Millisecond)
+		assert.Equal(t, calls, h.calls.Load(), "no more tokens should be requested")
+	})
+}
+
+func TestServer_ReportToken(t *testing.T) {
+	// Arrange
+	client, solver := newClient(t, &fakeHarvester{})
+	token, err := client.GetToken(context.Background(), &captchasolvev1.GetTokenRequest{Site: "retailer"})
+	require.NoError(t, err)
+	request := &captchasolvev1.ReportTokenRequest{Site: "retailer", Token: token.GetToken()}
+
+	// Act
+	_, err = client.ReportToken(context.Background(), request)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, map[captchasolve.ErrorClass]int{captchasolve.ErrorClassRejected: 1}, solver.Stats()[0].Failures)
+
+	_, err = client.ReportToken(context.Background(), request)
+	require.Equal(t, codes.NotFound, status.Code(err), "tokens are only reported once")
+	_, err = client.ReportToken(context.Background(), &captchasolvev1.ReportTokenRequest{Site: "retailer"})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
@@ -2,103 +2,181 @@ package captchasolve
 
 import (
 	"context"
-	"errors"
-	"fmt"
 	"sync"
+	"time"
 
 	captchatoolsgo "github.com/Matthew17-21/Captcha-Tools/captchatools-go"
 )
 
-// startHarvesters coordinates concurrent token harvesting from multiple harvesters
-func (c *captchasolve) startHarvesters(ctx context.Context, additional ...*captchatoolsgo.AdditionalData) {
+// startHarvesters runs n solves concurrently, each using the harvester picked by the Strategy,
+// and hands the results off as they arrive. At most maxGoroutines solves run at once
+// across the whole instance.
+func (c *captchasolve) startHarvesters(ctx context.Context, n int, additional ...*captchatoolsgo.AdditionalData) {
+	ctx, span := c.startSpan(ctx, "captchasolve.startHarvesters", attrSolves.Int(n))
+
 	// Create a results channel to collect harvester results
-	resultsChan := make(chan result, len(c.harvesters))
+	resultsChan := make(chan result, n)
 
-	// Create a semaphore channel to limit concurrent goroutines
-	sem := make(chan struct{}, c.maxGoroutines)
+	// Create harvesters in the background, so the results of the solves that finished are
+	// handed off while the others wait for a slot
+	c.logger.Info("Creating %d harvesters...", n)
+	go func() {
+		var wg sync.WaitGroup
+		for i := 0; i < n; i++ {
+			// Acquire a solve slot
+			release, err := c.acquire(ctx) // Will block if maxGoroutines solves are running
+			if err != nil {
+				resultsChan <- result{err: err}
+				continue
+			}
 
-	// Create harvesters
-	c.logger.Info("Creating %d harvesters...", len(c.harvesters))
-	var wg sync.WaitGroup
-	for i, harvester := range c.harvesters {
-		wg.Add(1)
-		c.logger.Info("Created harvester #%d", i+1)
+			// Pick the harvester to use
+			index, harvester, ok := c.nextHarvester()
+			if !ok {
+				release()
+				resultsChan <- result{err: ErrAllHarvestersQuarantined}
+				continue
+			}
 
-		// Acquire semaphore
-		sem <- struct{}{} // Will block if maxConcurrent goroutines are running
+			// Make sure the solve budget isn't spent
+			if !c.spendBudget() {
+				release()
+				resultsChan <- result{err: ErrBudgetExceeded}
+				continue
+			}
 
-		go func(h captchatoolsgo.Harvester) {
-			defer func() {
-				<-sem // Release semaphore when done
-				wg.Done()
+			wg.Add(1)
+			c.harvesterLogger(index, harvester).Info("Created harvester")
+			go func() {
+				defer func() {
+					release() // Release the slot when done
+					wg.Done()
+				}()
+				c.solve(ctx, index, harvester, resultsChan, additional...)
 			}()
-			c.harvestToken(ctx, h, resultsChan, additional...)
-		}(harvester)
-	}
+		}
 
-	// Close the results channel once all harvesters finish
-	go func() {
+		// Close the results channel once all harvesters finish
 		wg.Wait()
 		close(resultsChan)
 	}()
 
-	// Process the results to retrieve the first valid token
-	c.processResults(ctx, resultsChan)
+	// Hand off every result as it arrives
+	endSpan(span, c.processResults(ctx, Fingerprint(additional...), resultsChan))
 }
 
 // harvestToken attempts to obtain a captcha token from a single harvester and sends the result
 // through the results channel. It handles the actual communication with the captcha service.
 //
-// The function automatically converts the harvester's token to a CaptchaAnswer before sending.
+// Failed calls are retried according to the harvester's RetryPolicy, waiting between
+// attempts, unless Update replaced the harvester in the meantime. The error of the last
+// attempt is sent if every attempt failed.
+//
+// The function automatically converts the harvester's token to a CaptchaAnswer, with its
+// validity window set, before sending. Errors that mean the harvester can't be used
+// anymore, such as running out of funds, get it quarantined.
 // It logs the progress and any errors that occur during the harvesting process.
-func (c *captchasolve) harvestToken(ctx context.Context, h captchatoolsgo.Harvester, resultsChan chan<- result, additional ...*captchatoolsgo.AdditionalData) {
-	c.logger.Info("Attempting to get a token from harvester...")
-	tkn, err := h.GetTokenWithContext(ctx, additional...)
-	if err != nil {
-		c.logger.Error("Failed to get a token. Error: %v", err)
-		resultsChan <- result{token: nil, err: fmt.Errorf("error getting token: %w", err)}
-		return
+func (c *captchasolve) harvestToken(ctx context.Context, index int, h captchatoolsgo.Harvester, resultsChan chan<- result, additional ...*captchatoolsgo.AdditionalData) {
+	policy := c.retryPolicy(index)
+	for attempt := 1; ; attempt++ {
+		log := WithFields(c.harvesterLogger(index, h), "attempt", attempt)
+		log.Info("Attempting to get a token from harvester...")
+		attemptCtx, span := c.startSpan(ctx, "captchasolve.harvestToken",
+			attrHarvester.Int(index), attrProvider.String(providerName(h)), attrAttempt.Int(attempt))
+		start := time.Now()
+		tkn, err := h.GetTokenWithContext(attemptCtx, additional...)
+		if err == nil && tkn != nil {
+			span.SetAttributes(attrTokenID.Int(tkn.Id()))
+		}
+		endSpan(span, err)
+		if err == nil {
+			latency := time.Since(start)
+			c.recordCall(index, h, latency, nil)
+			c.reportHarvested(index, h, latency)
+			WithFields(log, "token_id", tkn.Id(), "latency", latency).Info("Successfully got token!")
+			token := toCaptchaAnswer(tkn).withValidity(c.validity(h, tkn))
+			token.fingerprint = Fingerprint(additional...)
+			token.harvester = h
+			resultsChan <- result{token: token, err: nil}
+			return
+		}
+		WithFields(log, "error", err).Error("Failed to get a token")
+		if ctx.Err() == nil { // Cancelled calls, such as hedges that lost, aren't the harvester's fault
+			c.recordCall(index, h, 0, err)
+			c.reportFailed(index, h, err)
+		}
+
+		// Retry unless the policy gives up, the harvester got quarantined or replaced, or
+		// the solve is cancelled while waiting
+		delay, retry := policy.Backoff(attempt, err)
+		if retry {
+			c.mu.Lock()
+			retry = !c.quarantinedLocked(index) && !c.replacedLocked(index, h)
+			c.mu.Unlock()
+		}
+		if retry {
+			WithFields(log, "delay", delay).Warn("Retrying harvester")
+			c.reportRetried(index, h)
+			retry = sleep(ctx, delay) == nil
+		}
+		if !retry {
+			resultsChan <- result{token: nil, err: &HarvesterError{Index: index, Provider: providerName(h), Attempts: attempt, Err: err}}
+			return
+		}
 	}
-	c.logger.Info("Successfully got token with ID %v!", tkn.Id())
-	resultsChan <- result{token: toCaptchaAnswer(tkn), err: nil}
 }
 
 // processResults handles the continuous processing of harvested tokens from multiple harvesters.
-// It receives results from the resultsChan and adds valid tokens to the queue for later use.
+// It receives results from the resultsChan until it is closed and hands valid tokens off for use.
+//
+// Any valid tokens received are given to the oldest waiting caller, or added to the
+// queue for future use when nobody is waiting.
+// Invalid results (nil tokens or errors) are logged and skipped. A failed solve also ends
+// the wait of the caller it leaves uncovered, with a *RoundError holding the solve's error.
 //
-// Any valid tokens received are added to the queue for future use.
-// Invalid results (nil tokens or errors) are logged and skipped.
-func (c *captchasolve) processResults(ctx context.Context, resultsChan <-chan result) (*CaptchaAnswer, error) {
-	/*
-		Return the first token that is sent to the results channel
-		All other tokens that get sent to the results channel, add to queue
-	*/
+// Every result marks the end of one in-flight solve. A *RoundError holding the error of
+// every solve is returned if none of the results held a valid token.
+func (c *captchasolve) processResults(ctx context.Context, fingerprint string, resultsChan <-chan result) error {
 	c.logger.Info("Processing results...")
-	for {
-		select {
-		case res, ok := <-resultsChan:
-			if !ok {
-				return nil, errors.New("no valid tokens found")
-			}
-
-			if res.err != nil {
-				c.logger.Error("Error on response:", res.err)
-				continue
-			}
-
-			if res.token == nil {
-				c.logger.Warn("error - token is nil. Retrying...")
-				continue
-			}
+	var delivered bool
+	var errs []error
+	for res := range resultsChan {
+		c.mu.Lock()
+		c.addInFlightLocked(fingerprint, -1)
+		if res.err != nil {
+			// Let the caller left without a solve know instead of having it wait forever
+			c.failUncoveredWaiterLocked(fingerprint, &RoundError{Errors: []error{res.err}})
+			c.reportPoolLocked()
+			c.mu.Unlock()
+			WithFields(c.logger, "error", res.err).Error("Solve failed")
+			errs = append(errs, res.err)
+			continue
+		}
 
-			// Add the token to queue
-			if err := c.queue.Enqueue(res.token); err != nil {
-				return nil, fmt.Errorf("error enqueuing token: %w", err)
-			}
+		if res.token == nil {
+			c.failUncoveredWaiterLocked(fingerprint, &RoundError{Errors: []error{errNilToken}})
+			c.reportPoolLocked()
+			c.mu.Unlock()
+			c.logger.Warn("Solve returned a nil token")
+			errs = append(errs, errNilToken)
+			continue
+		}
 
-		case <-ctx.Done():
-			c.logger.Warn("Context is done. Stopping...")
-			return nil, ctx.Err()
+		// Hand the token to a waiter or add it to the queue
+		_, span := c.startSpan(ctx, "captchasolve.handoff",
+			attrTokenID.Int(res.token.Id()), tokenAge(res.token), attrHandedOff.Bool(c.waitersFor(res.token.fingerprint) > 0))
+		err := c.deliverLocked(res.token)
+		c.reportPoolLocked()
+		c.mu.Unlock()
+		endSpan(span, err)
+		if err != nil {
+			WithFields(c.logger, "token_id", res.token.Id(), "error", err).Error("Error enqueuing token")
+			continue
 		}
+		delivered = true
+	}
+	if !delivered {
+		return &RoundError{Errors: errs}
 	}
+	return nil
 }
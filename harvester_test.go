@@ -4,9 +4,11 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 
 	captchatoolsgo "github.com/Matthew17-21/Captcha-Tools/captchatools-go"
 )
@@ -41,13 +43,57 @@ func TestStartHarvesters(t *testing.T) {
 		queue: mockQueue,
 	}
 
-	c.startHarvesters(ctx)
+	c.startHarvesters(ctx, 1)
 
 	mockLogger.AssertExpectations(t)
 	mockHarvester.AssertExpectations(t)
 	mockQueue.AssertExpectations(t)
 }
 
+// gatedHarvester returns its first token right away, and the others once release is closed.
+type gatedHarvester struct {
+	fakeHarvester
+	release chan struct{}
+}
+
+func (g *gatedHarvester) GetTokenWithContext(ctx context.Context, additional ...*captchatoolsgo.AdditionalData) (*captchatoolsgo.CaptchaAnswer, error) {
+	if g.calls.Load() > 0 {
+		select {
+		case <-g.release:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return g.fakeHarvester.GetTokenWithContext(ctx, additional...)
+}
+
+func TestStartHarvesters_HandsOffAsSolved(t *testing.T) {
+	// Arrange
+	h := &gatedHarvester{release: make(chan struct{})}
+	c := New(WithHarvester(h), WithMaxGoroutines(1)).(*captchasolve)
+	defer c.Close()
+	c.mu.Lock()
+	c.inFlight = 3
+	c.mu.Unlock()
+
+	// Act
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.startHarvesters(context.Background(), 3)
+	}()
+
+	// Assert
+	require.Eventually(t, func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.queue.Len() == 1
+	}, time.Second, time.Millisecond, "the first token shouldn't wait for the other solves to get a slot")
+	close(h.release)
+	<-done
+	assert.Equal(t, 3, c.queue.Len())
+}
+
 func TestHarvestToken_Success(t *testing.T) {
 	ctx := context.Background()
 	resultsChan := make(chan result, 1)
@@ -64,7 +110,7 @@ func TestHarvestToken_Success(t *testing.T) {
 		},
 	}
 
-	c.harvestToken(ctx, mockHarvester, resultsChan)
+	c.harvestToken(ctx, 0, mockHarvester, resultsChan)
 
 	res := <-resultsChan
 	assert.NoError(t, res.err)
@@ -74,10 +120,39 @@ func TestHarvestToken_Success(t *testing.T) {
 	mockHarvester.AssertExpectations(t)
 }
 
-func TestProcessResults_Error(t *testing.T) {
+func TestHarvestToken_SetsValidity(t *testing.T) {
 	ctx := context.Background()
 	resultsChan := make(chan result, 1)
 
+	c := New(WithTokenValidity(30 * time.Second)).(*captchasolve)
+
+	c.harvestToken(ctx, 0, &fakeHarvester{}, resultsChan)
+
+	res := <-resultsChan
+	assert.NoError(t, res.err)
+	assert.Equal(t, res.token.solvedAt.Add(30*time.Second), res.token.ExpiresAt())
+}
+
+func TestHarvestToken_Error(t *testing.T) {
+	ctx := context.Background()
+	resultsChan := make(chan result, 1)
+	providerErr := errors.New("ERROR_CAPTCHA_UNSOLVABLE")
+
+	c := New().(*captchasolve)
+
+	c.harvestToken(ctx, 2, &fakeHarvester{err: providerErr}, resultsChan)
+
+	res := <-resultsChan
+	var harvesterErr *HarvesterError
+	assert.ErrorAs(t, res.err, &harvesterErr)
+	assert.Equal(t, 2, harvesterErr.Index)
+	assert.Equal(t, "*captchasolve.fakeHarvester", harvesterErr.Provider)
+	assert.ErrorIs(t, res.err, providerErr)
+}
+
+func TestProcessResults_Error(t *testing.T) {
+	resultsChan := make(chan result, 1)
+
 	mockLogger := &mockLogger{}
 	mockLogger.On("Info", mock.Anything, mock.Anything).Return()
 	mockLogger.On("Error", mock.Anything, mock.Anything).Return()
@@ -94,8 +169,8 @@ func TestProcessResults_Error(t *testing.T) {
 	resultsChan <- result{token: nil, err: errors.New("test error")}
 	close(resultsChan)
 
-	_, err := c.processResults(ctx, resultsChan)
-	assert.Error(t, err)
+	err := c.processResults(context.Background(), "", resultsChan)
+	assert.ErrorIs(t, err, ErrAllHarvestersFailed)
 
 	mockLogger.AssertExpectations(t)
 }
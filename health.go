@@ -0,0 +1,262 @@
+package captchasolve
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	captchatoolsgo "github.com/Matthew17-21/Captcha-Tools/captchatools-go"
+)
+
+const (
+	// defaultQuarantineRecheck is how long a quarantined harvester waits before its
+	// balance is first checked again. The wait doubles after every failed check, up to
+	// defaultQuarantineMaxRecheck.
+	defaultQuarantineRecheck    = time.Minute
+	defaultQuarantineMaxRecheck = 30 * time.Minute
+
+	// quarantineCheckInterval is how often the quarantine worker looks for harvesters
+	// due for a recheck.
+	quarantineCheckInterval = 10 * time.Second
+)
+
+// HarvesterState is whether a harvester is used for solves.
+type HarvesterState int
+
+const (
+	// HarvesterActive harvesters are used for solves.
+	HarvesterActive HarvesterState = iota
+
+	// HarvesterQuarantined harvesters are skipped until a balance check shows they can
+	// be used again.
+	HarvesterQuarantined
+)
+
+func (s HarvesterState) String() string {
+	if s == HarvesterQuarantined {
+		return "quarantined"
+	}
+	return "active"
+}
+
+// HarvesterStatus describes the health of one of the configured harvesters.
+type HarvesterStatus struct {
+	Index         int            // Position of the harvester in the order it was configured
+	Provider      string         // Type of the harvester
+	State         HarvesterState // Whether the harvester is used for solves
+	Reason        ErrorClass     // Class of the error that got the harvester quarantined
+	LastError     error          // Last error returned by the harvester, if any
+	QuarantinedAt time.Time      // When the harvester was quarantined
+	NextCheck     time.Time      // When the harvester's balance will be checked again
+}
+
+// harvesterHealth is the internal health record of a harvester.
+type harvesterHealth struct {
+	state         HarvesterState
+	reason        ErrorClass
+	lastErr       error
+	quarantinedAt time.Time
+	nextCheck     time.Time
+	recheck       time.Duration // Wait before the next balance check
+	failures      int           // Number of consecutive failed calls
+	latency       time.Duration // Moving average of the latency of successful calls
+
+	// successes, failuresByClass and lastSuccess are reported by Stats.
+	successes       int
+	failuresByClass map[ErrorClass]int
+	lastSuccess     time.Time
+
+	// latencies holds the latency of the last latencyWindow successful calls, with
+	// latencyNext the position the next one is written to once it is full.
+	latencies   []time.Duration
+	latencyNext int
+
+	// stats is a snapshot of the statistics above, rebuilt on every recorded call so the
+	// latency percentiles aren't worked out again on every pick.
+	stats HarvesterStats
+}
+
+// shouldQuarantine reports whether errors of the given class mean the harvester can't
+// be used until an operator intervenes, rather than being a one-off failure.
+func shouldQuarantine(class ErrorClass) bool {
+	switch class {
+	case ErrorClassNoBalance, ErrorClassInvalidKey, ErrorClassBanned:
+		return true
+	default:
+		return false
+	}
+}
+
+// providerName returns a name identifying the provider of a harvester.
+func providerName(h captchatoolsgo.Harvester) string {
+	return fmt.Sprintf("%T", h)
+}
+
+// harvesterLogger returns the logger of h, the harvester at index i, adding its number and
+// provider to every record.
+func (c *captchasolve) harvesterLogger(i int, h captchatoolsgo.Harvester) Logger {
+	return WithFields(c.logger, "harvester", i+1, "provider", providerName(h))
+}
+
+// healthLocked returns the health record of the harvester at index i. c.mu must be held.
+func (c *captchasolve) healthLocked(i int) *harvesterHealth {
+	if c.health == nil {
+		c.health = make(map[int]*harvesterHealth)
+	}
+	h, ok := c.health[i]
+	if !ok {
+		h = &harvesterHealth{}
+		c.health[i] = h
+	}
+	return h
+}
+
+// recordCall records the outcome of a call to h, the harvester at index i when it was
+// picked, unless Update replaced it since: its results mustn't count towards, or get
+// quarantined, the harvester that took its place.
+func (c *captchasolve) recordCall(i int, h captchatoolsgo.Harvester, latency time.Duration, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.replacedLocked(i, h) {
+		return
+	}
+	if err != nil {
+		c.recordFailureLocked(i, err)
+		return
+	}
+	c.recordSuccessLocked(i, latency)
+}
+
+// recordFailureLocked records an error returned by the harvester at index i, quarantining
+// it if the error means it can't be used anymore. c.mu must be held.
+func (c *captchasolve) recordFailureLocked(i int, err error) {
+	h := c.healthLocked(i)
+	h.lastErr = err
+	h.failures++
+	class := ClassifyError(err)
+	if h.failuresByClass == nil {
+		h.failuresByClass = make(map[ErrorClass]int)
+	}
+	h.failuresByClass[class]++
+	h.refreshStats()
+	if h.state == HarvesterQuarantined || !shouldQuarantine(class) {
+		return
+	}
+
+	log := c.logger
+	if i < len(c.harvesters) {
+		log = c.harvesterLogger(i, c.harvesters[i])
+	}
+	WithFields(log, "reason", class, "error", err).Warn("Quarantining harvester")
+	h.state = HarvesterQuarantined
+	h.reason = class
+	h.quarantinedAt = time.Now()
+	h.recheck = c.quarantineRecheck
+	h.nextCheck = h.quarantinedAt.Add(h.recheck)
+}
+
+// recordSuccessLocked records a token harvested by the harvester at index i in the given
+// time. c.mu must be held.
+func (c *captchasolve) recordSuccessLocked(i int, latency time.Duration) {
+	h := c.healthLocked(i)
+	h.lastErr = nil
+	h.failures = 0
+	h.successes++
+	h.lastSuccess = time.Now()
+
+	// Weigh recent solves more so the average follows changes in provider speed
+	if h.latency == 0 {
+		h.latency = latency
+	} else {
+		h.latency = (4*h.latency + latency) / 5
+	}
+	if len(h.latencies) < latencyWindow {
+		h.latencies = append(h.latencies, latency)
+	} else {
+		h.latencies[h.latencyNext] = latency
+		h.latencyNext = (h.latencyNext + 1) % latencyWindow
+	}
+	h.refreshStats()
+}
+
+// quarantinedLocked reports whether the harvester at index i is quarantined. c.mu must be held.
+func (c *captchasolve) quarantinedLocked(i int) bool {
+	h, ok := c.health[i]
+	return ok && h.state == HarvesterQuarantined
+}
+
+// quarantine periodically checks the balance of quarantined harvesters until ctx is
+// cancelled, putting them back in rotation once they can be used again.
+func (c *captchasolve) quarantine(ctx context.Context) {
+	ticker := time.NewTicker(quarantineCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.recheckQuarantined(time.Now())
+		}
+	}
+}
+
+// recheckQuarantined checks the balance of every quarantined harvester due for a recheck
+// at now. Harvesters with funds are restored; the others wait twice as long for the next
+// check, up to the configured maximum.
+func (c *captchasolve) recheckQuarantined(now time.Time) {
+	// Collect the harvesters due for a recheck so the balance calls run without the lock
+	c.mu.Lock()
+	due := make(map[int]captchatoolsgo.Harvester)
+	for i, h := range c.health {
+		if h.state == HarvesterQuarantined && !now.Before(h.nextCheck) && i < len(c.harvesters) {
+			due[i] = c.harvesters[i]
+		}
+	}
+	c.mu.Unlock()
+
+	for i, harvester := range due {
+		balance, err := harvester.GetBalance()
+		if err == nil && balance <= 0 {
+			err = fmt.Errorf("no balance left (%v)", balance)
+		}
+
+		c.mu.Lock()
+		if c.replacedLocked(i, harvester) {
+			c.mu.Unlock()
+			continue
+		}
+		h := c.healthLocked(i)
+		if err != nil {
+			h.lastErr = err
+			h.recheck = min(h.recheck*2, c.quarantineMaxRecheck)
+			h.nextCheck = now.Add(h.recheck)
+			WithFields(c.harvesterLogger(i, harvester), "next_check", h.nextCheck, "error", err).Info("Harvester still quarantined")
+		} else {
+			// Keep the statistics, which cover the lifetime of the solver
+			h.state, h.reason, h.lastErr = HarvesterActive, ErrorClassUnknown, nil
+			h.quarantinedAt, h.nextCheck, h.recheck = time.Time{}, time.Time{}, 0
+			h.failures = 0
+			WithFields(c.harvesterLogger(i, harvester), "balance", balance).Info("Harvester has a balance, restoring it")
+		}
+		c.mu.Unlock()
+	}
+}
+
+// HarvesterStatus returns the health of every configured harvester, in the order they
+// were configured.
+func (c *captchasolve) HarvesterStatus() []HarvesterStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	statuses := make([]HarvesterStatus, len(c.harvesters))
+	for i, harvester := range c.harvesters {
+		statuses[i] = HarvesterStatus{Index: i, Provider: providerName(harvester)}
+		if h, ok := c.health[i]; ok {
+			statuses[i].State = h.state
+			statuses[i].Reason = h.reason
+			statuses[i].LastError = h.lastErr
+			statuses[i].QuarantinedAt = h.quarantinedAt
+			statuses[i].NextCheck = h.nextCheck
+		}
+	}
+	return statuses
+}
@@ -0,0 +1,126 @@
+package captchasolve
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// balanceHarvester is a fakeHarvester with a configurable balance.
+type balanceHarvester struct {
+	fakeHarvester
+	balance float32
+}
+
+func (b *balanceHarvester) GetBalance() (float32, error) { return b.balance, nil }
+
+func TestRecordFailure(t *testing.T) {
+	t.Run("quarantines harvesters that are out of funds", func(t *testing.T) {
+		// Arrange
+		c := New(WithHarvester(&fakeHarvester{}), WithHarvester(&fakeHarvester{})).(*captchasolve)
+
+		// Act
+		c.recordCall(1, c.harvesters[1], 0, errors.New("ERROR_ZERO_BALANCE"))
+
+		// Assert
+		statuses := c.HarvesterStatus()
+		require.Len(t, statuses, 2)
+		require.Equal(t, HarvesterActive, statuses[0].State)
+		require.Equal(t, HarvesterQuarantined, statuses[1].State)
+		require.Equal(t, ErrorClassNoBalance, statuses[1].Reason)
+		require.WithinDuration(t, time.Now().Add(defaultQuarantineRecheck), statuses[1].NextCheck, time.Second)
+	})
+
+	t.Run("one-off failures don't quarantine", func(t *testing.T) {
+		// Arrange
+		c := New(WithHarvester(&fakeHarvester{})).(*captchasolve)
+
+		// Act
+		c.recordCall(0, c.harvesters[0], 0, errors.New("ERROR_CAPTCHA_UNSOLVABLE"))
+
+		// Assert
+		status := c.HarvesterStatus()[0]
+		require.Equal(t, HarvesterActive, status.State)
+		require.Error(t, status.LastError)
+	})
+}
+
+func TestNextHarvester_SkipsQuarantined(t *testing.T) {
+	// Arrange
+	h1, h2 := &fakeHarvester{}, &fakeHarvester{}
+	c := New(WithHarvester(h1), WithHarvester(h2)).(*captchasolve)
+	c.recordCall(0, c.harvesters[0], 0, errors.New("ERROR_IP_BANNED"))
+
+	// Act & Assert
+	for i := 0; i < 3; i++ {
+		index, h, ok := c.nextHarvester()
+		require.True(t, ok)
+		require.Equal(t, 1, index)
+		require.Same(t, h2, h)
+	}
+
+	c.recordCall(1, c.harvesters[1], 0, errors.New("ERROR_KEY_DOES_NOT_EXIST"))
+	_, _, ok := c.nextHarvester()
+	require.False(t, ok, "no harvester should be picked once all are quarantined")
+}
+
+func TestHarvestToken_QuarantinesOutOfFunds(t *testing.T) {
+	// Arrange
+	h := &fakeHarvester{err: errors.New("ERROR_ZERO_BALANCE")}
+	c := New(WithHarvester(h), WithMaxGoroutines(1)).(*captchasolve)
+	defer c.Close()
+
+	// Act
+	c.startHarvesters(context.Background(), 3)
+
+	// Assert
+	require.EqualValues(t, 1, h.calls.Load(), "quarantined harvester shouldn't be retried")
+	require.Equal(t, HarvesterQuarantined, c.HarvesterStatus()[0].State)
+}
+
+func TestRecheckQuarantined(t *testing.T) {
+	t.Run("restores harvesters with funds", func(t *testing.T) {
+		// Arrange
+		h := &balanceHarvester{balance: 10}
+		c := New(WithHarvester(h)).(*captchasolve)
+		c.recordCall(0, c.harvesters[0], 0, errors.New("ERROR_ZERO_BALANCE"))
+
+		// Act
+		c.recheckQuarantined(time.Now().Add(defaultQuarantineRecheck))
+
+		// Assert
+		require.Equal(t, HarvesterActive, c.HarvesterStatus()[0].State)
+	})
+
+	t.Run("backs off while out of funds", func(t *testing.T) {
+		// Arrange
+		h := &balanceHarvester{balance: 0}
+		c := New(WithHarvester(h), WithQuarantineRecheck(time.Minute, 3*time.Minute)).(*captchasolve)
+		c.recordCall(0, c.harvesters[0], 0, errors.New("ERROR_ZERO_BALANCE"))
+		now := time.Now().Add(time.Minute)
+
+		// Act & Assert
+		c.recheckQuarantined(now)
+		require.Equal(t, HarvesterQuarantined, c.HarvesterStatus()[0].State)
+		require.Equal(t, now.Add(2*time.Minute), c.HarvesterStatus()[0].NextCheck)
+
+		c.recheckQuarantined(now.Add(2 * time.Minute))
+		require.Equal(t, now.Add(5*time.Minute), c.HarvesterStatus()[0].NextCheck, "wait should be capped")
+	})
+
+	t.Run("skips harvesters not due yet", func(t *testing.T) {
+		// Arrange
+		h := &balanceHarvester{balance: 10}
+		c := New(WithHarvester(h)).(*captchasolve)
+		c.recordCall(0, c.harvesters[0], 0, errors.New("ERROR_ZERO_BALANCE"))
+
+		// Act
+		c.recheckQuarantined(time.Now())
+
+		// Assert
+		require.Equal(t, HarvesterQuarantined, c.HarvesterStatus()[0].State)
+	})
+}
@@ -0,0 +1,189 @@
+package captchasolve
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	captchatoolsgo "github.com/Matthew17-21/Captcha-Tools/captchatools-go"
+)
+
+const (
+	// latencyWindow is the number of recent solve latencies kept per harvester.
+	latencyWindow = 100
+
+	// minLatencySamples is the number of solve latencies a harvester needs before a
+	// latency percentile is used as its hedge delay.
+	minLatencySamples = 10
+)
+
+// HedgePolicy configures hedged solves. A hedged solve starts with the harvester picked
+// by the Strategy, and starts a backup harvester if no token arrived after a delay, up to
+// MaxHedges backups. The first token wins and the other harvesters are cancelled.
+type HedgePolicy struct {
+	// MaxHedges is the number of backup harvesters a solve may start. Hedging is disabled
+	// when it is 0.
+	MaxHedges int
+
+	// Delay is how long to wait for the last started harvester before starting a backup.
+	Delay time.Duration
+
+	// Percentile, between 0 and 1, waits for that percentile of the last started
+	// harvester's observed solve latency instead of Delay, once enough solves have been
+	// observed. For example 0.9 starts a backup once the solve is slower than 90% of the
+	// harvester's recent solves.
+	Percentile float64
+}
+
+// solve runs a single solve starting with the harvester at index, hedging it if enabled.
+func (c *captchasolve) solve(ctx context.Context, index int, h captchatoolsgo.Harvester, resultsChan chan<- result, additional ...*captchatoolsgo.AdditionalData) {
+	if c.hedgePolicy().MaxHedges <= 0 {
+		c.harvestToken(ctx, index, h, resultsChan, additional...)
+		return
+	}
+	c.hedgedSolve(ctx, index, h, resultsChan, additional...)
+}
+
+// hedgedSolve runs a solve starting with the harvester at index, starting backup harvesters
+// when it is slow or fails. Exactly one result is sent through resultsChan: the first
+// token, or the errors of every harvester if they all failed.
+//
+// The harvesters still running once a token arrives are cancelled. Tokens they return
+// regardless are handed off like any other token rather than wasted.
+func (c *captchasolve) hedgedSolve(ctx context.Context, index int, h captchatoolsgo.Harvester, resultsChan chan<- result, additional ...*captchatoolsgo.AdditionalData) {
+	policy := c.hedgePolicy()
+	var wg sync.WaitGroup
+	ctx, cancel := context.WithCancel(ctx)
+	hedgeResults := make(chan result, 1+policy.MaxHedges)
+	defer func() {
+		// Cancel the losers and keep any token they still returned
+		cancel()
+		wg.Wait()
+		close(hedgeResults)
+		for res := range hedgeResults {
+			if res.err == nil && res.token != nil {
+				c.deliver(res.token)
+			}
+		}
+	}()
+
+	// Start the preferred harvester
+	used := []int{index}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		c.harvestToken(ctx, index, h, hedgeResults, additional...)
+	}()
+	running := 1
+	timer := time.NewTimer(c.hedgeDelay(policy, index))
+	defer timer.Stop()
+
+	var errs []error
+	for running > 0 {
+		startBackup := false
+		select {
+		case res := <-hedgeResults:
+			running--
+			if res.err == nil && res.token != nil {
+				resultsChan <- res
+				return
+			}
+			if res.err == nil {
+				res.err = errNilToken
+			}
+			errs = append(errs, res.err)
+
+			// Don't wait for the delay if nothing is running anymore
+			startBackup = running == 0
+		case <-timer.C:
+			startBackup = true
+		}
+		if !startBackup || len(used) > policy.MaxHedges {
+			continue
+		}
+
+		// Start a backup harvester
+		next, ok := c.startHedge(ctx, &wg, used, hedgeResults, additional...)
+		if !ok {
+			continue
+		}
+		used = append(used, next)
+		running++
+		if len(used) <= policy.MaxHedges {
+			timer.Reset(c.hedgeDelay(policy, next))
+		}
+	}
+	resultsChan <- result{err: errors.Join(errs...)}
+}
+
+// startHedge starts a backup harvester that isn't in used, returning its index. False is
+// returned if no other harvester can be used or the solve budget is spent.
+func (c *captchasolve) startHedge(ctx context.Context, wg *sync.WaitGroup, used []int, hedgeResults chan<- result, additional ...*captchatoolsgo.AdditionalData) (int, bool) {
+	index, h, ok := c.nextHarvester(used...)
+	if !ok || !c.spendBudget() {
+		return 0, false
+	}
+	c.harvesterLogger(index, h).Info("Starting backup harvester")
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		release, err := c.acquire(ctx) // Will block if maxGoroutines solves are running
+		if err != nil {
+			hedgeResults <- result{err: err}
+			return
+		}
+		defer release()
+		c.harvestToken(ctx, index, h, hedgeResults, additional...)
+	}()
+	return index, true
+}
+
+// hedgePolicy returns the HedgePolicy of the instance, which Update may change while
+// solves are running.
+func (c *captchasolve) hedgePolicy() HedgePolicy {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hedge
+}
+
+// hedgeDelay returns how long to wait for the harvester at index before starting a backup.
+func (c *captchasolve) hedgeDelay(policy HedgePolicy, index int) time.Duration {
+	if policy.Percentile > 0 {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if d, ok := c.latencyPercentileLocked(index, policy.Percentile); ok {
+			return d
+		}
+	}
+	return policy.Delay
+}
+
+// latencyPercentileLocked returns the given percentile of the recent solve latencies of
+// the harvester at index i. False is returned until enough solves have been observed.
+// c.mu must be held.
+func (c *captchasolve) latencyPercentileLocked(i int, p float64) (time.Duration, bool) {
+	h, ok := c.health[i]
+	if !ok || len(h.latencies) < minLatencySamples {
+		return 0, false
+	}
+	return percentile(h.latencies, p), true
+}
+
+// percentile returns the p percentile, between 0 and 1, of the given durations.
+func percentile(durations []time.Duration, p float64) time.Duration {
+	sorted := slices.Clone(durations)
+	slices.Sort(sorted)
+	return sortedPercentile(sorted, p)
+}
+
+// sortedPercentile is like percentile, for durations that are already sorted.
+func sortedPercentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(min(max(p, 0), 1)*float64(len(sorted)-1) + 0.5)
+	return sorted[rank]
+}
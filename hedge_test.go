@@ -0,0 +1,144 @@
+package captchasolve
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHedgedSolve(t *testing.T) {
+	t.Run("backup wins when the primary is slow", func(t *testing.T) {
+		// Arrange
+		slow, fast := &fakeHarvester{delay: time.Hour}, &fakeHarvester{}
+		c := New(WithHarvester(slow), WithHarvester(fast), WithHedging(HedgePolicy{MaxHedges: 1, Delay: 10 * time.Millisecond})).(*captchasolve)
+		defer c.Close()
+		resultsChan := make(chan result, 1)
+
+		// Act, returns once the primary has been cancelled
+		c.hedgedSolve(context.Background(), 0, slow, resultsChan)
+
+		// Assert
+		res := <-resultsChan
+		require.NoError(t, res.err)
+		assert.Equal(t, "fake-token", res.token.Token)
+		assert.EqualValues(t, 1, fast.calls.Load())
+		assert.Zero(t, c.queue.Len())
+	})
+
+	t.Run("no backup when the primary is fast", func(t *testing.T) {
+		// Arrange
+		primary, backup := &fakeHarvester{}, &fakeHarvester{}
+		c := New(WithHarvester(primary), WithHarvester(backup), WithHedging(HedgePolicy{MaxHedges: 1, Delay: time.Hour})).(*captchasolve)
+		defer c.Close()
+		resultsChan := make(chan result, 1)
+
+		// Act
+		c.hedgedSolve(context.Background(), 0, primary, resultsChan)
+
+		// Assert
+		res := <-resultsChan
+		require.NoError(t, res.err)
+		assert.Zero(t, backup.calls.Load())
+	})
+
+	t.Run("backup starts right away when the primary fails", func(t *testing.T) {
+		// Arrange
+		failing, backup := &fakeHarvester{err: errors.New("502 bad gateway")}, &fakeHarvester{}
+		c := New(WithHarvester(failing), WithHarvester(backup), WithHedging(HedgePolicy{MaxHedges: 1, Delay: time.Hour})).(*captchasolve)
+		defer c.Close()
+		resultsChan := make(chan result, 1)
+
+		// Act
+		c.hedgedSolve(context.Background(), 0, failing, resultsChan)
+
+		// Assert
+		res := <-resultsChan
+		require.NoError(t, res.err)
+		assert.EqualValues(t, 1, backup.calls.Load())
+	})
+
+	t.Run("reports every error when all harvesters fail", func(t *testing.T) {
+		// Arrange
+		err1, err2 := errors.New("502 bad gateway"), errors.New("ERROR_CAPTCHA_UNSOLVABLE")
+		h1, h2, h3 := &fakeHarvester{err: err1}, &fakeHarvester{err: err2}, &fakeHarvester{}
+		c := New(WithHarvester(h1), WithHarvester(h2), WithHarvester(h3), WithHedging(HedgePolicy{MaxHedges: 1})).(*captchasolve)
+		defer c.Close()
+		resultsChan := make(chan result, 1)
+
+		// Act
+		c.hedgedSolve(context.Background(), 0, h1, resultsChan)
+
+		// Assert
+		res := <-resultsChan
+		require.ErrorIs(t, res.err, err1)
+		require.ErrorIs(t, res.err, err2)
+		assert.Zero(t, h3.calls.Load(), "no more than MaxHedges backups should be started")
+	})
+
+	t.Run("backups are taken from the solve budget", func(t *testing.T) {
+		// Arrange
+		slow, backup := &fakeHarvester{delay: 50 * time.Millisecond}, &fakeHarvester{}
+		c := New(WithHarvester(slow), WithHarvester(backup), WithSolveBudget(1), WithHedging(HedgePolicy{MaxHedges: 1})).(*captchasolve)
+		defer c.Close()
+
+		// Act
+		token, err := c.GetToken(context.Background())
+
+		// Assert
+		require.NoError(t, err)
+		require.NotNil(t, token)
+		assert.Zero(t, backup.calls.Load())
+	})
+
+	t.Run("hedges solves started by GetToken", func(t *testing.T) {
+		// Arrange
+		slow, fast := &fakeHarvester{delay: time.Hour}, &fakeHarvester{}
+		c := New(WithHarvester(slow), WithHarvester(fast), WithHedging(HedgePolicy{MaxHedges: 1, Delay: 10 * time.Millisecond})).(*captchasolve)
+		defer c.Close()
+
+		// Act
+		token, err := c.GetToken(context.Background())
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, "fake-token", token.Token)
+	})
+}
+
+func TestHedgeDelay(t *testing.T) {
+	c := New(WithHarvester(&fakeHarvester{}), WithHedging(HedgePolicy{MaxHedges: 1, Delay: time.Minute, Percentile: 0.9})).(*captchasolve)
+
+	require.Equal(t, time.Minute, c.hedgeDelay(c.hedge, 0), "delay should be used until enough solves are observed")
+
+	for i := 1; i <= minLatencySamples; i++ {
+		c.recordCall(0, c.harvesters[0], time.Duration(i)*time.Second, nil)
+	}
+	require.Equal(t, 9*time.Second, c.hedgeDelay(c.hedge, 0))
+}
+
+func TestRecordCall_LatencyWindow(t *testing.T) {
+	c := New(WithHarvester(&fakeHarvester{})).(*captchasolve)
+
+	for i := 0; i < latencyWindow+10; i++ {
+		c.recordCall(0, c.harvesters[0], time.Duration(i)*time.Millisecond, nil)
+	}
+
+	latencies := c.health[0].latencies
+	require.Len(t, latencies, latencyWindow)
+	require.NotContains(t, latencies, 9*time.Millisecond, "oldest latencies should be overwritten")
+	require.Contains(t, latencies, time.Duration(latencyWindow+9)*time.Millisecond)
+}
+
+func TestPercentile(t *testing.T) {
+	durations := []time.Duration{5, 1, 4, 2, 3}
+
+	assert.Equal(t, time.Duration(1), percentile(durations, 0))
+	assert.Equal(t, time.Duration(3), percentile(durations, 0.5))
+	assert.Equal(t, time.Duration(5), percentile(durations, 1))
+	assert.Zero(t, percentile(nil, 0.5))
+	assert.Equal(t, []time.Duration{5, 1, 4, 2, 3}, durations, "durations shouldn't be reordered")
+}
@@ -63,6 +63,45 @@ func (q *SliceQueue[T]) Peek() (T, error) {
 	return q.data[0], nil
 }
 
+// RemoveFunc removes every element for which remove returns true, keeping the order
+// of the remaining elements. It returns the number of elements removed.
+func (q *SliceQueue[T]) RemoveFunc(remove func(T) bool) int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	kept := q.data[:0]
+	for _, val := range q.data {
+		if !remove(val) {
+			kept = append(kept, val)
+		}
+	}
+
+	// Zero out the tail so removed elements can be garbage collected
+	var zero T
+	for i := len(kept); i < len(q.data); i++ {
+		q.data[i] = zero
+	}
+	removed := len(q.data) - len(kept)
+	q.data = kept
+	return removed
+}
+
+// SetCapacity changes the maximum capacity of the queue, with 0 meaning unbounded. If the
+// queue holds more elements than the new capacity, the oldest ones are removed. It returns
+// the number of elements removed.
+func (q *SliceQueue[T]) SetCapacity(maxCapacity int) int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.maxCapacity = max(maxCapacity, 0)
+	if q.maxCapacity == 0 || len(q.data) <= q.maxCapacity {
+		return 0
+	}
+	removed := len(q.data) - q.maxCapacity
+	q.data = append(make([]T, 0, q.maxCapacity), q.data[removed:]...)
+	return removed
+}
+
 // Len returns the current number of elements in the queue.
 func (q *SliceQueue[T]) Len() int {
 	q.mutex.RLock()
@@ -143,6 +143,78 @@ func TestSliceQueue_Clear(t *testing.T) {
 	require.ErrorIs(t, err, ErrQueueEmpty)
 }
 
+func TestSliceQueue_RemoveFunc(t *testing.T) {
+	t.Run("empty queue", func(t *testing.T) {
+		q := NewSliceQueue[int]()
+		removed := q.RemoveFunc(func(int) bool { return true })
+		require.Zero(t, removed)
+	})
+
+	t.Run("removes matching elements in order", func(t *testing.T) {
+		q := NewSliceQueue[int]()
+		for i := 1; i <= 6; i++ {
+			q.Enqueue(i)
+		}
+
+		removed := q.RemoveFunc(func(v int) bool { return v%2 == 0 })
+		require.Equal(t, 3, removed)
+		require.Equal(t, 3, q.Len())
+
+		// Remaining elements keep their FIFO order
+		for _, want := range []int{1, 3, 5} {
+			got, err := q.Dequeue()
+			require.NoError(t, err)
+			require.Equal(t, want, got)
+		}
+	})
+
+	t.Run("frees capacity in bounded queue", func(t *testing.T) {
+		q := NewSliceQueue[int](2)
+		q.Enqueue(1)
+		q.Enqueue(2)
+
+		q.RemoveFunc(func(v int) bool { return v == 1 })
+		require.NoError(t, q.Enqueue(3))
+	})
+}
+
+func TestSliceQueue_SetCapacity(t *testing.T) {
+	t.Run("grows bounded queue", func(t *testing.T) {
+		q := NewSliceQueue[int](1)
+		q.Enqueue(1)
+
+		removed := q.SetCapacity(2)
+		require.Zero(t, removed)
+		require.NoError(t, q.Enqueue(2))
+		require.ErrorIs(t, q.Enqueue(3), ErrQueueFull)
+	})
+
+	t.Run("shrinking removes oldest elements", func(t *testing.T) {
+		q := NewSliceQueue[int](4)
+		for i := 1; i <= 4; i++ {
+			q.Enqueue(i)
+		}
+
+		removed := q.SetCapacity(2)
+		require.Equal(t, 2, removed)
+		require.ErrorIs(t, q.Enqueue(5), ErrQueueFull)
+		for _, want := range []int{3, 4} {
+			got, err := q.Dequeue()
+			require.NoError(t, err)
+			require.Equal(t, want, got)
+		}
+	})
+
+	t.Run("zero capacity becomes unbounded", func(t *testing.T) {
+		q := NewSliceQueue[int](1)
+		q.Enqueue(1)
+
+		removed := q.SetCapacity(0)
+		require.Zero(t, removed)
+		require.NoError(t, q.Enqueue(2))
+	})
+}
+
 func TestSliceQueue_ConcurrentAccess(t *testing.T) {
 	q := NewSliceQueue[int]()
 	const numGoroutines = 10
@@ -0,0 +1,108 @@
+// Package serving holds what the HTTP and gRPC servers have in common: checking API keys
+// and remembering the tokens handed out so they can be reported.
+package serving
+
+import (
+	"crypto/subtle"
+	"strings"
+	"sync"
+
+	"github.com/Matthew17-21/CaptchaSolve"
+)
+
+// Keys is a set of API keys. The zero value accepts every key.
+type Keys [][]byte
+
+// NewKeys returns the set of the given keys, ignoring empty ones.
+func NewKeys(keys ...string) Keys {
+	var k Keys
+	for _, key := range keys {
+		if key != "" {
+			k = append(k, []byte(key))
+		}
+	}
+	return k
+}
+
+// Allow reports whether key is one of the keys, or whether the set is empty.
+func (k Keys) Allow(key string) bool {
+	if len(k) == 0 {
+		return true
+	}
+	if key == "" {
+		return false
+	}
+	allowed := false
+	for _, apiKey := range k {
+		// Compare every key in constant time so timings don't leak which one nearly matched
+		if subtle.ConstantTimeCompare([]byte(key), apiKey) == 1 {
+			allowed = true
+		}
+	}
+	return allowed
+}
+
+// KeyFromHeaders returns the API key carried by a bearer authorization header, or else
+// by an x-api-key header.
+func KeyFromHeaders(authorization, apiKey string) string {
+	if bearer, ok := strings.CutPrefix(authorization, "Bearer "); ok {
+		return bearer
+	}
+	return apiKey
+}
+
+// minPruneSize is the number of tokens remembered before Tokens first looks for expired
+// ones to forget.
+const minPruneSize = 1024
+
+// Tokens remembers the tokens handed out, by site, until they expire.
+type Tokens struct {
+	mu      sync.Mutex
+	served  map[string]servedToken // By token
+	pruneAt int                    // Size of served at which the expired tokens are forgotten
+}
+
+// servedToken is a token handed out for a site.
+type servedToken struct {
+	site   string
+	answer *captchasolve.CaptchaAnswer
+}
+
+// Add remembers token as handed out for site. The tokens that expired are forgotten
+// once the number of tokens remembered doubled since they were last looked for, so
+// adding a token takes constant time on average.
+func (t *Tokens) Add(site string, token *captchasolve.CaptchaAnswer) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.served == nil {
+		t.served = make(map[string]servedToken)
+	}
+	if len(t.served) >= max(t.pruneAt, minPruneSize) {
+		t.pruneLocked()
+	}
+	t.served[token.Token] = servedToken{site: site, answer: token}
+}
+
+// pruneLocked forgets the tokens that expired, and sets the size at which it is done
+// again to twice the number of tokens left, or minPruneSize. t.mu must be held.
+func (t *Tokens) pruneLocked() {
+	for key, served := range t.served {
+		if served.answer.IsExpired() {
+			delete(t.served, key)
+		}
+	}
+	t.pruneAt = max(2*len(t.served), minPruneSize)
+}
+
+// Take returns and forgets the token handed out for site, so a token is only reported
+// once. It returns false if the token wasn't handed out for site or has expired.
+func (t *Tokens) Take(site, token string) (*captchasolve.CaptchaAnswer, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	served, ok := t.served[token]
+	if !ok || served.site != site || served.answer.IsExpired() {
+		return nil, false
+	}
+	delete(t.served, token)
+	return served.answer, true
+}
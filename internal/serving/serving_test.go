@@ -0,0 +1,98 @@
+package serving
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	captchatoolsgo "github.com/Matthew17-21/Captcha-Tools/captchatools-go"
+	"github.com/Matthew17-21/CaptchaSolve"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeHarvester is a harvester returning a token right away.
+type fakeHarvester struct{}
+
+func (f fakeHarvester) GetToken(additional ...*captchatoolsgo.AdditionalData) (*captchatoolsgo.CaptchaAnswer, error) {
+	return f.GetTokenWithContext(context.Background(), additional...)
+}
+
+func (fakeHarvester) GetTokenWithContext(context.Context, ...*captchatoolsgo.AdditionalData) (*captchatoolsgo.CaptchaAnswer, error) {
+	return &captchatoolsgo.CaptchaAnswer{Token: "token"}, nil
+}
+
+func (fakeHarvester) GetBalance() (float32, error) { return 1, nil }
+
+func TestKeys(t *testing.T) {
+	keys := NewKeys("first", "", "second")
+
+	assert.True(t, keys.Allow("first"))
+	assert.True(t, keys.Allow("second"))
+	assert.False(t, keys.Allow("third"))
+	assert.False(t, keys.Allow(""))
+	assert.True(t, NewKeys("").Allow(""), "an empty set allows every key")
+}
+
+func TestKeyFromHeaders(t *testing.T) {
+	assert.Equal(t, "bearer", KeyFromHeaders("Bearer bearer", "header"))
+	assert.Equal(t, "header", KeyFromHeaders("Basic abc", "header"))
+	assert.Empty(t, KeyFromHeaders("", ""))
+}
+
+func TestTokens(t *testing.T) {
+	t.Run("hands tokens back once", func(t *testing.T) {
+		// Arrange
+		solver := captchasolve.New(captchasolve.WithHarvester(fakeHarvester{}))
+		defer solver.Close()
+		token, err := solver.GetToken(context.Background())
+		require.NoError(t, err)
+		var tokens Tokens
+		tokens.Add("retailer", token)
+
+		// Act
+		_, otherSite := tokens.Take("shop", "token")
+		got, ok := tokens.Take("retailer", "token")
+		_, again := tokens.Take("retailer", "token")
+
+		// Assert
+		assert.False(t, otherSite)
+		require.True(t, ok)
+		assert.Same(t, token, got)
+		assert.False(t, again)
+	})
+
+	t.Run("forgets expired tokens", func(t *testing.T) {
+		// Arrange
+		var tokens Tokens
+		tokens.Add("retailer", &captchasolve.CaptchaAnswer{CaptchaAnswer: captchatoolsgo.CaptchaAnswer{Token: "token"}})
+
+		// Act
+		_, ok := tokens.Take("retailer", "token")
+
+		// Assert
+		require.False(t, ok)
+	})
+	t.Run("prunes expired tokens once enough are remembered", func(t *testing.T) {
+		// Arrange
+		var tokens Tokens
+		expired := time.Now().Add(-time.Second)
+		for i := 0; i < minPruneSize-1; i++ {
+			tokens.Add("retailer", captchasolve.NewRemoteAnswer(captchatoolsgo.CaptchaAnswer{Token: fmt.Sprint("expired-", i)}, "", expired))
+		}
+		valid := captchasolve.NewRemoteAnswer(captchatoolsgo.CaptchaAnswer{Token: "valid"}, "", time.Now().Add(time.Minute))
+		tokens.Add("retailer", valid)
+		require.Len(t, tokens.served, minPruneSize, "expired tokens should be kept until the threshold")
+
+		// Act
+		tokens.Add("retailer", captchasolve.NewRemoteAnswer(captchatoolsgo.CaptchaAnswer{Token: "other"}, "", time.Now().Add(time.Minute)))
+
+		// Assert
+		require.Len(t, tokens.served, 2)
+		assert.Equal(t, minPruneSize, tokens.pruneAt, "the threshold shouldn't go below the minimum")
+		got, ok := tokens.Take("retailer", "valid")
+		require.True(t, ok)
+		assert.Same(t, valid, got)
+	})
+}
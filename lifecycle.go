@@ -0,0 +1,173 @@
+package captchasolve
+
+import (
+	"context"
+	"sync"
+)
+
+// ShutdownPolicy controls what happens to in-flight solves and queued tokens when a
+// CaptchaSolve is shut down.
+type ShutdownPolicy int
+
+const (
+	// ShutdownDiscard cancels in-flight solves right away and clears the queue.
+	ShutdownDiscard ShutdownPolicy = iota
+
+	// ShutdownDrain lets in-flight solves finish during Shutdown and hands their tokens
+	// to the callers still waiting. Tokens nobody was waiting for are kept in the queue,
+	// and in the token file set with WithTokenFile for the next instance to reload.
+	ShutdownDrain
+)
+
+// Start launches the background workers of the instance: the prefill worker, the
+// expiry sweeper and the worker rechecking quarantined harvesters.
+// The workers run until ctx is cancelled or the instance is shut down. Calling Start
+// more than once has no effect.
+func (c *captchasolve) Start(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return ErrPoolClosed
+	}
+	if c.stopWorkers != nil {
+		return nil
+	}
+
+	// Stop the workers when either ctx or the instance is done
+	ctx, c.stopWorkers = context.WithCancel(ctx)
+	context.AfterFunc(c.ctx, c.stopWorkers)
+	c.workersCtx = ctx
+
+	// Keep the pool topped up in the background
+	c.startPrefillLocked()
+
+	// Evict expired tokens in the background
+	if c.sweepInterval > 0 {
+		c.workers.Add(1)
+		go func() {
+			defer c.workers.Done()
+			c.sweeper(ctx)
+		}()
+	}
+
+	// Put quarantined harvesters back in rotation once they can be used again
+	c.workers.Add(1)
+	go func() {
+		defer c.workers.Done()
+		c.quarantine(ctx)
+	}()
+	return nil
+}
+
+// startPrefillLocked launches the prefill worker if prefilling is enabled, the background
+// workers have been started and it isn't running yet. c.mu must be held.
+func (c *captchasolve) startPrefillLocked() {
+	if c.prefillMax <= 0 || c.workersCtx == nil || c.prefilling {
+		return
+	}
+	c.prefilling = true
+	ctx := c.workersCtx
+	c.workers.Add(1)
+	go func() {
+		defer c.workers.Done()
+		c.prefill(ctx)
+	}()
+}
+
+// Close shuts the instance down immediately: in-flight solves are cancelled, waiting
+// callers get ErrPoolClosed and queued tokens are handled according to the shutdown
+// policy. It returns once every goroutine started by the instance has exited.
+func (c *captchasolve) Close() error {
+	return c.shutdown(context.Background(), false)
+}
+
+// Shutdown gracefully shuts the instance down. No new solves are started and, with
+// ShutdownDrain, in-flight solves are given until ctx is done to finish before being
+// cancelled. It returns once every goroutine started by the instance has exited, or
+// ctx.Err() if ctx is done first.
+func (c *captchasolve) Shutdown(ctx context.Context) error {
+	return c.shutdown(ctx, c.shutdownPolicy == ShutdownDrain)
+}
+
+// shutdown implements Close and Shutdown. When drain is set, in-flight solves are
+// waited on before they are cancelled.
+func (c *captchasolve) shutdown(ctx context.Context, drain bool) error {
+	c.mu.Lock()
+	alreadyClosed := c.closed
+	c.closed = true
+	stopWorkers := c.stopWorkers
+	c.mu.Unlock()
+	if alreadyClosed {
+		if err := c.wait(ctx); err != nil {
+			return err
+		}
+		return c.closeTokenFile()
+	}
+	c.logger.Info("Shutting down...")
+
+	// Stop the background workers so no new solves are started
+	if stopWorkers != nil {
+		stopWorkers()
+	}
+
+	// Let in-flight solves hand their tokens off
+	var drainErr error
+	if drain {
+		drainErr = waitContext(ctx, &c.solves)
+	}
+
+	// Cancel whatever is still running and release the waiting callers
+	c.cancel()
+	c.mu.Lock()
+	c.failWaitersLocked(ErrPoolClosed)
+	c.reportPoolLocked()
+	c.mu.Unlock()
+
+	if err := c.wait(ctx); err != nil {
+		return err
+	}
+	if c.shutdownPolicy == ShutdownDiscard {
+		c.mu.Lock()
+		c.clearQueueLocked()
+		c.mu.Unlock()
+	}
+	if err := c.closeTokenFile(); err != nil {
+		return err
+	}
+	c.logger.Info("Shut down")
+	return drainErr
+}
+
+// wait blocks until every goroutine started by the instance has exited or ctx is done.
+func (c *captchasolve) wait(ctx context.Context) error {
+	if err := waitContext(ctx, &c.workers); err != nil {
+		return err
+	}
+	return waitContext(ctx, &c.solves)
+}
+
+// detach returns a context that keeps the values of ctx but, instead of following its
+// cancellation, is only cancelled when the instance shuts down.
+func (c *captchasolve) detach(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(c.ctx, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// waitContext waits for wg, giving up once ctx is done.
+func waitContext(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
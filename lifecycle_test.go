@@ -0,0 +1,117 @@
+package captchasolve
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+)
+
+func TestClose(t *testing.T) {
+	t.Run("cancels in-flight solves and releases waiters", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		// Arrange
+		h := &fakeHarvester{delay: time.Hour}
+		solver := New(WithHarvester(h), WithSurplus(2)).(*captchasolve)
+		errs := make(chan error, 1)
+		go func() {
+			_, err := solver.GetToken(context.Background())
+			errs <- err
+		}()
+		waitForWaiters(t, solver, 1)
+
+		// Act
+		require.NoError(t, solver.Close())
+
+		// Assert
+		require.ErrorIs(t, <-errs, ErrPoolClosed)
+		require.EqualValues(t, 3, h.calls.Load())
+		require.Zero(t, solver.inFlight)
+	})
+
+	t.Run("stops background workers", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		// Arrange
+		solver := New(WithHarvester(&fakeHarvester{}), WithPrefill(1, 2))
+		require.NoError(t, solver.Start(context.Background()))
+
+		// Act & Assert
+		require.NoError(t, solver.Close())
+	})
+
+	t.Run("discards queued tokens by default", func(t *testing.T) {
+		// Arrange
+		solver := New().(*captchasolve)
+		solver.queue.Enqueue(&CaptchaAnswer{solvedAt: time.Now()})
+
+		// Act
+		require.NoError(t, solver.Close())
+
+		// Assert
+		require.Zero(t, solver.queue.Len())
+	})
+
+	t.Run("solver can't be used once closed", func(t *testing.T) {
+		// Arrange
+		solver := New(WithHarvester(&fakeHarvester{}))
+		require.NoError(t, solver.Close())
+
+		// Act
+		_, err := solver.GetToken(context.Background())
+
+		// Assert
+		require.ErrorIs(t, err, ErrPoolClosed)
+		require.ErrorIs(t, solver.Start(context.Background()), ErrPoolClosed)
+		require.NoError(t, solver.Close(), "closing twice should be a no-op")
+	})
+}
+
+func TestShutdown(t *testing.T) {
+	t.Run("drain lets in-flight solves finish", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		// Arrange
+		h := &fakeHarvester{delay: 50 * time.Millisecond}
+		solver := New(WithHarvester(h), WithSurplus(1), WithShutdownPolicy(ShutdownDrain)).(*captchasolve)
+		tokens := make(chan *CaptchaAnswer, 1)
+		go func() {
+			token, _ := solver.GetToken(context.Background())
+			tokens <- token
+		}()
+		waitForWaiters(t, solver, 1)
+
+		// Act
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		require.NoError(t, solver.Shutdown(ctx))
+
+		// Assert
+		require.NotNil(t, <-tokens, "waiting caller should get the drained token")
+		require.Equal(t, 1, solver.queue.Len(), "surplus token should be kept")
+	})
+
+	t.Run("gives up once ctx is done", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		// Arrange
+		h := &fakeHarvester{delay: time.Hour}
+		solver := New(WithHarvester(h), WithSurplus(1), WithShutdownPolicy(ShutdownDrain))
+		require.Eventually(t, func() bool {
+			solver.(*captchasolve).dispatch(context.Background())
+			return h.calls.Load() == 1
+		}, time.Second, time.Millisecond)
+
+		// Act
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		err := solver.Shutdown(ctx)
+
+		// Assert
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.NoError(t, solver.Close(), "goroutines should exit once cancelled")
+	})
+}
@@ -1,7 +1,13 @@
 package captchasolve
 
 import (
+	"context"
+	"fmt"
 	"log"
+	"log/slog"
+	"runtime"
+	"strings"
+	"time"
 )
 
 type Logger interface {
@@ -11,24 +17,157 @@ type Logger interface {
 	Error(format string, args ...any)
 }
 
-type logger struct{}
+// FieldLogger is a Logger that can attach structured key/value fields, such as the
+// harvester or the token ID, to its records. The loggers returned by NewLogger and
+// NewSlogLogger implement it. The fields of other loggers are appended to the message.
+type FieldLogger interface {
+	Logger
 
+	// With returns a Logger adding the given key/value pairs to every record.
+	With(args ...any) Logger
+}
+
+// WithFields returns a Logger adding the given key/value pairs to every record of l, as
+// fields if l is a FieldLogger and appended to the message otherwise. Errors are logged
+// under the "error" key.
+func WithFields(l Logger, args ...any) Logger {
+	if _, ok := l.(*silentLogger); ok {
+		return l // Don't format fields nobody will read
+	}
+	if fl, ok := l.(FieldLogger); ok {
+		return fl.With(args...)
+	}
+	if fl, ok := l.(*fieldLogger); ok {
+		return &fieldLogger{Logger: fl.Logger, fields: appendFields(fl.fields, args)}
+	}
+	return &fieldLogger{Logger: l, fields: args}
+}
+
+// fieldLogger appends its fields to the messages of a Logger that doesn't support them.
+type fieldLogger struct {
+	Logger
+	fields []any
+}
+
+func (l *fieldLogger) Debug(format string, args ...any) {
+	l.Logger.Debug(format+" %s", append(args, formatFields(l.fields))...)
+}
+
+func (l *fieldLogger) Info(format string, args ...any) {
+	l.Logger.Info(format+" %s", append(args, formatFields(l.fields))...)
+}
+
+func (l *fieldLogger) Warn(format string, args ...any) {
+	l.Logger.Warn(format+" %s", append(args, formatFields(l.fields))...)
+}
+
+func (l *fieldLogger) Error(format string, args ...any) {
+	l.Logger.Error(format+" %s", append(args, formatFields(l.fields))...)
+}
+
+// appendFields returns the key/value pairs of fields followed by those of args, without
+// modifying fields.
+func appendFields(fields, args []any) []any {
+	return append(fields[:len(fields):len(fields)], args...)
+}
+
+// formatFields formats key/value pairs as key=value, separated by spaces.
+func formatFields(fields []any) string {
+	var b strings.Builder
+	for i := 0; i < len(fields); i += 2 {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		if i+1 == len(fields) {
+			fmt.Fprintf(&b, "!BADKEY=%v", fields[i])
+			break
+		}
+		fmt.Fprintf(&b, "%v=%v", fields[i], fields[i+1])
+	}
+	return b.String()
+}
+
+type logger struct {
+	level  slog.Level
+	fields []any
+}
+
+// NewLogger returns a Logger writing records of level Info and above through the
+// standard log package.
 func NewLogger() Logger {
-	return &logger{}
+	return NewLoggerWithLevel(slog.LevelInfo)
+}
+
+// NewLoggerWithLevel returns a Logger writing records of the given level and above
+// through the standard log package.
+//
+// Example:
+//
+//	WithLogger(NewLoggerWithLevel(slog.LevelWarn))
+func NewLoggerWithLevel(level slog.Level) Logger {
+	return &logger{level: level}
+}
+
+func (l *logger) With(args ...any) Logger {
+	return &logger{level: l.level, fields: appendFields(l.fields, args)}
+}
+
+func (l *logger) Debug(format string, args ...any) { l.log(slog.LevelDebug, format, args) }
+
+func (l *logger) Info(format string, args ...any) { l.log(slog.LevelInfo, format, args) }
+
+func (l *logger) Warn(format string, args ...any) { l.log(slog.LevelWarn, format, args) }
+
+func (l *logger) Error(format string, args ...any) { l.log(slog.LevelError, format, args) }
+
+func (l *logger) log(level slog.Level, format string, args []any) {
+	if level < l.level {
+		return
+	}
+	msg := fmt.Sprintf(format, args...)
+	if len(l.fields) > 0 {
+		msg += " " + formatFields(l.fields)
+	}
+	log.Printf("%s %s\n", level, msg)
 }
 
-func (logger) Debug(_ string, _ ...any) {}
+type slogLogger struct {
+	l *slog.Logger
+}
 
-func (logger) Info(format string, args ...any) {
-	log.Printf(format+"\n", args...)
+// NewSlogLogger returns a Logger writing structured records to l. Fields, such as the
+// harvester and the token ID, are added to the records as attributes.
+//
+// Example:
+//
+//	WithLogger(NewSlogLogger(slog.New(slog.NewJSONHandler(os.Stderr, nil))))
+func NewSlogLogger(l *slog.Logger) Logger {
+	return &slogLogger{l: l}
 }
 
-func (logger) Warn(format string, args ...any) {
-	log.Printf(format+"\n", args...)
+func (s *slogLogger) With(args ...any) Logger {
+	return &slogLogger{l: s.l.With(args...)}
 }
 
-func (logger) Error(format string, args ...any) {
-	log.Printf(format+"\n", args...)
+func (s *slogLogger) Debug(format string, args ...any) { s.log(slog.LevelDebug, format, args) }
+
+func (s *slogLogger) Info(format string, args ...any) { s.log(slog.LevelInfo, format, args) }
+
+func (s *slogLogger) Warn(format string, args ...any) { s.log(slog.LevelWarn, format, args) }
+
+func (s *slogLogger) Error(format string, args ...any) { s.log(slog.LevelError, format, args) }
+
+func (s *slogLogger) log(level slog.Level, format string, args []any) {
+	ctx := context.Background()
+	if !s.l.Enabled(ctx, level) {
+		return
+	}
+
+	// Report the caller of the Logger method as the source of the record
+	var pcs [1]uintptr
+	runtime.Callers(3, pcs[:])
+	r := slog.NewRecord(time.Now(), level, fmt.Sprintf(format, args...), pcs[0])
+	_ = s.l.Handler().Handle(ctx, r)
 }
 
 type silentLogger struct {
@@ -1,6 +1,18 @@
 package captchasolve
 
-import "github.com/test-go/testify/mock"
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/test-go/testify/mock"
+)
 
 type mockLogger struct {
 	mock.Mock
@@ -18,3 +30,112 @@ func (m *mockLogger) Warn(format string, args ...interface{}) {
 func (m *mockLogger) Debug(format string, args ...interface{}) {
 	m.Called(format, args)
 }
+
+func TestLogger(t *testing.T) {
+	// captureLog returns what is written through the standard log package while f runs.
+	captureLog := func(f func()) string {
+		var buf bytes.Buffer
+		log.SetOutput(&buf)
+		defer log.SetOutput(os.Stderr)
+		f()
+		return buf.String()
+	}
+
+	t.Run("writes records of the minimum level and above", func(t *testing.T) {
+		l := NewLoggerWithLevel(slog.LevelWarn)
+
+		out := captureLog(func() {
+			l.Info("info %d", 1)
+			l.Warn("warn %d", 2)
+		})
+
+		assert.NotContains(t, out, "info 1")
+		assert.Contains(t, out, "WARN warn 2")
+	})
+
+	t.Run("skips debug records by default", func(t *testing.T) {
+		l := NewLogger()
+
+		out := captureLog(func() {
+			l.Debug("debug")
+			l.Info("info")
+		})
+
+		assert.NotContains(t, out, "debug")
+		assert.Contains(t, out, "INFO info")
+	})
+
+	t.Run("appends fields", func(t *testing.T) {
+		l := WithFields(NewLogger(), "harvester", 1)
+
+		out := captureLog(func() {
+			WithFields(l, "attempt", 2).Error("failed: %v", "boom")
+		})
+
+		assert.Contains(t, out, "ERROR failed: boom harvester=1 attempt=2")
+	})
+}
+
+func TestSlogLogger(t *testing.T) {
+	t.Run("writes structured records", func(t *testing.T) {
+		// Arrange
+		var buf bytes.Buffer
+		l := NewSlogLogger(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{AddSource: true})))
+
+		// Act
+		WithFields(l, "harvester", 1, "attempt", 2).Warn("Retrying in %v", time.Second)
+
+		// Assert
+		var record map[string]any
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+		assert.Equal(t, "WARN", record["level"])
+		assert.Equal(t, "Retrying in 1s", record["msg"])
+		assert.EqualValues(t, 1, record["harvester"])
+		assert.EqualValues(t, 2, record["attempt"])
+		source := record["source"].(map[string]any)
+		assert.Contains(t, source["file"], "logger_test.go", "the caller should be reported as the source")
+	})
+
+	t.Run("skips records below the handler's level", func(t *testing.T) {
+		// Arrange
+		var buf bytes.Buffer
+		l := NewSlogLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelError})))
+
+		// Act
+		l.Info("info")
+		l.Error("error")
+
+		// Assert
+		assert.NotContains(t, buf.String(), "msg=info")
+		assert.Contains(t, buf.String(), "msg=error")
+	})
+}
+
+func TestWithFields(t *testing.T) {
+	t.Run("appends fields to the message of other loggers", func(t *testing.T) {
+		// Arrange
+		mockLogger := &mockLogger{}
+		mockLogger.On("Info", "got %d tokens %s", []interface{}{3, "harvester=1 attempt=2"}).Return()
+
+		// Act
+		l := WithFields(WithFields(mockLogger, "harvester", 1), "attempt", 2)
+		l.Info("got %d tokens", 3)
+
+		// Assert
+		mockLogger.AssertExpectations(t)
+	})
+
+	t.Run("doesn't share fields between loggers", func(t *testing.T) {
+		base := WithFields(&mockLogger{}, "harvester", 1)
+
+		first := WithFields(base, "attempt", 1).(*fieldLogger)
+		second := WithFields(base, "attempt", 2).(*fieldLogger)
+
+		assert.Equal(t, []any{"harvester", 1, "attempt", 1}, first.fields)
+		assert.Equal(t, []any{"harvester", 1, "attempt", 2}, second.fields)
+	})
+
+	t.Run("formats a key without value", func(t *testing.T) {
+		assert.Equal(t, "a=1 !BADKEY=b", formatFields([]any{"a", 1, "b"}))
+	})
+}
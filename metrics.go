@@ -0,0 +1,96 @@
+package captchasolve
+
+import (
+	"time"
+
+	captchatoolsgo "github.com/Matthew17-21/Captcha-Tools/captchatools-go"
+)
+
+// Metrics receives measurements from a solver, for example to export them to a monitoring
+// system. The metrics subpackage implements it with Prometheus.
+//
+// Methods are called synchronously, some while the solver holds its lock, so they must be
+// fast and safe for concurrent use. Harvesters are identified by their index, in the order
+// they were configured, and their provider.
+type Metrics interface {
+	// PoolChanged reports the number of queued tokens, waiting callers and in-flight solves
+	// whenever one of them changes.
+	PoolChanged(queued, waiters, inFlight int)
+
+	// TokenHarvested reports a token returned by a harvester and how long the call took.
+	TokenHarvested(index int, provider string, latency time.Duration)
+
+	// TokenServed reports a token returned by GetToken.
+	TokenServed()
+
+	// TokensExpired reports queued tokens evicted because they expired before being used.
+	TokensExpired(n int)
+
+	// TokensDiscarded reports tokens thrown away while still valid, for example by
+	// ClearTokens or because the queue was full.
+	TokensDiscarded(n int)
+
+	// HarvesterFailed reports a failed call to a harvester and the class of its error.
+	HarvesterFailed(index int, provider string, class ErrorClass)
+
+	// HarvesterRetried reports a failed call to a harvester being retried.
+	HarvesterRetried(index int, provider string)
+}
+
+// reportPoolLocked reports the state of the pool to the metrics. c.mu must be held.
+func (c *captchasolve) reportPoolLocked() {
+	if c.metrics != nil {
+		c.metrics.PoolChanged(c.queuedLocked(), len(c.waiters), c.inFlight)
+	}
+}
+
+// reportHarvested reports a token returned by the harvester at index to the metrics.
+func (c *captchasolve) reportHarvested(index int, h captchatoolsgo.Harvester, latency time.Duration) {
+	if c.metrics != nil {
+		c.metrics.TokenHarvested(index, providerName(h), latency)
+	}
+}
+
+// reportFailed reports a failed call to the harvester at index to the metrics.
+func (c *captchasolve) reportFailed(index int, h captchatoolsgo.Harvester, err error) {
+	if c.metrics != nil {
+		c.metrics.HarvesterFailed(index, providerName(h), ClassifyError(err))
+	}
+}
+
+// reportRetried reports a retried call to the harvester at index to the metrics.
+func (c *captchasolve) reportRetried(index int, h captchatoolsgo.Harvester) {
+	if c.metrics != nil {
+		c.metrics.HarvesterRetried(index, providerName(h))
+	}
+}
+
+// reportServed reports a token returned by GetToken to the metrics.
+func (c *captchasolve) reportServed() {
+	if c.metrics != nil {
+		c.metrics.TokenServed()
+	}
+}
+
+// reportExpired reports n evicted expired tokens to the metrics.
+func (c *captchasolve) reportExpired(n int) {
+	if c.metrics != nil && n > 0 {
+		c.metrics.TokensExpired(n)
+	}
+}
+
+// reportDiscarded reports n discarded valid tokens to the metrics.
+func (c *captchasolve) reportDiscarded(n int) {
+	if c.metrics != nil && n > 0 {
+		c.metrics.TokensDiscarded(n)
+	}
+}
+
+// clearQueueLocked removes every queued token, reporting them as discarded. c.mu must be held.
+func (c *captchasolve) clearQueueLocked() {
+	if c.metrics != nil {
+		c.reportDiscarded(c.queuedLocked())
+	}
+	c.forEachQueueLocked(func(q tokenQueue) { q.Clear() })
+	c.reportPoolLocked()
+}
@@ -0,0 +1,141 @@
+// Package metrics exports the measurements of a CaptchaSolve to Prometheus.
+//
+// Example:
+//
+//	solver := captchasolve.New(
+//	    captchasolve.WithHarvester(harvester),
+//	    captchasolve.WithMetrics(metrics.New(prometheus.DefaultRegisterer)),
+//	)
+//
+// Every metric is registered under the captchasolve namespace. Solvers sharing a registry
+// must be told apart with prometheus.WrapRegistererWith, for example with a site label.
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/Matthew17-21/CaptchaSolve"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "captchasolve"
+
+// SolveBuckets are the buckets of the solve latency histogram, in seconds. Captcha
+// providers take anywhere from a few seconds to a couple of minutes to solve a captcha.
+var SolveBuckets = []float64{1, 2.5, 5, 10, 15, 20, 30, 45, 60, 90, 120, 180}
+
+// Metrics is a captchasolve.Metrics exporting to Prometheus.
+type Metrics struct {
+	queued    prometheus.Gauge
+	waiters   prometheus.Gauge
+	inFlight  prometheus.Gauge
+	harvested *prometheus.CounterVec
+	served    prometheus.Counter
+	expired   prometheus.Counter
+	discarded prometheus.Counter
+	latency   *prometheus.HistogramVec
+	errors    *prometheus.CounterVec
+	retries   *prometheus.CounterVec
+}
+
+var _ captchasolve.Metrics = (*Metrics)(nil)
+
+// New creates the metrics and registers them with reg. It panics if they are already
+// registered, like prometheus.MustRegister.
+func New(reg prometheus.Registerer) *Metrics {
+	f := promauto.With(reg)
+	harvesterLabels := []string{"harvester", "provider"}
+	return &Metrics{
+		queued: f.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "queue_depth",
+			Help:      "Number of tokens waiting in the queue.",
+		}),
+		waiters: f.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "waiters",
+			Help:      "Number of callers waiting for a token.",
+		}),
+		inFlight: f.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "in_flight_solves",
+			Help:      "Number of solves started but not finished yet.",
+		}),
+		harvested: f.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "tokens_harvested_total",
+			Help:      "Number of tokens returned by the harvesters.",
+		}, harvesterLabels),
+		served: f.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "tokens_served_total",
+			Help:      "Number of tokens returned to callers.",
+		}),
+		expired: f.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "tokens_expired_total",
+			Help:      "Number of queued tokens that expired before being used.",
+		}),
+		discarded: f.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "tokens_discarded_total",
+			Help:      "Number of valid tokens thrown away, for example when clearing the queue.",
+		}),
+		latency: f.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "solve_duration_seconds",
+			Help:      "Time taken by the harvesters to return a token.",
+			Buckets:   SolveBuckets,
+		}, harvesterLabels),
+		errors: f.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "harvester_errors_total",
+			Help:      "Number of failed calls to the harvesters, by class of error.",
+		}, append(harvesterLabels, "class")),
+		retries: f.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "harvester_retries_total",
+			Help:      "Number of failed calls to the harvesters that were retried.",
+		}, harvesterLabels),
+	}
+}
+
+// PoolChanged implements captchasolve.Metrics.
+func (m *Metrics) PoolChanged(queued, waiters, inFlight int) {
+	m.queued.Set(float64(queued))
+	m.waiters.Set(float64(waiters))
+	m.inFlight.Set(float64(inFlight))
+}
+
+// TokenHarvested implements captchasolve.Metrics.
+func (m *Metrics) TokenHarvested(index int, provider string, latency time.Duration) {
+	m.harvested.WithLabelValues(harvesterLabel(index), provider).Inc()
+	m.latency.WithLabelValues(harvesterLabel(index), provider).Observe(latency.Seconds())
+}
+
+// TokenServed implements captchasolve.Metrics.
+func (m *Metrics) TokenServed() { m.served.Inc() }
+
+// TokensExpired implements captchasolve.Metrics.
+func (m *Metrics) TokensExpired(n int) { m.expired.Add(float64(n)) }
+
+// TokensDiscarded implements captchasolve.Metrics.
+func (m *Metrics) TokensDiscarded(n int) { m.discarded.Add(float64(n)) }
+
+// HarvesterFailed implements captchasolve.Metrics.
+func (m *Metrics) HarvesterFailed(index int, provider string, class captchasolve.ErrorClass) {
+	m.errors.WithLabelValues(harvesterLabel(index), provider, class.String()).Inc()
+}
+
+// HarvesterRetried implements captchasolve.Metrics.
+func (m *Metrics) HarvesterRetried(index int, provider string) {
+	m.retries.WithLabelValues(harvesterLabel(index), provider).Inc()
+}
+
+// harvesterLabel returns the label of the harvester at index, numbered from 1 like in
+// the solver's logs and errors.
+func harvesterLabel(index int) string {
+	return strconv.Itoa(index + 1)
+}
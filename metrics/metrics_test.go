@@ -0,0 +1,102 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Matthew17-21/CaptchaSolve"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	t.Run("registers every metric", func(t *testing.T) {
+		// Arrange
+		reg := prometheus.NewPedanticRegistry()
+		m := New(reg)
+
+		// Act
+		m.PoolChanged(1, 2, 3)
+		m.TokenHarvested(0, "capmonster", time.Second)
+		m.TokenServed()
+		m.TokensExpired(1)
+		m.TokensDiscarded(1)
+		m.HarvesterFailed(0, "capmonster", captchasolve.ErrorClassUnsolvable)
+		m.HarvesterRetried(0, "capmonster")
+
+		// Assert
+		count, err := testutil.GatherAndCount(reg)
+		require.NoError(t, err)
+		assert.Equal(t, 10, count)
+	})
+
+	t.Run("reports the pool", func(t *testing.T) {
+		// Arrange
+		m := New(prometheus.NewRegistry())
+
+		// Act
+		m.PoolChanged(5, 2, 3)
+
+		// Assert
+		assert.Equal(t, 5.0, testutil.ToFloat64(m.queued))
+		assert.Equal(t, 2.0, testutil.ToFloat64(m.waiters))
+		assert.Equal(t, 3.0, testutil.ToFloat64(m.inFlight))
+	})
+
+	t.Run("counts tokens", func(t *testing.T) {
+		// Arrange
+		m := New(prometheus.NewRegistry())
+
+		// Act
+		m.TokenHarvested(1, "capmonster", 12*time.Second)
+		m.TokenServed()
+		m.TokenServed()
+		m.TokensExpired(3)
+		m.TokensDiscarded(4)
+
+		// Assert
+		assert.Equal(t, 1.0, testutil.ToFloat64(m.harvested.WithLabelValues("2", "capmonster")))
+		assert.Equal(t, 2.0, testutil.ToFloat64(m.served))
+		assert.Equal(t, 3.0, testutil.ToFloat64(m.expired))
+		assert.Equal(t, 4.0, testutil.ToFloat64(m.discarded))
+	})
+
+	t.Run("counts errors by class", func(t *testing.T) {
+		// Arrange
+		reg := prometheus.NewRegistry()
+		m := New(reg)
+
+		// Act
+		m.HarvesterFailed(0, "capmonster", captchasolve.ErrorClassNoBalance)
+		m.HarvesterFailed(0, "capmonster", captchasolve.ErrorClassNoBalance)
+		m.HarvesterRetried(0, "capmonster")
+
+		// Assert
+		expected := `
+# HELP captchasolve_harvester_errors_total Number of failed calls to the harvesters, by class of error.
+# TYPE captchasolve_harvester_errors_total counter
+captchasolve_harvester_errors_total{class="no_balance",harvester="1",provider="capmonster"} 2
+`
+		require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expected), "captchasolve_harvester_errors_total"))
+		assert.Equal(t, 1.0, testutil.ToFloat64(m.retries.WithLabelValues("1", "capmonster")))
+	})
+
+	t.Run("panics when registered twice", func(t *testing.T) {
+		reg := prometheus.NewRegistry()
+		New(reg)
+
+		assert.Panics(t, func() { New(reg) })
+	})
+
+	t.Run("solvers can share a registry", func(t *testing.T) {
+		reg := prometheus.NewRegistry()
+
+		assert.NotPanics(t, func() {
+			New(prometheus.WrapRegistererWith(prometheus.Labels{"site": "a"}, reg))
+			New(prometheus.WrapRegistererWith(prometheus.Labels{"site": "b"}, reg))
+		})
+	})
+}
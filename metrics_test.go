@@ -0,0 +1,156 @@
+package captchasolve
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingMetrics is a Metrics recording what it's told.
+type recordingMetrics struct {
+	mu        sync.Mutex
+	pool      [3]int // Last queued, waiters and in-flight counts
+	harvested int
+	served    int
+	expired   int
+	discarded int
+	failures  map[ErrorClass]int
+	retries   int
+}
+
+func (m *recordingMetrics) PoolChanged(queued, waiters, inFlight int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pool = [3]int{queued, waiters, inFlight}
+}
+
+func (m *recordingMetrics) poolState() [3]int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.pool
+}
+
+func (m *recordingMetrics) TokenHarvested(int, string, time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.harvested++
+}
+
+func (m *recordingMetrics) TokenServed() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.served++
+}
+
+func (m *recordingMetrics) TokensExpired(n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.expired += n
+}
+
+func (m *recordingMetrics) TokensDiscarded(n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.discarded += n
+}
+
+func (m *recordingMetrics) HarvesterFailed(_ int, _ string, class ErrorClass) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.failures == nil {
+		m.failures = make(map[ErrorClass]int)
+	}
+	m.failures[class]++
+}
+
+func (m *recordingMetrics) HarvesterRetried(int, string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.retries++
+}
+
+func TestMetrics(t *testing.T) {
+	t.Run("reports harvested and served tokens", func(t *testing.T) {
+		// Arrange
+		m := &recordingMetrics{}
+		c := New(WithHarvester(&fakeHarvester{}), WithMetrics(m)).(*captchasolve)
+		defer c.Close()
+
+		// Act
+		_, err := c.GetToken(context.Background())
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, 1, m.harvested)
+		assert.Equal(t, 1, m.served)
+		require.Eventually(t, func() bool { return m.poolState() == [3]int{0, 0, 0} }, time.Second, time.Millisecond)
+	})
+
+	t.Run("reports failures and retries", func(t *testing.T) {
+		// Arrange
+		m := &recordingMetrics{}
+		c := New(
+			WithHarvester(&fakeHarvester{err: errors.New("ERROR_CAPTCHA_UNSOLVABLE")}),
+			WithRetryPolicy(ExponentialBackoff{MaxAttempts: 2}),
+			WithMetrics(m),
+		).(*captchasolve)
+		defer c.Close()
+
+		// Act
+		_, err := c.GetToken(context.Background())
+
+		// Assert
+		require.Error(t, err)
+		assert.Equal(t, map[ErrorClass]int{ErrorClassUnsolvable: 2}, m.failures)
+		assert.Equal(t, 1, m.retries)
+		assert.Zero(t, m.served)
+	})
+
+	t.Run("reports expired tokens", func(t *testing.T) {
+		// Arrange
+		m := &recordingMetrics{}
+		c := New(WithMetrics(m)).(*captchasolve)
+		enqueueTokens(c, 2, 3)
+
+		// Act
+		c.sweep(context.Background())
+
+		// Assert
+		assert.Equal(t, 3, m.expired)
+		assert.Equal(t, [3]int{2, 0, 0}, m.pool)
+	})
+
+	t.Run("reports discarded tokens", func(t *testing.T) {
+		// Arrange
+		m := &recordingMetrics{}
+		c := New(WithMaxCapacity(1), WithMetrics(m)).(*captchasolve)
+		enqueueTokens(c, 1, 0)
+
+		// Act
+		c.deliver(&CaptchaAnswer{solvedAt: time.Now()}) // Queue is full
+		c.ClearTokens()
+
+		// Assert
+		assert.Equal(t, 2, m.discarded)
+		assert.Equal(t, [3]int{0, 0, 0}, m.pool)
+	})
+
+	t.Run("reports waiting callers", func(t *testing.T) {
+		// Arrange
+		m := &recordingMetrics{}
+		c := New(WithHarvester(&fakeHarvester{delay: time.Hour}), WithMetrics(m)).(*captchasolve)
+		defer c.Close()
+
+		// Act
+		go c.GetToken(context.Background())
+		waitForWaiters(t, c, 1)
+
+		// Assert
+		require.Eventually(t, func() bool { return m.poolState() == [3]int{0, 1, 1} }, time.Second, time.Millisecond)
+	})
+}
@@ -1,20 +1,47 @@
 package captchasolve
 
-import captchatoolsgo "github.com/Matthew17-21/Captcha-Tools/captchatools-go"
+import (
+	"time"
+
+	captchatoolsgo "github.com/Matthew17-21/Captcha-Tools/captchatools-go"
+	"go.opentelemetry.io/otel/trace"
+)
 
 type ClientOption func(c *config)
 
-// WithMaxCapacity sets the maximum number of tokens to be saved in the underlying data structure
+// WithMaxCapacity sets the maximum number of tokens to be saved in the underlying data structure.
+// It bounds the tokens held for every fingerprint together; once it is reached, the oldest
+// token is discarded to make room for a new one.
 func WithMaxCapacity(i int) ClientOption {
 	return func(c *config) {
 		c.maxCapacity = i
 	}
 }
 
+// HarvesterOption configures a single harvester added with WithHarvester.
+type HarvesterOption func(s *harvesterSettings)
+
 // WithHarvester uses a given captcha harvester in the client
-func WithHarvester(h captchatoolsgo.Harvester) ClientOption {
+func WithHarvester(h captchatoolsgo.Harvester, opts ...HarvesterOption) ClientOption {
+	var settings harvesterSettings
+	for _, opt := range opts {
+		opt(&settings)
+	}
 	return func(c *config) {
 		c.harvesters = append(c.harvesters, h)
+		c.harvesterSettings = append(c.harvesterSettings, settings)
+	}
+}
+
+// WithHarvesterRetryPolicy retries the failed calls of a single harvester according to the
+// given policy, instead of the one set with WithRetryPolicy.
+//
+// Example:
+//
+//	WithHarvester(harvester, WithHarvesterRetryPolicy(NoRetry))
+func WithHarvesterRetryPolicy(p RetryPolicy) HarvesterOption {
+	return func(s *harvesterSettings) {
+		s.retryPolicy = p
 	}
 }
 
@@ -25,7 +52,253 @@ func WithMaxGoroutines(max int) ClientOption {
 		max = 1
 	}
 	return func(c *config) {
-		c.maxCapacity = max
+		c.maxGoroutines = max
+	}
+}
+
+// WithSurplus keeps n extra solves in flight on top of the callers waiting for a token.
+// Surplus tokens are added to the queue so the next caller doesn't have to wait.
+func WithSurplus(n int) ClientOption {
+	// Make sure it is a valid amount
+	if n < 0 {
+		n = 0
+	}
+	return func(c *config) {
+		c.surplus = n
+	}
+}
+
+// WithSolveBudget caps the number of solves, and therefore the spend, over the lifetime
+// of the client. Once it has been spent, solves fail with ErrBudgetExceeded. A budget of
+// 0 means no limit.
+func WithSolveBudget(n int) ClientOption {
+	// Make sure it is a valid amount
+	if n < 0 {
+		n = 0
+	}
+	return func(c *config) {
+		c.solveBudget = n
+	}
+}
+
+// WithPrefill keeps tokens harvested ahead of time so callers don't have to wait for a solve.
+// A background worker starts new solves whenever fewer than min non-expired tokens are queued,
+// topping the queue back up to max. The max is capped to the configured max capacity.
+func WithPrefill(min, max int) ClientOption {
+	// Make sure it is a valid range
+	if min < 0 {
+		min = 0
+	}
+	if max < min {
+		max = min
+	}
+	return func(c *config) {
+		c.prefillMin = min
+		c.prefillMax = max
+	}
+}
+
+// WithTokenValidity sets how long tokens remain valid for after being solved.
+// It defaults to 2 minutes, the validity of reCAPTCHA tokens.
+func WithTokenValidity(d time.Duration) ClientOption {
+	return func(c *config) {
+		c.tokenValidity = d
+	}
+}
+
+// WithValidityFunc sets a function deciding the validity of every harvested token, for
+// example per harvester or captcha type. Tokens it returns 0 for use the default validity.
+func WithValidityFunc(f ValidityFunc) ClientOption {
+	return func(c *config) {
+		c.validityFunc = f
+	}
+}
+
+// WithExpiryMargin sets how long a token must remain valid for to be handed to a caller.
+// Tokens with less time left are treated as expired. It defaults to 5 seconds.
+func WithExpiryMargin(d time.Duration) ClientOption {
+	// Make sure it is a valid amount
+	if d < 0 {
+		d = 0
+	}
+	return func(c *config) {
+		c.expiryMargin = d
+	}
+}
+
+// WithSweeper sets how often expired tokens are evicted from the queue by the background
+// sweeper launched by Start. An interval of 0 disables the sweeper. When refill is set,
+// every evicted token is replaced, either by waking the prefill worker or by starting a
+// new solve, so the pool never silently rots. Tokens solved with AdditionalData aren't
+// replaced, since the AdditionalData they were solved with isn't kept.
+func WithSweeper(interval time.Duration, refill bool) ClientOption {
+	// Make sure it is a valid interval
+	if interval < 0 {
+		interval = 0
+	}
+	return func(c *config) {
+		c.sweepInterval = interval
+		c.sweepRefill = refill
+	}
+}
+
+// WithQuarantineRecheck sets how long a harvester quarantined for being out of funds,
+// using an invalid key or being banned waits before its balance is checked again. The
+// wait doubles after every failed check, up to max.
+func WithQuarantineRecheck(initial, max time.Duration) ClientOption {
+	// Make sure it is a valid range
+	if initial <= 0 {
+		initial = defaultQuarantineRecheck
+	}
+	if max < initial {
+		max = initial
+	}
+	return func(c *config) {
+		c.quarantineRecheck = initial
+		c.quarantineMaxRecheck = max
+	}
+}
+
+// WithShutdownPolicy sets whether Shutdown lets in-flight solves finish and keeps queued
+// tokens (ShutdownDrain), or cancels them and clears the queue (ShutdownDiscard, the default
+// unless a token file is set with WithTokenFile).
+func WithShutdownPolicy(p ShutdownPolicy) ClientOption {
+	return func(c *config) {
+		c.shutdownPolicy = p
+		c.shutdownPolicySet = true
+	}
+}
+
+// WithTokenFile keeps the queued tokens in an append-only log at path, so they survive
+// restarts: New reloads the tokens of the log that haven't expired, and compacts it. The
+// file must not be used by another instance at the same time. The shutdown policy defaults
+// to ShutdownDrain so the queued tokens are kept on shutdown; with ShutdownDiscard, the
+// queue and the file are cleared.
+//
+// Tokens keep their expiry, fingerprint and provider across restarts, but not their Id.
+func WithTokenFile(path string) ClientOption {
+	return func(c *config) {
+		c.tokenFile = path
+	}
+}
+
+// WithHarvesterPriority sets the priority of a harvester, used by the PriorityFailover
+// strategy. Harvesters with a lower priority are used first.
+func WithHarvesterPriority(priority int) HarvesterOption {
+	return func(s *harvesterSettings) {
+		s.priority = priority
+	}
+}
+
+// WithHarvesterWeight sets the weight of a harvester, used by the WeightedRandom strategy.
+func WithHarvesterWeight(weight int) HarvesterOption {
+	// Make sure it is a valid amount
+	if weight < 0 {
+		weight = 0
+	}
+	return func(s *harvesterSettings) {
+		s.weight = weight
+	}
+}
+
+// WithHarvesterCost sets the cost per solve of a harvester, used by the LowestCost strategy.
+func WithHarvesterCost(cost float64) HarvesterOption {
+	return func(s *harvesterSettings) {
+		s.cost = cost
+	}
+}
+
+// WithStrategy sets how the harvester used for each solve is picked. Harvesters are used
+// in rotation (RoundRobin) by default.
+//
+// Example:
+//
+//	New(
+//	    WithHarvester(twoCaptcha, WithHarvesterPriority(0)),
+//	    WithHarvester(capmonster, WithHarvesterPriority(1)),
+//	    WithStrategy(PriorityFailover(3)),
+//	)
+func WithStrategy(s Strategy) ClientOption {
+	return func(c *config) {
+		c.strategy = s
+	}
+}
+
+// WithHedging hedges every solve: a backup harvester is started whenever no token arrived
+// after the policy's delay, or right away if the running harvesters failed, up to
+// MaxHedges backups per solve. The first token wins and the other harvesters are cancelled.
+// Every started harvester is taken from the solve budget.
+//
+// Example:
+//
+//	WithHedging(HedgePolicy{MaxHedges: 2, Delay: 20 * time.Second, Percentile: 0.9})
+func WithHedging(p HedgePolicy) ClientOption {
+	// Make sure it is a valid policy
+	if p.MaxHedges < 0 {
+		p.MaxHedges = 0
+	}
+	if p.Delay < 0 {
+		p.Delay = 0
+	}
+	return func(c *config) {
+		c.hedge = p
+	}
+}
+
+// WithRetryPolicy retries failed harvester calls according to the given policy, unless the
+// harvester was given a policy of its own with WithHarvesterRetryPolicy. Failed calls aren't
+// retried by default.
+//
+// Example:
+//
+//	WithRetryPolicy(ExponentialBackoff{
+//	    MaxAttempts: 3,
+//	    BaseDelay:   time.Second,
+//	    MaxDelay:    10 * time.Second,
+//	    Jitter:      0.2,
+//	})
+func WithRetryPolicy(p RetryPolicy) ClientOption {
+	return func(c *config) {
+		c.retry = p
+	}
+}
+
+// WithMetrics reports measurements of the token pool and the harvesters to m, such as
+// the queue depth, solve latency and errors by class. See the metrics package for a
+// Prometheus implementation.
+//
+// Example:
+//
+//	WithMetrics(metrics.New(prometheus.DefaultRegisterer))
+func WithMetrics(m Metrics) ClientOption {
+	return func(c *config) {
+		c.metrics = m
+	}
+}
+
+// WithTracerProvider traces the solver with OpenTelemetry, creating its spans with a
+// tracer from tp. GetToken, every round of solves, every call to a harvester and every
+// token hand-off get a span of their own.
+//
+// Example:
+//
+//	WithTracerProvider(otel.GetTracerProvider())
+func WithTracerProvider(tp trace.TracerProvider) ClientOption {
+	return func(c *config) {
+		if tp == nil {
+			c.tracer = nil
+			return
+		}
+		c.tracer = tp.Tracer(tracerName)
+	}
+}
+
+// WithCaptchaType sets the type of the captcha the harvesters solve, such as "v2" or
+// "turnstile". It is recorded on the spans of the solver. Solvers created from a config
+// file or by a Registry have it set to the type of their site.
+func WithCaptchaType(captchaType string) ClientOption {
+	return func(c *config) {
+		c.captchaType = captchaType
 	}
 }
 
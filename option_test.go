@@ -2,6 +2,7 @@ package captchasolve
 
 import (
 	"testing"
+	"time"
 
 	captchatoolsgo "github.com/Matthew17-21/Captcha-Tools/captchatools-go"
 	"github.com/stretchr/testify/assert"
@@ -30,6 +31,16 @@ func TestWithHarvester(t *testing.T) {
 	option(cfg)
 
 	assert.Contains(t, cfg.harvesters, mockHarvester, "harvester should be added to the harvesters slice")
+	assert.Len(t, cfg.harvesterSettings, 1, "harvester settings should be kept in line with the harvesters")
+}
+
+func TestWithHarvesterRetryPolicy(t *testing.T) {
+	cfg := &config{}
+	policy := ExponentialBackoff{MaxAttempts: 3}
+	option := WithHarvester(&mockHarvester{}, WithHarvesterRetryPolicy(policy))
+	option(cfg)
+
+	assert.Equal(t, policy, cfg.harvesterSettings[0].retryPolicy, "retryPolicy should be set on the harvester")
 }
 
 func TestWithMaxGoroutines(t *testing.T) {
@@ -38,16 +49,185 @@ func TestWithMaxGoroutines(t *testing.T) {
 	t.Run("valid max goroutines", func(t *testing.T) {
 		option := WithMaxGoroutines(5)
 		option(cfg)
-		assert.Equal(t, 5, cfg.maxCapacity, "maxCapacity should be set to 5")
+		assert.Equal(t, 5, cfg.maxGoroutines, "maxGoroutines should be set to 5")
 	})
 
 	t.Run("invalid max goroutines", func(t *testing.T) {
 		option := WithMaxGoroutines(0)
 		option(cfg)
-		assert.Equal(t, 1, cfg.maxCapacity, "maxCapacity should be set to 1 when an invalid value is passed")
+		assert.Equal(t, 1, cfg.maxGoroutines, "maxGoroutines should be set to 1 when an invalid value is passed")
+	})
+}
+
+func TestWithSurplus(t *testing.T) {
+	cfg := &config{}
+
+	t.Run("valid surplus", func(t *testing.T) {
+		option := WithSurplus(3)
+		option(cfg)
+		assert.Equal(t, 3, cfg.surplus, "surplus should be set to 3")
+	})
+
+	t.Run("invalid surplus", func(t *testing.T) {
+		option := WithSurplus(-1)
+		option(cfg)
+		assert.Equal(t, 0, cfg.surplus, "surplus should be set to 0 when an invalid value is passed")
+	})
+}
+
+func TestWithSolveBudget(t *testing.T) {
+	cfg := &config{}
+
+	t.Run("valid budget", func(t *testing.T) {
+		option := WithSolveBudget(100)
+		option(cfg)
+		assert.Equal(t, 100, cfg.solveBudget, "solveBudget should be set to 100")
+	})
+
+	t.Run("invalid budget", func(t *testing.T) {
+		option := WithSolveBudget(-1)
+		option(cfg)
+		assert.Equal(t, 0, cfg.solveBudget, "solveBudget should be set to 0 when an invalid value is passed")
+	})
+}
+
+func TestWithPrefill(t *testing.T) {
+	t.Run("valid range", func(t *testing.T) {
+		cfg := &config{}
+		WithPrefill(2, 5)(cfg)
+		assert.Equal(t, 2, cfg.prefillMin, "prefillMin should be set to 2")
+		assert.Equal(t, 5, cfg.prefillMax, "prefillMax should be set to 5")
+	})
+
+	t.Run("invalid range", func(t *testing.T) {
+		cfg := &config{}
+		WithPrefill(-1, -5)(cfg)
+		assert.Equal(t, 0, cfg.prefillMin, "prefillMin should be set to 0 when a negative value is passed")
+		assert.Equal(t, 0, cfg.prefillMax, "prefillMax should not be lower than prefillMin")
+	})
+}
+
+func TestWithTokenValidity(t *testing.T) {
+	cfg := &config{}
+	option := WithTokenValidity(time.Minute)
+	option(cfg)
+
+	assert.Equal(t, time.Minute, cfg.tokenValidity, "tokenValidity should be set to 1 minute")
+}
+
+func TestWithValidityFunc(t *testing.T) {
+	cfg := &config{}
+	option := WithValidityFunc(func(captchatoolsgo.Harvester, *captchatoolsgo.CaptchaAnswer) time.Duration { return time.Minute })
+	option(cfg)
+
+	assert.NotNil(t, cfg.validityFunc, "validityFunc should be set")
+}
+
+func TestWithExpiryMargin(t *testing.T) {
+	cfg := &config{}
+
+	t.Run("valid margin", func(t *testing.T) {
+		option := WithExpiryMargin(10 * time.Second)
+		option(cfg)
+		assert.Equal(t, 10*time.Second, cfg.expiryMargin, "expiryMargin should be set to 10s")
+	})
+
+	t.Run("invalid margin", func(t *testing.T) {
+		option := WithExpiryMargin(-time.Second)
+		option(cfg)
+		assert.Equal(t, time.Duration(0), cfg.expiryMargin, "expiryMargin should be set to 0 when an invalid value is passed")
+	})
+}
+
+func TestWithSweeper(t *testing.T) {
+	cfg := &config{}
+
+	t.Run("valid interval", func(t *testing.T) {
+		option := WithSweeper(time.Minute, true)
+		option(cfg)
+		assert.Equal(t, time.Minute, cfg.sweepInterval, "sweepInterval should be set to 1 minute")
+		assert.True(t, cfg.sweepRefill, "sweepRefill should be set")
+	})
+
+	t.Run("invalid interval", func(t *testing.T) {
+		option := WithSweeper(-time.Minute, false)
+		option(cfg)
+		assert.Equal(t, time.Duration(0), cfg.sweepInterval, "sweepInterval should be set to 0 when an invalid value is passed")
 	})
 }
 
+func TestWithQuarantineRecheck(t *testing.T) {
+	t.Run("valid range", func(t *testing.T) {
+		cfg := &config{}
+		WithQuarantineRecheck(time.Minute, time.Hour)(cfg)
+		assert.Equal(t, time.Minute, cfg.quarantineRecheck)
+		assert.Equal(t, time.Hour, cfg.quarantineMaxRecheck)
+	})
+
+	t.Run("invalid range", func(t *testing.T) {
+		cfg := &config{}
+		WithQuarantineRecheck(0, time.Second)(cfg)
+		assert.Equal(t, defaultQuarantineRecheck, cfg.quarantineRecheck, "quarantineRecheck should use the default when an invalid value is passed")
+		assert.Equal(t, defaultQuarantineRecheck, cfg.quarantineMaxRecheck, "quarantineMaxRecheck should not be lower than quarantineRecheck")
+	})
+}
+
+func TestWithShutdownPolicy(t *testing.T) {
+	cfg := &config{}
+	option := WithShutdownPolicy(ShutdownDrain)
+	option(cfg)
+
+	assert.Equal(t, ShutdownDrain, cfg.shutdownPolicy, "shutdownPolicy should be set to ShutdownDrain")
+}
+
+func TestWithTokenFile(t *testing.T) {
+	t.Run("drains on shutdown by default", func(t *testing.T) {
+		cfg := newConfig(WithTokenFile("tokens.jsonl"))
+
+		assert.Equal(t, "tokens.jsonl", cfg.tokenFile, "tokenFile should be set to the provided path")
+		assert.Equal(t, ShutdownDrain, cfg.shutdownPolicy, "shutdownPolicy should default to ShutdownDrain")
+	})
+
+	t.Run("keeps the shutdown policy set", func(t *testing.T) {
+		cfg := newConfig(WithShutdownPolicy(ShutdownDiscard), WithTokenFile("tokens.jsonl"))
+
+		assert.Equal(t, ShutdownDiscard, cfg.shutdownPolicy, "shutdownPolicy should be kept when set explicitly")
+	})
+}
+
+func TestWithHarvesterStrategySettings(t *testing.T) {
+	cfg := &config{}
+	option := WithHarvester(&mockHarvester{}, WithHarvesterPriority(2), WithHarvesterWeight(3), WithHarvesterCost(0.002))
+	option(cfg)
+
+	assert.Equal(t, harvesterSettings{priority: 2, weight: 3, cost: 0.002}, cfg.harvesterSettings[0])
+}
+
+func TestWithHarvesterWeight(t *testing.T) {
+	s := &harvesterSettings{}
+	WithHarvesterWeight(-1)(s)
+
+	assert.Equal(t, 0, s.weight, "weight should be set to 0 when an invalid value is passed")
+}
+
+func TestWithStrategy(t *testing.T) {
+	cfg := &config{}
+	strategy := LowestCost()
+	option := WithStrategy(strategy)
+	option(cfg)
+
+	assert.Equal(t, strategy, cfg.strategy, "strategy should be set to the provided strategy")
+}
+
+func TestWithRetryPolicy(t *testing.T) {
+	cfg := &config{}
+	policy := ExponentialBackoff{MaxAttempts: 3}
+	option := WithRetryPolicy(policy)
+	option(cfg)
+
+	assert.Equal(t, policy, cfg.retry, "retry should be set to the provided policy")
+}
+
 func TestWithLogger(t *testing.T) {
 	cfg := &config{}
 	mockLogger := &mockLogger{}
@@ -56,3 +236,50 @@ func TestWithLogger(t *testing.T) {
 
 	assert.Equal(t, mockLogger, cfg.logger, "logger should be set to the provided mockLogger")
 }
+
+func TestWithHedging(t *testing.T) {
+	cfg := &config{}
+
+	t.Run("valid policy", func(t *testing.T) {
+		policy := HedgePolicy{MaxHedges: 2, Delay: time.Second, Percentile: 0.9}
+		WithHedging(policy)(cfg)
+		assert.Equal(t, policy, cfg.hedge, "hedge should be set to the provided policy")
+	})
+
+	t.Run("invalid policy", func(t *testing.T) {
+		WithHedging(HedgePolicy{MaxHedges: -1, Delay: -time.Second})(cfg)
+		assert.Equal(t, HedgePolicy{}, cfg.hedge, "negative values should be set to 0")
+	})
+}
+
+func TestWithMetrics(t *testing.T) {
+	cfg := &config{}
+	m := &recordingMetrics{}
+	option := WithMetrics(m)
+	option(cfg)
+
+	assert.Equal(t, m, cfg.metrics, "metrics should be set to the provided metrics")
+}
+
+func TestWithTracerProvider(t *testing.T) {
+	cfg := &config{}
+
+	t.Run("valid tracer provider", func(t *testing.T) {
+		tp, _ := newRecordingTracerProvider()
+		WithTracerProvider(tp)(cfg)
+		assert.NotNil(t, cfg.tracer, "tracer should be created from the provided tracer provider")
+	})
+
+	t.Run("nil tracer provider", func(t *testing.T) {
+		WithTracerProvider(nil)(cfg)
+		assert.Nil(t, cfg.tracer, "tracing should be disabled when nil is passed")
+	})
+}
+
+func TestWithCaptchaType(t *testing.T) {
+	cfg := &config{}
+	option := WithCaptchaType("turnstile")
+	option(cfg)
+
+	assert.Equal(t, "turnstile", cfg.captchaType, "captchaType should be set to the provided type")
+}
@@ -0,0 +1,57 @@
+package captchasolve
+
+import (
+	"context"
+	"time"
+)
+
+// prefillInterval is how often the prefill worker re-checks the pool depth when it
+// hasn't been woken up by a caller taking a token.
+const prefillInterval = 5 * time.Second
+
+// prefillDemand returns how many new solves are needed to bring the pool back up to
+// prefillMax once it has dropped below prefillMin. Solves that aren't covering a
+// waiter will land in the queue, so they count towards the depth. c.mu must be held.
+func (c *captchasolve) prefillDemand() int {
+	if c.prefillMax <= 0 {
+		return 0
+	}
+	depth := c.queue.Len() + c.inFlightFor("") - c.waitersFor("")
+	if depth >= c.prefillMin {
+		return 0
+	}
+	return c.prefillMax - depth
+}
+
+// prefill keeps between prefillMin and prefillMax non-expired tokens in the queue
+// until ctx is cancelled. It wakes up whenever a caller takes a token from the queue,
+// and every prefillInterval to replace tokens that expired while sitting in the queue.
+func (c *captchasolve) prefill(ctx context.Context) {
+	c.mu.Lock()
+	c.logger.Info("Starting prefill worker, keeping %d-%d tokens ready", c.prefillMin, c.prefillMax)
+	c.mu.Unlock()
+	ticker := time.NewTicker(prefillInterval)
+	defer ticker.Stop()
+	for {
+		c.mu.Lock()
+		c.evictExpiredLocked()
+		c.mu.Unlock()
+		c.dispatch(ctx)
+
+		select {
+		case <-ctx.Done():
+			c.logger.Info("Stopping prefill worker")
+			return
+		case <-ticker.C:
+		case <-c.refill:
+		}
+	}
+}
+
+// signalRefill wakes up the prefill worker, if any, without blocking.
+func (c *captchasolve) signalRefill() {
+	select {
+	case c.refill <- struct{}{}:
+	default:
+	}
+}
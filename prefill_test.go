@@ -0,0 +1,91 @@
+package captchasolve
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Matthew17-21/CaptchaSolve/internal/queue"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrefillDemand(t *testing.T) {
+	tests := []struct {
+		name       string
+		min, max   int
+		queued     int
+		inFlight   int
+		numWaiters int
+		expected   int
+	}{
+		{name: "disabled", min: 0, max: 0, expected: 0},
+		{name: "empty pool fills to max", min: 2, max: 5, expected: 5},
+		{name: "at min does nothing", min: 2, max: 5, queued: 2, expected: 0},
+		{name: "below min tops up to max", min: 2, max: 5, queued: 1, expected: 4},
+		{name: "in-flight solves count towards depth", min: 2, max: 5, queued: 1, inFlight: 1, expected: 0},
+		{name: "solves covering waiters don't count", min: 2, max: 5, inFlight: 2, numWaiters: 2, expected: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			c := &captchasolve{queue: queue.NewSliceQueue[*CaptchaAnswer]()}
+			c.prefillMin, c.prefillMax = tt.min, tt.max
+			c.inFlight = tt.inFlight
+			for i := 0; i < tt.queued; i++ {
+				c.queue.Enqueue(&CaptchaAnswer{solvedAt: time.Now()})
+			}
+			for i := 0; i < tt.numWaiters; i++ {
+				c.addWaiter(newWaiter(""))
+			}
+
+			// Act & Assert
+			require.Equal(t, tt.expected, c.prefillDemand())
+		})
+	}
+}
+
+func TestPrefill(t *testing.T) {
+	t.Run("fills the queue up to max", func(t *testing.T) {
+		// Arrange
+		h := &fakeHarvester{}
+
+		solver := New(WithHarvester(h), WithPrefill(2, 4)).(*captchasolve)
+		defer solver.Close()
+
+		// Act
+		require.NoError(t, solver.Start(context.Background()))
+
+		// Assert
+		require.Eventually(t, func() bool { return solver.queue.Len() == 4 }, time.Second, time.Millisecond)
+		require.EqualValues(t, 4, h.calls.Load())
+	})
+
+	t.Run("tops up once below min", func(t *testing.T) {
+		// Arrange
+		h := &fakeHarvester{}
+		solver := New(WithHarvester(h), WithPrefill(2, 4)).(*captchasolve)
+		defer solver.Close()
+		require.NoError(t, solver.Start(context.Background()))
+		require.Eventually(t, func() bool { return solver.queue.Len() == 4 }, time.Second, time.Millisecond)
+
+		// Act
+		for i := 0; i < 3; i++ {
+			_, err := solver.GetToken(context.Background())
+			require.NoError(t, err)
+		}
+
+		// Assert
+		require.Eventually(t, func() bool { return solver.queue.Len() == 4 }, time.Second, time.Millisecond)
+		require.EqualValues(t, 7, h.calls.Load())
+	})
+
+	t.Run("target is capped to max capacity", func(t *testing.T) {
+		// Act
+		solver := New(WithMaxCapacity(3), WithPrefill(5, 10)).(*captchasolve)
+
+		// Assert
+		require.Equal(t, 3, solver.prefillMin)
+		require.Equal(t, 3, solver.prefillMax)
+	})
+}
@@ -0,0 +1,223 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: captchasolve/v1/captchasolve.proto
+
+package captchasolvev1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	CaptchaSolve_GetToken_FullMethodName        = "/captchasolve.v1.CaptchaSolve/GetToken"
+	CaptchaSolve_SubscribeTokens_FullMethodName = "/captchasolve.v1.CaptchaSolve/SubscribeTokens"
+	CaptchaSolve_ReportToken_FullMethodName     = "/captchasolve.v1.CaptchaSolve/ReportToken"
+)
+
+// CaptchaSolveClient is the client API for CaptchaSolve service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// CaptchaSolve serves captcha tokens from the solvers of a captchasolve server, by site.
+//
+// When the server has API keys set, calls must carry one in the authorization metadata,
+// as a bearer token, or in the x-api-key metadata.
+type CaptchaSolveClient interface {
+	// GetToken waits for a token for a site, until the deadline of the call.
+	GetToken(ctx context.Context, in *GetTokenRequest, opts ...grpc.CallOption) (*Token, error)
+	// SubscribeTokens streams tokens for a site as they're harvested, until the call is
+	// cancelled or max_tokens were sent. A token is only taken from the pool once the
+	// previous one was sent, so a subscriber that stops reading stops taking tokens.
+	SubscribeTokens(ctx context.Context, in *SubscribeTokensRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Token], error)
+	// ReportToken reports a token handed out for a site as rejected by the site, counting it
+	// as a failure of the harvester that solved it.
+	ReportToken(ctx context.Context, in *ReportTokenRequest, opts ...grpc.CallOption) (*ReportTokenResponse, error)
+}
+
+type captchaSolveClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewCaptchaSolveClient(cc grpc.ClientConnInterface) CaptchaSolveClient {
+	return &captchaSolveClient{cc}
+}
+
+func (c *captchaSolveClient) GetToken(ctx context.Context, in *GetTokenRequest, opts ...grpc.CallOption) (*Token, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Token)
+	err := c.cc.Invoke(ctx, CaptchaSolve_GetToken_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *captchaSolveClient) SubscribeTokens(ctx context.Context, in *SubscribeTokensRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Token], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &CaptchaSolve_ServiceDesc.Streams[0], CaptchaSolve_SubscribeTokens_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[SubscribeTokensRequest, Token]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type CaptchaSolve_SubscribeTokensClient = grpc.ServerStreamingClient[Token]
+
+func (c *captchaSolveClient) ReportToken(ctx context.Context, in *ReportTokenRequest, opts ...grpc.CallOption) (*ReportTokenResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ReportTokenResponse)
+	err := c.cc.Invoke(ctx, CaptchaSolve_ReportToken_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CaptchaSolveServer is the server API for CaptchaSolve service.
+// All implementations must embed UnimplementedCaptchaSolveServer
+// for forward compatibility.
+//
+// CaptchaSolve serves captcha tokens from the solvers of a captchasolve server, by site.
+//
+// When the server has API keys set, calls must carry one in the authorization metadata,
+// as a bearer token, or in the x-api-key metadata.
+type CaptchaSolveServer interface {
+	// GetToken waits for a token for a site, until the deadline of the call.
+	GetToken(context.Context, *GetTokenRequest) (*Token, error)
+	// SubscribeTokens streams tokens for a site as they're harvested, until the call is
+	// cancelled or max_tokens were sent. A token is only taken from the pool once the
+	// previous one was sent, so a subscriber that stops reading stops taking tokens.
+	SubscribeTokens(*SubscribeTokensRequest, grpc.ServerStreamingServer[Token]) error
+	// ReportToken reports a token handed out for a site as rejected by the site, counting it
+	// as a failure of the harvester that solved it.
+	ReportToken(context.Context, *ReportTokenRequest) (*ReportTokenResponse, error)
+	mustEmbedUnimplementedCaptchaSolveServer()
+}
+
+// UnimplementedCaptchaSolveServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedCaptchaSolveServer struct{}
+
+func (UnimplementedCaptchaSolveServer) GetToken(context.Context, *GetTokenRequest) (*Token, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetToken not implemented")
+}
+func (UnimplementedCaptchaSolveServer) SubscribeTokens(*SubscribeTokensRequest, grpc.ServerStreamingServer[Token]) error {
+	return status.Errorf(codes.Unimplemented, "method SubscribeTokens not implemented")
+}
+func (UnimplementedCaptchaSolveServer) ReportToken(context.Context, *ReportTokenRequest) (*ReportTokenResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReportToken not implemented")
+}
+func (UnimplementedCaptchaSolveServer) mustEmbedUnimplementedCaptchaSolveServer() {}
+func (UnimplementedCaptchaSolveServer) testEmbeddedByValue()                      {}
+
+// UnsafeCaptchaSolveServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to CaptchaSolveServer will
+// result in compilation errors.
+type UnsafeCaptchaSolveServer interface {
+	mustEmbedUnimplementedCaptchaSolveServer()
+}
+
+func RegisterCaptchaSolveServer(s grpc.ServiceRegistrar, srv CaptchaSolveServer) {
+	// If the following call pancis, it indicates UnimplementedCaptchaSolveServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&CaptchaSolve_ServiceDesc, srv)
+}
+
+func _CaptchaSolve_GetToken_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetTokenRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CaptchaSolveServer).GetToken(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CaptchaSolve_GetToken_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CaptchaSolveServer).GetToken(ctx, req.(*GetTokenRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CaptchaSolve_SubscribeTokens_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscribeTokensRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CaptchaSolveServer).SubscribeTokens(m, &grpc.GenericServerStream[SubscribeTokensRequest, Token]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type CaptchaSolve_SubscribeTokensServer = grpc.ServerStreamingServer[Token]
+
+func _CaptchaSolve_ReportToken_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReportTokenRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CaptchaSolveServer).ReportToken(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CaptchaSolve_ReportToken_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CaptchaSolveServer).ReportToken(ctx, req.(*ReportTokenRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// CaptchaSolve_ServiceDesc is the grpc.ServiceDesc for CaptchaSolve service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var CaptchaSolve_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "captchasolve.v1.CaptchaSolve",
+	HandlerType: (*CaptchaSolveServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetToken",
+			Handler:    _CaptchaSolve_GetToken_Handler,
+		},
+		{
+			MethodName: "ReportToken",
+			Handler:    _CaptchaSolve_ReportToken_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "SubscribeTokens",
+			Handler:       _CaptchaSolve_SubscribeTokens_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "captchasolve/v1/captchasolve.proto",
+}
@@ -0,0 +1,5 @@
+// Package captchasolvev1 holds the gRPC service generated from captchasolve.proto. The
+// grpcserver package implements it.
+package captchasolvev1
+
+//go:generate protoc -I ../.. --go_out=../.. --go_opt=paths=source_relative --go-grpc_out=../.. --go-grpc_opt=paths=source_relative captchasolve/v1/captchasolve.proto
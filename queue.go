@@ -11,30 +11,51 @@ import (
 type tokenQueue interface {
 	Enqueue(*CaptchaAnswer) error
 	Dequeue() (*CaptchaAnswer, error)
+	Peek() (*CaptchaAnswer, error)
+	RemoveFunc(func(*CaptchaAnswer) bool) int
 	Clear()
 	Len() int
 }
 
-// ClearTokens removes any/all pre-harvested tokens
-func (c *captchasolve) ClearTokens() { c.queue.Clear() }
+// ClearTokens removes any/all pre-harvested tokens, whatever their fingerprint
+func (c *captchasolve) ClearTokens() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.clearQueueLocked()
+}
 
-// getValidTokenFromQueue attempts to get a non-expired token from the queue
-func (c *captchasolve) getValidTokenFromQueue() (*CaptchaAnswer, error) {
+// getValidTokenFromQueue attempts to get a token with the given fingerprint from the queue
+// that remains valid for longer than the expiry margin
+func (c *captchasolve) getValidTokenFromQueue(fingerprint string) (*CaptchaAnswer, error) {
 	// Check if pre-harvested tokens are already saved.
 	// No need to check the length since the Dequeue method does it under the hood.
 	c.logger.Info("Attempting to get a valid token from queue...")
-	tkn, err := c.queue.Dequeue()
-	if err != nil {
-		if errors.Is(err, queue.ErrQueueEmpty) {
-			c.logger.Info("Can't get token - queue is empty.")
-			return nil, queue.ErrQueueEmpty
-		}
-		c.logger.Error("Unknown error getting token from queue:", err)
-		return nil, fmt.Errorf("error dequeueing: %w", err)
+	q := c.queueFor(fingerprint)
+	if q == nil {
+		c.logger.Info("Can't get token - queue is empty.")
+		return nil, queue.ErrQueueEmpty
+	}
+	if fingerprint != "" {
+		defer func() {
+			if q.Len() == 0 {
+				delete(c.keyed, fingerprint)
+			}
+		}()
 	}
-	if tkn.IsExpired() {
-		c.logger.Info("Token is expired. Getting new one from queue...")
-		return c.getValidTokenFromQueue()
+	for {
+		tkn, err := q.Dequeue()
+		if err != nil {
+			if errors.Is(err, queue.ErrQueueEmpty) {
+				c.logger.Info("Can't get token - queue is empty.")
+				return nil, queue.ErrQueueEmpty
+			}
+			WithFields(c.logger, "error", err).Error("Unknown error getting token from queue")
+			return nil, fmt.Errorf("error dequeueing: %w", err)
+		}
+		if c.usable(tkn) {
+			return tkn, nil
+		}
+		WithFields(c.logger, "token_id", tkn.Id()).Info("Token is expired or about to expire. Getting new one from queue...")
+		c.reportExpired(1)
 	}
-	return tkn, nil
 }
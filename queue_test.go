@@ -2,6 +2,7 @@ package captchasolve
 
 import (
 	"testing"
+	"time"
 
 	"github.com/Matthew17-21/CaptchaSolve/internal/queue"
 	"github.com/stretchr/testify/require"
@@ -25,6 +26,19 @@ func (m *mockQueue) Dequeue() (*CaptchaAnswer, error) {
 	return args.Get(0).(*CaptchaAnswer), args.Error(1)
 }
 
+func (m *mockQueue) Peek() (*CaptchaAnswer, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*CaptchaAnswer), args.Error(1)
+}
+
+func (m *mockQueue) RemoveFunc(remove func(*CaptchaAnswer) bool) int {
+	args := m.Called(remove)
+	return args.Int(0)
+}
+
 func (m *mockQueue) Clear() {
 	m.Called()
 }
@@ -51,3 +65,30 @@ func TestClearTokens(t *testing.T) {
 	// Assert
 	require.Empty(t, cs.queue.Len())
 }
+
+func TestGetValidTokenFromQueue(t *testing.T) {
+	t.Run("skips expired tokens", func(t *testing.T) {
+		// Arrange
+		cs := New().(*captchasolve)
+		enqueueTokens(cs, 1, 3)
+
+		// Act
+		tkn, err := cs.getValidTokenFromQueue("")
+
+		// Assert
+		require.NoError(t, err)
+		require.Equal(t, "valid", tkn.Token)
+	})
+
+	t.Run("empty queue", func(t *testing.T) {
+		// Arrange
+		cs := New().(*captchasolve)
+		cs.queue.Enqueue(&CaptchaAnswer{solvedAt: time.Now().Add(-time.Hour)})
+
+		// Act
+		_, err := cs.getValidTokenFromQueue("")
+
+		// Assert
+		require.ErrorIs(t, err, queue.ErrQueueEmpty)
+	})
+}
@@ -0,0 +1,213 @@
+package captchasolve
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	captchatoolsgo "github.com/Matthew17-21/Captcha-Tools/captchatools-go"
+)
+
+// Site identifies the captcha of a page. A Registry holds one pool of tokens per Site.
+type Site struct {
+	CaptchaType string // Type of the captcha, such as "v2", "v3", "hcaptcha" or "turnstile"
+	SiteKey     string // Site key of the captcha
+	URL         string // URL of the page the captcha is on
+	Action      string // Action of reCAPTCHA v3 captchas
+}
+
+func (s Site) String() string {
+	if s.Action == "" {
+		return fmt.Sprintf("%s %s at %s", s.CaptchaType, s.SiteKey, s.URL)
+	}
+	return fmt.Sprintf("%s %s at %s (%s)", s.CaptchaType, s.SiteKey, s.URL, s.Action)
+}
+
+// Registry holds a CaptchaSolve per site, each with its own harvesters, capacity and
+// prefill settings, so one process can serve tokens for every site it monitors. Sites
+// are registered under an ID callers request tokens with.
+//
+// Example:
+//
+//	registry := NewRegistry()
+//	defer registry.Close()
+//	err := registry.Register("retailer", Site{CaptchaType: "v2", SiteKey: key, URL: url},
+//	    WithHarvester(harvester),
+//	    WithPrefill(2, 5),
+//	)
+//	token, err := registry.GetToken(ctx, "retailer")
+type Registry struct {
+	mu     sync.RWMutex
+	sites  map[string]registeredSite
+	ctx    context.Context // Set once Start is called
+	closed bool
+
+	// reloadMu serializes Reload. siteConfigs holds the configuration of the sites added
+	// by Reload, siteHarvesters the harvesters created for it, configOpts the options
+	// applied on top of it and logger the logger it configured. Guarded by reloadMu.
+	reloadMu       sync.Mutex
+	siteConfigs    map[string]SiteConfig
+	siteHarvesters map[string][]captchatoolsgo.Harvester
+	configOpts     []ClientOption
+	logger         Logger
+}
+
+// registeredSite is a site registered with a Registry.
+type registeredSite struct {
+	site   Site
+	solver CaptchaSolve
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{sites: make(map[string]registeredSite), logger: NewSilentLogger()}
+}
+
+// Register creates a CaptchaSolve for site with the given options and registers it under
+// id. It returns ErrSiteExists if id or site is already registered, and the error of
+// NewE if the options aren't valid. The captcha type of the solver is set to the one of
+// site unless the options set it with WithCaptchaType.
+//
+// The solver is started right away if the Registry has been started.
+func (r *Registry) Register(id string, site Site, opts ...ClientOption) error {
+	solver, err := NewE(append([]ClientOption{WithCaptchaType(site.CaptchaType)}, opts...)...)
+	if err != nil {
+		return fmt.Errorf("captchasolve: site %q: %w", id, err)
+	}
+	if err := r.add(id, site, solver); err != nil {
+		solver.Close()
+		return err
+	}
+	return nil
+}
+
+// add registers solver for site under id, starting it if the Registry has been started.
+func (r *Registry) add(id string, site Site, solver CaptchaSolve) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return ErrPoolClosed
+	}
+	if _, ok := r.sites[id]; ok {
+		return fmt.Errorf("%w: %q", ErrSiteExists, id)
+	}
+	for existingID, existing := range r.sites {
+		if existing.site == site {
+			return fmt.Errorf("%w: %v is registered as %q", ErrSiteExists, site, existingID)
+		}
+	}
+	if r.ctx != nil {
+		if err := solver.Start(r.ctx); err != nil {
+			return err
+		}
+	}
+	r.sites[id] = registeredSite{site: site, solver: solver}
+	return nil
+}
+
+// Remove unregisters the site registered under id and closes its solver. It returns
+// ErrUnknownSite if no site is registered under id.
+func (r *Registry) Remove(id string) error {
+	r.mu.Lock()
+	registered, ok := r.sites[id]
+	delete(r.sites, id)
+	r.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownSite, id)
+	}
+	return registered.solver.Close()
+}
+
+// GetToken returns a token for the site registered under id. See CaptchaSolve.GetToken.
+// It returns ErrUnknownSite if no site is registered under id.
+func (r *Registry) GetToken(ctx context.Context, id string, additional ...*captchatoolsgo.AdditionalData) (*CaptchaAnswer, error) {
+	solver, ok := r.Solver(id)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownSite, id)
+	}
+	return solver.GetToken(ctx, additional...)
+}
+
+// Solver returns the solver of the site registered under id, for example to check the
+// health and statistics of its harvesters.
+func (r *Registry) Solver(id string) (CaptchaSolve, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	registered, ok := r.sites[id]
+	return registered.solver, ok
+}
+
+// Site returns the site registered under id.
+func (r *Registry) Site(id string) (Site, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	registered, ok := r.sites[id]
+	return registered.site, ok
+}
+
+// IDs returns the IDs of every registered site, sorted.
+func (r *Registry) IDs() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	ids := make([]string, 0, len(r.sites))
+	for id := range r.sites {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// Start launches the background workers of every registered solver, and of those
+// registered later. See CaptchaSolve.Start.
+func (r *Registry) Start(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return ErrPoolClosed
+	}
+	if r.ctx != nil {
+		return nil
+	}
+	r.ctx = ctx
+	var errs []error
+	for id, registered := range r.sites {
+		if err := registered.solver.Start(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("captchasolve: site %q: %w", id, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close closes every registered solver. See CaptchaSolve.Close.
+func (r *Registry) Close() error {
+	return r.Shutdown(context.Background())
+}
+
+// Shutdown shuts every registered solver down concurrently, according to their own
+// ShutdownPolicy. See CaptchaSolve.Shutdown.
+func (r *Registry) Shutdown(ctx context.Context) error {
+	r.mu.Lock()
+	r.closed = true
+	sites := r.sites
+	r.sites = make(map[string]registeredSite)
+	r.mu.Unlock()
+
+	var mu sync.Mutex
+	var errs []error
+	var wg sync.WaitGroup
+	for id, registered := range sites {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := registered.solver.Shutdown(ctx); err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("captchasolve: site %q: %w", id, err))
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
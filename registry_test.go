@@ -0,0 +1,123 @@
+package captchasolve
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+)
+
+func TestRegistry(t *testing.T) {
+	siteA := Site{CaptchaType: "v2", SiteKey: "key-a", URL: "https://a.example"}
+	siteB := Site{CaptchaType: "v3", SiteKey: "key-b", URL: "https://b.example", Action: "checkout"}
+
+	t.Run("serves tokens per site", func(t *testing.T) {
+		// Arrange
+		r := NewRegistry()
+		defer r.Close()
+		harvesterA, harvesterB := &fakeHarvester{}, &fakeHarvester{}
+		require.NoError(t, r.Register("a", siteA, WithHarvester(harvesterA)))
+		require.NoError(t, r.Register("b", siteB, WithHarvester(harvesterB)))
+
+		// Act
+		_, errA := r.GetToken(context.Background(), "a")
+		_, errB := r.GetToken(context.Background(), "b")
+
+		// Assert
+		require.NoError(t, errA)
+		require.NoError(t, errB)
+		assert.EqualValues(t, 1, harvesterA.calls.Load())
+		assert.EqualValues(t, 1, harvesterB.calls.Load())
+		assert.Equal(t, []string{"a", "b"}, r.IDs())
+		site, ok := r.Site("b")
+		require.True(t, ok)
+		assert.Equal(t, siteB, site)
+	})
+
+	t.Run("reports unknown sites", func(t *testing.T) {
+		r := NewRegistry()
+
+		_, err := r.GetToken(context.Background(), "missing")
+
+		require.ErrorIs(t, err, ErrUnknownSite)
+		require.ErrorIs(t, r.Remove("missing"), ErrUnknownSite)
+	})
+
+	t.Run("rejects duplicates", func(t *testing.T) {
+		r := NewRegistry()
+		defer r.Close()
+		require.NoError(t, r.Register("a", siteA, WithHarvester(&fakeHarvester{})))
+
+		require.ErrorIs(t, r.Register("a", siteB, WithHarvester(&fakeHarvester{})), ErrSiteExists)
+		require.ErrorIs(t, r.Register("other", siteA, WithHarvester(&fakeHarvester{})), ErrSiteExists)
+	})
+
+	t.Run("rejects invalid options", func(t *testing.T) {
+		r := NewRegistry()
+
+		err := r.Register("a", siteA)
+
+		require.ErrorIs(t, err, ErrNoHarvesters)
+		require.ErrorContains(t, err, `site "a"`)
+		require.Empty(t, r.IDs())
+	})
+
+	t.Run("removes sites", func(t *testing.T) {
+		r := NewRegistry()
+		require.NoError(t, r.Register("a", siteA, WithHarvester(&fakeHarvester{})))
+		solver, _ := r.Solver("a")
+
+		require.NoError(t, r.Remove("a"))
+
+		_, err := solver.GetToken(context.Background())
+		require.ErrorIs(t, err, ErrPoolClosed)
+		_, ok := r.Solver("a")
+		require.False(t, ok)
+	})
+
+	t.Run("starts solvers registered before and after Start", func(t *testing.T) {
+		// Arrange
+		r := NewRegistry()
+		defer r.Close()
+		harvesterA, harvesterB := &fakeHarvester{}, &fakeHarvester{}
+		require.NoError(t, r.Register("a", siteA, WithHarvester(harvesterA), WithPrefill(1, 1)))
+
+		// Act
+		require.NoError(t, r.Start(context.Background()))
+		require.NoError(t, r.Register("b", siteB, WithHarvester(harvesterB), WithPrefill(1, 1)))
+
+		// Assert
+		require.Eventually(t, func() bool {
+			return harvesterA.calls.Load() > 0 && harvesterB.calls.Load() > 0
+		}, time.Second, time.Millisecond, "prefill workers should be running")
+	})
+
+	t.Run("closes every solver", func(t *testing.T) {
+		defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+		// Arrange
+		r := NewRegistry()
+		require.NoError(t, r.Register("a", siteA, WithHarvester(&fakeHarvester{delay: time.Hour})))
+		require.NoError(t, r.Register("b", siteB, WithHarvester(&fakeHarvester{delay: time.Hour})))
+		require.NoError(t, r.Start(context.Background()))
+		solver, _ := r.Solver("a")
+		go solver.GetToken(context.Background())
+		waitForWaiters(t, solver.(*captchasolve), 1)
+
+		// Act
+		require.NoError(t, r.Close())
+
+		// Assert
+		require.Empty(t, r.IDs())
+		require.ErrorIs(t, r.Register("c", Site{}, WithHarvester(&fakeHarvester{})), ErrPoolClosed)
+		require.ErrorIs(t, r.Start(context.Background()), ErrPoolClosed)
+	})
+}
+
+func TestSite_String(t *testing.T) {
+	assert.Equal(t, "v2 key at https://a.example", Site{CaptchaType: "v2", SiteKey: "key", URL: "https://a.example"}.String())
+	assert.Equal(t, "v3 key at https://a.example (login)", Site{CaptchaType: "v3", SiteKey: "key", URL: "https://a.example", Action: "login"}.String())
+}
@@ -0,0 +1,174 @@
+package captchasolve
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	captchatoolsgo "github.com/Matthew17-21/Captcha-Tools/captchatools-go"
+)
+
+// defaultWatchInterval is how often WatchConfig checks the configuration file for changes
+// when no interval is given.
+const defaultWatchInterval = 5 * time.Second
+
+// Reload applies a new configuration to the sites added by LoadConfig, FileConfig.Registry
+// or an earlier Reload, without flushing the pools of the sites that remain:
+//   - Sites that are new are registered, and sites no longer configured are removed.
+//   - Sites whose captcha changed are replaced, since their queued tokens are for another
+//     captcha.
+//   - Other sites whose settings changed are updated with CaptchaSolve.Update, keeping
+//     their queued tokens and in-flight solves. Their logger is kept, and so are the
+//     harvesters of the providers that didn't change, along with their health.
+//   - Sites whose settings didn't change are left alone.
+//
+// The options given to LoadConfig or FileConfig.Registry are applied again. Sites
+// registered with Register are never changed. Nothing is changed if fc isn't valid; the
+// sites that couldn't be applied otherwise keep their previous configuration, and the
+// problems are returned.
+//
+// Example, rotating an API key:
+//
+//	fc, err := ReadConfig("captchasolve.yaml")
+//	if err != nil {
+//	    return err
+//	}
+//	return registry.Reload(fc)
+func (r *Registry) Reload(fc *FileConfig) error {
+	if err := fc.Validate(); err != nil {
+		return err
+	}
+	l, err := fc.logger()
+	if err != nil {
+		return err
+	}
+
+	r.reloadMu.Lock()
+	defer r.reloadMu.Unlock()
+	if r.siteConfigs == nil {
+		r.siteConfigs = make(map[string]SiteConfig)
+		r.siteHarvesters = make(map[string][]captchatoolsgo.Harvester)
+	}
+
+	// Remove the sites no longer configured
+	var errs []error
+	for id := range r.siteConfigs {
+		if _, ok := fc.Sites[id]; ok {
+			continue
+		}
+		delete(r.siteConfigs, id)
+		delete(r.siteHarvesters, id)
+		if err := r.Remove(id); err != nil && !errors.Is(err, ErrUnknownSite) {
+			errs = append(errs, err)
+		}
+	}
+
+	// Add or update the others. API keys are compared once resolved so keys rotated in
+	// the environment or in a file are picked up too.
+	applied := 0
+	for _, id := range fc.siteIDs() {
+		sc := fc.Sites[id].resolved()
+		previous, existed := r.siteConfigs[id]
+		if existed && reflect.DeepEqual(previous, sc) {
+			continue
+		}
+		if err := r.applySite(id, sc, previous, existed, l); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		r.siteConfigs[id] = sc
+		applied++
+	}
+	r.logger = l
+	if applied > 0 {
+		WithFields(r.logger, "count", applied).Info("Applied the configuration of sites")
+	}
+	return errors.Join(errs...)
+}
+
+// applySite registers the site configured by sc under id, or updates it if it existed
+// with the previous configuration. r.reloadMu must be held.
+func (r *Registry) applySite(id string, sc, previous SiteConfig, existed bool, l Logger) error {
+	opts, harvesters, err := sc.options(id, previous, r.siteHarvesters[id])
+	if err != nil {
+		return err
+	}
+	opts = append(opts, WithLogger(WithFields(l, "site", id)))
+	opts = append(opts, r.configOpts...)
+
+	if existed && previous.Site() == sc.Site() {
+		solver, ok := r.Solver(id)
+		if ok {
+			if err := solver.Update(opts...); err != nil {
+				return fmt.Errorf("captchasolve: site %q: %w", id, err)
+			}
+			r.siteHarvesters[id] = harvesters
+			return nil
+		}
+	}
+	if existed {
+		if err := r.Remove(id); err != nil && !errors.Is(err, ErrUnknownSite) {
+			return err
+		}
+	}
+	if err := r.Register(id, sc.Site(), opts...); err != nil {
+		return err
+	}
+	r.siteHarvesters[id] = harvesters
+	return nil
+}
+
+// WatchConfig reloads the configuration file at path into the Registry every interval
+// until ctx is done, picking up changes to the file and to the API keys it references.
+// See Reload. Problems, such as a file that isn't valid, are logged once and the
+// configuration in use is kept. It returns ctx.Err().
+//
+// Example:
+//
+//	registry, err := LoadConfig(path)
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	go registry.WatchConfig(ctx, path, 10*time.Second)
+func (r *Registry) WatchConfig(ctx context.Context, path string, interval time.Duration) error {
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var lastErr string
+	for {
+		err := r.reloadFile(path)
+		if err != nil && err.Error() != lastErr {
+			WithFields(r.currentLogger(), "path", path, "error", err).Error("Failed to reload configuration")
+		}
+		lastErr = ""
+		if err != nil {
+			lastErr = err.Error()
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// reloadFile reloads the configuration file at path into the Registry.
+func (r *Registry) reloadFile(path string) error {
+	fc, err := ReadConfig(path)
+	if err != nil {
+		return err
+	}
+	return r.Reload(fc)
+}
+
+// currentLogger returns the logger configured by the last Reload.
+func (r *Registry) currentLogger() Logger {
+	r.reloadMu.Lock()
+	defer r.reloadMu.Unlock()
+	return r.logger
+}
@@ -0,0 +1,171 @@
+package captchasolve
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// siteConfig returns a valid SiteConfig with a single capmonster provider using apiKey.
+func siteConfig(siteKey, apiKey string) SiteConfig {
+	return SiteConfig{
+		Type:      "v2",
+		SiteKey:   siteKey,
+		URL:       "https://retailer.example",
+		Providers: []ProviderConfig{{Type: "capmonster", APIKey: apiKey}},
+	}
+}
+
+func TestRegistry_Reload(t *testing.T) {
+	t.Run("updates changed sites keeping their tokens", func(t *testing.T) {
+		// Arrange
+		t.Setenv("TEST_CAPMONSTER_KEY", "old-key")
+		configs := fakeProviders(t)
+		registry, err := (&FileConfig{Sites: map[string]SiteConfig{
+			"retailer": siteConfig("key", "env:TEST_CAPMONSTER_KEY"),
+			"shop":     siteConfig("other-key", "shop-key"),
+		}}).Registry()
+		require.NoError(t, err)
+		defer registry.Close()
+		retailer, _ := registry.Solver("retailer")
+		shop, _ := registry.Solver("shop")
+		enqueueTokens(retailer.(*captchasolve), 1, 0)
+
+		// Act
+		t.Setenv("TEST_CAPMONSTER_KEY", "new-key")
+		err = registry.Reload(&FileConfig{Sites: map[string]SiteConfig{
+			"retailer": siteConfig("key", "env:TEST_CAPMONSTER_KEY"),
+			"shop":     siteConfig("other-key", "shop-key"),
+		}})
+
+		// Assert
+		require.NoError(t, err)
+		require.Len(t, *configs, 3, "only the rotated key should create a harvester")
+		assert.Equal(t, "new-key", (*configs)[2].Api_key)
+		solver, _ := registry.Solver("retailer")
+		require.Same(t, retailer, solver, "the solver should be updated in place")
+		token, err := solver.GetToken(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "valid", token.Token, "queued token should be kept")
+		solver, _ = registry.Solver("shop")
+		require.Same(t, shop, solver)
+	})
+
+	t.Run("keeps the harvesters of unchanged providers", func(t *testing.T) {
+		// Arrange
+		configs := fakeProviders(t)
+		registry, err := (&FileConfig{Sites: map[string]SiteConfig{"retailer": siteConfig("key", "api-key")}}).Registry()
+		require.NoError(t, err)
+		defer registry.Close()
+		solver, _ := registry.Solver("retailer")
+		c := solver.(*captchasolve)
+		c.recordCall(0, c.harvesters[0], 0, errors.New("ERROR_ZERO_BALANCE"))
+		require.Equal(t, HarvesterQuarantined, c.HarvesterStatus()[0].State)
+
+		// Act
+		sc := siteConfig("key", "api-key")
+		sc.Capacity = 10
+		sc.Prefill = PrefillConfig{Min: 1, Max: 2}
+		err = registry.Reload(&FileConfig{Sites: map[string]SiteConfig{"retailer": sc}})
+
+		// Assert
+		require.NoError(t, err)
+		require.Len(t, *configs, 1, "no harvester should be created")
+		require.Equal(t, 10, c.config.maxCapacity, "the site should be updated")
+		assert.Equal(t, HarvesterQuarantined, c.HarvesterStatus()[0].State, "the harvester should stay quarantined")
+	})
+
+	t.Run("replaces sites whose captcha changed", func(t *testing.T) {
+		// Arrange
+		fakeProviders(t)
+		registry, err := (&FileConfig{Sites: map[string]SiteConfig{"retailer": siteConfig("key", "api-key")}}).Registry()
+		require.NoError(t, err)
+		defer registry.Close()
+		previous, _ := registry.Solver("retailer")
+
+		// Act
+		err = registry.Reload(&FileConfig{Sites: map[string]SiteConfig{"retailer": siteConfig("new-key", "api-key")}})
+
+		// Assert
+		require.NoError(t, err)
+		solver, _ := registry.Solver("retailer")
+		require.NotSame(t, previous, solver)
+		site, _ := registry.Site("retailer")
+		assert.Equal(t, "new-key", site.SiteKey)
+		_, err = previous.GetToken(context.Background())
+		require.ErrorIs(t, err, ErrPoolClosed)
+	})
+
+	t.Run("adds and removes sites", func(t *testing.T) {
+		// Arrange
+		fakeProviders(t)
+		registry, err := (&FileConfig{Sites: map[string]SiteConfig{"retailer": siteConfig("key", "api-key")}}).Registry()
+		require.NoError(t, err)
+		defer registry.Close()
+		require.NoError(t, registry.Register("manual", Site{SiteKey: "manual"}, WithHarvester(&fakeHarvester{})))
+
+		// Act
+		err = registry.Reload(&FileConfig{Sites: map[string]SiteConfig{"shop": siteConfig("other-key", "api-key")}})
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, []string{"manual", "shop"}, registry.IDs(), "sites registered in code are kept")
+	})
+
+	t.Run("rejects invalid configurations", func(t *testing.T) {
+		// Arrange
+		fakeProviders(t)
+		registry, err := (&FileConfig{Sites: map[string]SiteConfig{"retailer": siteConfig("key", "api-key")}}).Registry()
+		require.NoError(t, err)
+		defer registry.Close()
+		previous, _ := registry.Solver("retailer")
+
+		// Act
+		err = registry.Reload(&FileConfig{Sites: map[string]SiteConfig{"retailer": siteConfig("key", "")}})
+
+		// Assert
+		require.ErrorContains(t, err, "sites.retailer.providers[0].api_key: required")
+		solver, _ := registry.Solver("retailer")
+		require.Same(t, previous, solver)
+	})
+}
+
+func TestRegistry_WatchConfig(t *testing.T) {
+	// Arrange
+	fakeProviders(t)
+	path := writeConfig(t, "captchasolve.yaml", yamlConfig)
+	t.Setenv("TEST_2CAPTCHA_KEY", "2captcha-key")
+	registry, err := LoadConfig(path)
+	require.NoError(t, err)
+	defer registry.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- registry.WatchConfig(ctx, path, 10*time.Millisecond) }()
+
+	capacity := func() int {
+		solver, _ := registry.Solver("retailer")
+		c := solver.(*captchasolve)
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.maxCapacity
+	}
+
+	// Act: an invalid file is skipped
+	require.NoError(t, os.WriteFile(path, []byte("sites: ["), 0o600))
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, 10, capacity())
+
+	// Act: a valid change is applied
+	require.NoError(t, os.WriteFile(path, []byte(strings.Replace(yamlConfig, "capacity: 10", "capacity: 20", 1)), 0o600))
+
+	// Assert
+	require.Eventually(t, func() bool { return capacity() == 20 }, time.Second, time.Millisecond)
+	cancel()
+	require.ErrorIs(t, <-done, context.Canceled)
+}
@@ -0,0 +1,526 @@
+// Package remote implements captchasolve.CaptchaSolve over the HTTP API of a token server,
+// such as cmd/captchasolve-server, so services can share one pool of tokens by swapping
+// captchasolve.New for New.
+//
+// Example:
+//
+//	solver, err := remote.New("http://127.0.0.1:8080", "retailer", remote.WithAPIKey(os.Getenv("CAPTCHASOLVE_API_KEY")))
+//	...
+//	defer solver.Close()
+//	token, err := solver.GetToken(ctx)
+//
+// The deadline of the context passed to GetToken is sent to the server, which stops
+// waiting for a token at the same time as the caller. Requests that don't reach the
+// server, or that it answers as unavailable, are retried according to the RetryPolicy.
+// Errors answered by the server match the errors of the captchasolve package with
+// errors.Is, such as captchasolve.ErrBudgetExceeded.
+package remote
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	captchatoolsgo "github.com/Matthew17-21/Captcha-Tools/captchatools-go"
+	"github.com/Matthew17-21/CaptchaSolve"
+	"github.com/Matthew17-21/CaptchaSolve/server"
+)
+
+// requestTimeout bounds the requests made by the methods that don't take a context.
+const requestTimeout = 10 * time.Second
+
+// maxIdleConns is the number of idle connections kept to the server by the default HTTP
+// client. Token requests long-poll, so concurrent callers each hold a connection.
+const maxIdleConns = 64
+
+// ErrAdditionalData is returned by GetToken when it is given AdditionalData, which the
+// token server doesn't take.
+var ErrAdditionalData = errors.New("remote: the token server doesn't take AdditionalData")
+
+// DefaultRetryPolicy is the RetryPolicy of a Client unless set with WithRetryPolicy. It
+// makes up to 4 attempts, retrying the errors reported by Retryable.
+var DefaultRetryPolicy captchasolve.RetryPolicy = captchasolve.ExponentialBackoff{
+	MaxAttempts: 4,
+	BaseDelay:   250 * time.Millisecond,
+	MaxDelay:    4 * time.Second,
+	Jitter:      0.2,
+	Retryable:   Retryable,
+}
+
+// Client is a captchasolve.CaptchaSolve getting the tokens of a site from a token server.
+// Its settings, harvesters and background workers are those of the server: Start does
+// nothing and Update isn't supported.
+type Client struct {
+	baseURL *url.URL
+	site    string
+	apiKey  string
+	http    *http.Client
+	retry   captchasolve.RetryPolicy
+	logger  captchasolve.Logger
+
+	// ctx is cancelled by Close, cancelling the calls in progress, which calls tracks.
+	ctx    context.Context
+	cancel context.CancelFunc
+	calls  sync.WaitGroup
+
+	// closed is set once the client has been shut down. Guarded by mu.
+	mu     sync.Mutex
+	closed bool
+}
+
+var _ captchasolve.CaptchaSolve = (*Client)(nil)
+
+// Option customizes a Client.
+type Option func(c *Client)
+
+// WithAPIKey sets the API key sent to the server as a bearer token.
+func WithAPIKey(key string) Option {
+	return func(c *Client) {
+		c.apiKey = key
+	}
+}
+
+// WithHTTPClient sets the HTTP client the requests are sent with, for example to
+// configure TLS. It mustn't have a timeout shorter than the longest wait for a token. By
+// default, a client keeping up to 64 idle connections to the server is used.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		if hc != nil {
+			c.http = hc
+		}
+	}
+}
+
+// WithRetryPolicy sets how failed requests are retried. Defaults to DefaultRetryPolicy;
+// captchasolve.NoRetry disables retries.
+func WithRetryPolicy(p captchasolve.RetryPolicy) Option {
+	return func(c *Client) {
+		if p != nil {
+			c.retry = p
+		}
+	}
+}
+
+// WithLogger sets the logger the Client reports failed requests to. Nothing is logged by
+// default.
+func WithLogger(l captchasolve.Logger) Option {
+	return func(c *Client) {
+		if l != nil {
+			c.logger = l
+		}
+	}
+}
+
+// New creates a Client getting the tokens of site from the token server at baseURL, such
+// as "http://127.0.0.1:8080". Close or Shutdown must be called to release its
+// connections.
+func New(baseURL, site string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("remote: invalid server URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("remote: invalid server URL %q: the scheme must be http or https", baseURL)
+	}
+	if site == "" {
+		return nil, errors.New("remote: the site is required")
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = maxIdleConns
+	c := &Client{
+		baseURL: u,
+		site:    site,
+		http:    &http.Client{Transport: transport},
+		retry:   DefaultRetryPolicy,
+		logger:  captchasolve.NewSilentLogger(),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+	return c, nil
+}
+
+// GetToken implements captchasolve.CaptchaSolve, waiting for a token from the server until
+// ctx is done. AdditionalData isn't supported and returns ErrAdditionalData. Tokens keep
+// the provider and expiry given by the server, but their Id is always 0.
+func (c *Client) GetToken(ctx context.Context, additional ...*captchatoolsgo.AdditionalData) (*captchasolve.CaptchaAnswer, error) {
+	if slices.ContainsFunc(additional, func(d *captchatoolsgo.AdditionalData) bool { return d != nil }) {
+		return nil, ErrAdditionalData
+	}
+	ctx, done, err := c.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	for {
+		var token server.TokenResponse
+		err := c.withRetries(ctx, func() error {
+			query := url.Values{}
+			if deadline, ok := ctx.Deadline(); ok {
+				wait := time.Until(deadline).Truncate(time.Millisecond)
+				if wait <= 0 {
+					return context.DeadlineExceeded
+				}
+				query.Set("timeout", wait.String())
+			}
+			return c.send(ctx, http.MethodGet, "/token", query, nil, &token)
+		})
+		if err == nil {
+			answer := captchatoolsgo.CaptchaAnswer{Token: token.Token, UserAgent: token.UserAgent}
+			return captchasolve.NewRemoteAnswer(answer, token.Provider, token.ExpiresAt), nil
+		}
+		if cause := context.Cause(ctx); cause != nil {
+			return nil, cause
+		}
+		// The server stopped waiting before the caller did, so wait again unless less
+		// time is left than can be sent as a timeout
+		if !errors.Is(err, context.DeadlineExceeded) {
+			return nil, err
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < time.Millisecond {
+			return nil, context.DeadlineExceeded
+		}
+	}
+}
+
+// ClearTokens implements captchasolve.CaptchaSolve, clearing the queued tokens of the site
+// on the server. Failures are logged.
+func (c *Client) ClearTokens() {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	ctx, done, err := c.begin(ctx)
+	if err != nil {
+		return
+	}
+	defer done()
+	err = c.withRetries(ctx, func() error { return c.send(ctx, http.MethodPost, "/clear", nil, nil, nil) })
+	if err != nil {
+		captchasolve.WithFields(c.logger, "site", c.site, "error", err).Warn("Failed to clear the tokens")
+	}
+}
+
+// ReportBadToken implements captchasolve.CaptchaSolve, reporting a token returned by
+// GetToken to the server. It isn't retried, as the server only takes a token once.
+func (c *Client) ReportBadToken(token *captchasolve.CaptchaAnswer) error {
+	if token == nil || token.Token == "" {
+		return captchasolve.ErrUnknownToken
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	ctx, done, err := c.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+	return c.send(ctx, http.MethodPost, "/report", nil, server.ReportRequest{Token: token.Token}, nil)
+}
+
+// HarvesterStatus implements captchasolve.CaptchaSolve, returning the health of the
+// harvesters of the site on the server. It returns nil if the server can't be reached.
+func (c *Client) HarvesterStatus() []captchasolve.HarvesterStatus {
+	status, ok := c.status()
+	if !ok {
+		return nil
+	}
+	health := make([]captchasolve.HarvesterStatus, len(status.Harvesters))
+	for i, h := range status.Harvesters {
+		health[i] = captchasolve.HarvesterStatus{
+			Index:    h.Index,
+			Provider: h.Provider,
+			Reason:   errorClass(h.Reason),
+		}
+		if h.State == captchasolve.HarvesterQuarantined.String() {
+			health[i].State = captchasolve.HarvesterQuarantined
+		}
+		if h.LastError != "" {
+			health[i].LastError = errors.New(h.LastError)
+		}
+	}
+	return health
+}
+
+// Stats implements captchasolve.CaptchaSolve, returning the statistics of the harvesters
+// of the site on the server. It returns nil if the server can't be reached.
+func (c *Client) Stats() []captchasolve.HarvesterStats {
+	status, ok := c.status()
+	if !ok {
+		return nil
+	}
+	stats := make([]captchasolve.HarvesterStats, len(status.Harvesters))
+	for i, h := range status.Harvesters {
+		stats[i] = captchasolve.HarvesterStats{
+			Index:     h.Index,
+			Provider:  h.Provider,
+			Successes: h.Successes,
+			Failures:  make(map[captchasolve.ErrorClass]int, len(h.Failures)),
+			P50:       time.Duration(h.P50) * time.Millisecond,
+			P90:       time.Duration(h.P90) * time.Millisecond,
+			P99:       time.Duration(h.P99) * time.Millisecond,
+		}
+		for class, n := range h.Failures {
+			stats[i].Failures[errorClass(class)] += n
+		}
+	}
+	return stats
+}
+
+// PoolStatus implements captchasolve.CaptchaSolve, returning the state of the pool of the
+// site on the server. It returns the zero PoolStatus if the server can't be reached.
+func (c *Client) PoolStatus() captchasolve.PoolStatus {
+	status, _ := c.status()
+	return captchasolve.PoolStatus{Queued: status.Queued, Waiters: status.Waiters, InFlight: status.InFlight}
+}
+
+// Start implements captchasolve.CaptchaSolve. The background workers run on the server,
+// so it only reports whether the client has been shut down.
+func (c *Client) Start(context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return captchasolve.ErrPoolClosed
+	}
+	return nil
+}
+
+// Update implements captchasolve.CaptchaSolve. The settings are those of the server, so it
+// always fails with an error matching errors.ErrUnsupported.
+func (c *Client) Update(...captchasolve.ClientOption) error {
+	return fmt.Errorf("remote: %w: the settings of site %q are managed by the token server", errors.ErrUnsupported, c.site)
+}
+
+// Close implements captchasolve.CaptchaSolve, cancelling the calls in progress and closing
+// the idle connections. The tokens queued on the server are kept.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	c.closed = true
+	c.mu.Unlock()
+	c.cancel()
+	c.calls.Wait()
+	c.http.CloseIdleConnections()
+	return nil
+}
+
+// Shutdown implements captchasolve.CaptchaSolve, waiting for the calls in progress before
+// closing the client. If ctx is done first, the calls are cancelled and its error is
+// returned.
+func (c *Client) Shutdown(ctx context.Context) error {
+	c.mu.Lock()
+	c.closed = true
+	c.mu.Unlock()
+
+	idle := make(chan struct{})
+	go func() {
+		c.calls.Wait()
+		close(idle)
+	}()
+	select {
+	case <-idle:
+		return c.Close()
+	case <-ctx.Done():
+		c.Close()
+		return ctx.Err()
+	}
+}
+
+// begin tracks a call until done is called, returning a context that is also cancelled,
+// with captchasolve.ErrPoolClosed as its cause, by Close.
+func (c *Client) begin(ctx context.Context) (context.Context, func(), error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil, nil, captchasolve.ErrPoolClosed
+	}
+	c.calls.Add(1)
+	ctx, cancel := context.WithCancelCause(ctx)
+	stop := context.AfterFunc(c.ctx, func() { cancel(captchasolve.ErrPoolClosed) })
+	return ctx, func() {
+		stop()
+		cancel(nil)
+		c.calls.Done()
+	}, nil
+}
+
+// status returns the status of the site on the server, logging failures.
+func (c *Client) status() (server.SiteStatus, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	ctx, done, err := c.begin(ctx)
+	if err != nil {
+		return server.SiteStatus{}, false
+	}
+	defer done()
+
+	var response server.StatusResponse
+	err = c.withRetries(ctx, func() error { return c.send(ctx, http.MethodGet, "/status", nil, nil, &response) })
+	if err != nil {
+		captchasolve.WithFields(c.logger, "site", c.site, "error", err).Warn("Failed to get the status")
+		return server.SiteStatus{}, false
+	}
+	status, ok := response.Sites[c.site]
+	return status, ok
+}
+
+// withRetries calls call until it succeeds, ctx is done or the retry policy gives up.
+func (c *Client) withRetries(ctx context.Context, call func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := call()
+		if err == nil || ctx.Err() != nil {
+			return err
+		}
+		delay, ok := c.retry.Backoff(attempt, err)
+		if !ok {
+			return err
+		}
+		captchasolve.WithFields(c.logger, "site", c.site, "delay", delay, "attempt", attempt, "error", err).Debug("Retrying a request")
+
+		t := time.NewTimer(delay)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return err
+		}
+	}
+}
+
+// send sends a request for the site to the server, encoding body as JSON if it isn't nil,
+// and decodes the JSON response into v if it isn't nil.
+func (c *Client) send(ctx context.Context, method, path string, query url.Values, body, v any) error {
+	u := c.baseURL.JoinPath(path)
+	q := url.Values{"site": {c.site}}
+	for key, values := range query {
+		q[key] = values
+	}
+	u.RawQuery = q.Encode()
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	defer io.Copy(io.Discard, resp.Body) // Drain the body so the connection is reused
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp)
+	}
+	if v == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("remote: decoding the response of %s %s: %w", method, path, err)
+	}
+	return nil
+}
+
+// Error is an error answered by the token server, or by a proxy in front of it. It
+// matches the error of the captchasolve package it stands for with errors.Is.
+type Error struct {
+	StatusCode int    // HTTP status of the response
+	Code       string // Error code sent by the server, such as "budget_exceeded"; empty if it sent none
+	Message    string // Message sent by the server, or the body of the response
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("remote: %s (HTTP %d)", e.Message, e.StatusCode)
+}
+
+// codeErrors maps the error codes sent by the server to the error they stand for.
+var codeErrors = map[string]error{
+	"timeout":           context.DeadlineExceeded,
+	"budget_exceeded":   captchasolve.ErrBudgetExceeded,
+	"harvesters_failed": captchasolve.ErrAllHarvestersFailed,
+	"unknown_site":      captchasolve.ErrUnknownSite,
+	"unknown_token":     captchasolve.ErrUnknownToken,
+}
+
+// unavailableErrors are the errors the server reports with the "unavailable" code, told
+// apart by their message.
+var unavailableErrors = []error{captchasolve.ErrPoolClosed, captchasolve.ErrNoHarvesters, captchasolve.ErrAllHarvestersQuarantined}
+
+// permanentErrors are the unavailableErrors that retrying won't get rid of.
+var permanentErrors = []error{captchasolve.ErrPoolClosed, captchasolve.ErrNoHarvesters}
+
+func (e *Error) Is(target error) bool {
+	if err, ok := codeErrors[e.Code]; ok {
+		return target == err
+	}
+	if e.Code == "unavailable" && slices.Contains(unavailableErrors, target) {
+		return strings.Contains(e.Message, target.Error())
+	}
+	return false
+}
+
+// responseError returns the error answered in resp.
+func responseError(resp *http.Response) error {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<16))
+	var body server.ErrorResponse
+	if err := json.Unmarshal(data, &body); err != nil || body.Error == "" {
+		message := strings.TrimSpace(string(data))
+		if message == "" {
+			message = http.StatusText(resp.StatusCode)
+		}
+		return &Error{StatusCode: resp.StatusCode, Message: message}
+	}
+	return &Error{StatusCode: resp.StatusCode, Code: body.Code, Message: body.Error}
+}
+
+// Retryable reports whether a failed request is worth retrying: when it didn't reach the
+// server, when the server is unavailable for a while, such as when every harvester is
+// quarantined, or when a proxy in front of it failed. A closed pool or one without
+// harvesters stays unavailable, so it isn't retried.
+func Retryable(err error) bool {
+	var e *Error
+	if errors.As(err, &e) {
+		switch e.StatusCode {
+		case http.StatusServiceUnavailable:
+			return !slices.ContainsFunc(permanentErrors, func(target error) bool { return errors.Is(e, target) })
+		case http.StatusBadGateway, http.StatusGatewayTimeout:
+			return e.Code == "" // Answered by a proxy rather than the server
+		}
+		return false
+	}
+	var urlErr *url.Error
+	return errors.As(err, &urlErr) && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+}
+
+// errorClass returns the ErrorClass named name, or captchasolve.ErrorClassUnknown.
+func errorClass(name string) captchasolve.ErrorClass {
+	// Every class but ErrorClassUnknown has a name of its own, so stop at the first unnamed one
+	unknown := captchasolve.ErrorClassUnknown.String()
+	for class := captchasolve.ErrorClassUnknown + 1; class.String() != unknown; class++ {
+		if class.String() == name {
+			return class
+		}
+	}
+	return captchasolve.ErrorClassUnknown
+}
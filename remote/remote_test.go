@@ -0,0 +1,370 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	captchatoolsgo "github.com/Matthew17-21/Captcha-Tools/captchatools-go"
+	"github.com/Matthew17-21/CaptchaSolve"
+	"github.com/Matthew17-21/CaptchaSolve/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeHarvester is a harvester returning numbered tokens after a delay.
+type fakeHarvester struct {
+	calls atomic.Int32
+	delay time.Duration
+}
+
+func (f *fakeHarvester) GetToken(additional ...*captchatoolsgo.AdditionalData) (*captchatoolsgo.CaptchaAnswer, error) {
+	return f.GetTokenWithContext(context.Background(), additional...)
+}
+
+func (f *fakeHarvester) GetTokenWithContext(ctx context.Context, _ ...*captchatoolsgo.AdditionalData) (*captchatoolsgo.CaptchaAnswer, error) {
+	n := f.calls.Add(1)
+	select {
+	case <-time.After(f.delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return &captchatoolsgo.CaptchaAnswer{Token: "token-" + strconv.Itoa(int(n)), UserAgent: "agent"}, nil
+}
+
+func (f *fakeHarvester) GetBalance() (float32, error) { return 1, nil }
+
+// fastRetries retries like DefaultRetryPolicy, without waiting.
+var fastRetries = captchasolve.ExponentialBackoff{MaxAttempts: 3, Retryable: Retryable}
+
+// newServer serves the "retailer" site, solved by solver, and returns its URL.
+func newServer(t *testing.T, solver captchasolve.CaptchaSolve, opts ...server.Option) string {
+	t.Helper()
+	srv := httptest.NewServer(server.New(server.Sites{"retailer": solver}, opts...))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+// newClient returns a Client of the "retailer" site served at baseURL.
+func newClient(t *testing.T, baseURL string, opts ...Option) *Client {
+	t.Helper()
+	c, err := New(baseURL, "retailer", append([]Option{WithRetryPolicy(fastRetries)}, opts...)...)
+	require.NoError(t, err)
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// newSolver returns a solver backed by h.
+func newSolver(t *testing.T, h captchatoolsgo.Harvester, opts ...captchasolve.ClientOption) captchasolve.CaptchaSolve {
+	t.Helper()
+	solver := captchasolve.New(append([]captchasolve.ClientOption{captchasolve.WithHarvester(h)}, opts...)...)
+	t.Cleanup(func() { solver.Close() })
+	return solver
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		baseURL string
+		site    string
+		err     string
+	}{
+		{name: "valid", baseURL: "http://127.0.0.1:8080", site: "retailer"},
+		{name: "no scheme", baseURL: "127.0.0.1:8080", site: "retailer", err: "invalid server URL"},
+		{name: "unsupported scheme", baseURL: "ftp://127.0.0.1", site: "retailer", err: "the scheme must be http or https"},
+		{name: "no site", baseURL: "http://127.0.0.1:8080", err: "the site is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := New(tt.baseURL, tt.site)
+
+			if tt.err != "" {
+				require.ErrorContains(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.NoError(t, c.Close())
+		})
+	}
+}
+
+// deadlineContext has a deadline, but is never done, as a context whose timer hasn't
+// fired yet.
+type deadlineContext struct {
+	context.Context
+	deadline time.Time
+}
+
+func (c deadlineContext) Deadline() (time.Time, bool) { return c.deadline, true }
+
+func TestClient_GetToken(t *testing.T) {
+	t.Run("returns a token", func(t *testing.T) {
+		// Arrange
+		c := newClient(t, newServer(t, newSolver(t, &fakeHarvester{})))
+
+		// Act
+		token, err := c.GetToken(context.Background())
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, "token-1", token.Token)
+		assert.Equal(t, "agent", token.UserAgent)
+		assert.Equal(t, "*remote.fakeHarvester", token.Provider())
+		assert.WithinDuration(t, time.Now().Add(2*time.Minute), token.ExpiresAt(), 5*time.Second)
+	})
+
+	t.Run("stops waiting at the deadline", func(t *testing.T) {
+		// Arrange
+		solver := newSolver(t, &fakeHarvester{delay: time.Hour})
+		c := newClient(t, newServer(t, solver))
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		// Act
+		_, err := c.GetToken(ctx)
+
+		// Assert
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.Eventually(t, func() bool { return solver.PoolStatus().Waiters == 0 }, time.Second, time.Millisecond,
+			"the deadline should reach the solver")
+	})
+
+	t.Run("stops once less than a millisecond is left", func(t *testing.T) {
+		// Arrange
+		var requests atomic.Int32
+		srv := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { requests.Add(1) }))
+		defer srv.Close()
+		c := newClient(t, srv.URL)
+		ctx := deadlineContext{Context: context.Background(), deadline: time.Now().Add(500 * time.Microsecond)}
+
+		// Act
+		done := make(chan error, 1)
+		go func() {
+			_, err := c.GetToken(ctx)
+			done <- err
+		}()
+
+		// Assert
+		select {
+		case err := <-done:
+			require.ErrorIs(t, err, context.DeadlineExceeded)
+			assert.Zero(t, requests.Load())
+		case <-time.After(time.Second):
+			require.Fail(t, "GetToken kept waiting past the deadline")
+		}
+	})
+
+	t.Run("waits again once the server gives up", func(t *testing.T) {
+		// Arrange
+		c := newClient(t, newServer(t, newSolver(t, &fakeHarvester{delay: 150 * time.Millisecond}), server.WithMaxWait(50*time.Millisecond)))
+
+		// Act
+		token, err := c.GetToken(context.Background())
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, "token-1", token.Token)
+	})
+
+	t.Run("retries while the server is unavailable", func(t *testing.T) {
+		// Arrange
+		handler := server.New(server.Sites{"retailer": newSolver(t, &fakeHarvester{})})
+		var requests atomic.Int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if requests.Add(1) < 3 {
+				http.Error(w, "restarting", http.StatusServiceUnavailable)
+				return
+			}
+			handler.ServeHTTP(w, r)
+		}))
+		defer srv.Close()
+		c := newClient(t, srv.URL)
+
+		// Act
+		token, err := c.GetToken(context.Background())
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, "token-1", token.Token)
+		assert.Equal(t, int32(3), requests.Load())
+	})
+
+	t.Run("reports the errors of the server", func(t *testing.T) {
+		baseURL := newServer(t, newSolver(t, &fakeHarvester{}), server.WithAPIKeys("secret"))
+
+		_, err := newClient(t, baseURL, WithAPIKey("secret")).GetToken(context.Background())
+		require.NoError(t, err)
+
+		_, err = newClient(t, baseURL, WithAPIKey("guess")).GetToken(context.Background())
+		var remoteErr *Error
+		require.ErrorAs(t, err, &remoteErr)
+		assert.Equal(t, http.StatusUnauthorized, remoteErr.StatusCode)
+		assert.Equal(t, "unauthorized", remoteErr.Code)
+
+		c, err := New(baseURL, "shop", WithAPIKey("secret"))
+		require.NoError(t, err)
+		defer c.Close()
+		_, err = c.GetToken(context.Background())
+		require.ErrorIs(t, err, captchasolve.ErrUnknownSite)
+	})
+
+	t.Run("rejects AdditionalData", func(t *testing.T) {
+		c := newClient(t, newServer(t, newSolver(t, &fakeHarvester{})))
+
+		_, err := c.GetToken(context.Background(), &captchatoolsgo.AdditionalData{UserAgent: "agent"})
+
+		require.ErrorIs(t, err, ErrAdditionalData)
+	})
+}
+
+func TestClient_ReportBadToken(t *testing.T) {
+	// Arrange
+	solver := newSolver(t, &fakeHarvester{})
+	c := newClient(t, newServer(t, solver))
+	token, err := c.GetToken(context.Background())
+	require.NoError(t, err)
+
+	// Act
+	err = c.ReportBadToken(token)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, map[captchasolve.ErrorClass]int{captchasolve.ErrorClassRejected: 1}, solver.Stats()[0].Failures)
+	require.ErrorIs(t, c.ReportBadToken(token), captchasolve.ErrUnknownToken, "tokens are only reported once")
+	require.ErrorIs(t, c.ReportBadToken(nil), captchasolve.ErrUnknownToken)
+}
+
+func TestClient_Status(t *testing.T) {
+	// Arrange
+	solver := newSolver(t, &fakeHarvester{}, captchasolve.WithSurplus(1))
+	c := newClient(t, newServer(t, solver))
+	token, err := c.GetToken(context.Background())
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return solver.PoolStatus().Queued == 1 }, time.Second, time.Millisecond)
+	require.NoError(t, c.ReportBadToken(token))
+
+	// Act
+	pool := c.PoolStatus()
+	health := c.HarvesterStatus()
+	stats := c.Stats()
+
+	// Assert
+	assert.Equal(t, captchasolve.PoolStatus{Queued: 1}, pool)
+	require.Len(t, health, 1)
+	assert.Equal(t, "*remote.fakeHarvester", health[0].Provider)
+	assert.Equal(t, captchasolve.HarvesterActive, health[0].State)
+	require.Len(t, stats, 1)
+	assert.Equal(t, 2, stats[0].Successes)
+	assert.Equal(t, map[captchasolve.ErrorClass]int{captchasolve.ErrorClassRejected: 1}, stats[0].Failures)
+
+	c.ClearTokens()
+	assert.Equal(t, captchasolve.PoolStatus{}, c.PoolStatus())
+}
+
+func TestClient_Close(t *testing.T) {
+	t.Run("cancels the calls in progress", func(t *testing.T) {
+		// Arrange
+		c := newClient(t, newServer(t, newSolver(t, &fakeHarvester{delay: time.Hour})))
+		errs := make(chan error, 1)
+		go func() {
+			_, err := c.GetToken(context.Background())
+			errs <- err
+		}()
+		time.Sleep(50 * time.Millisecond)
+
+		// Act
+		require.NoError(t, c.Close())
+
+		// Assert
+		require.ErrorIs(t, <-errs, captchasolve.ErrPoolClosed)
+		_, err := c.GetToken(context.Background())
+		require.ErrorIs(t, err, captchasolve.ErrPoolClosed)
+		require.ErrorIs(t, c.Start(context.Background()), captchasolve.ErrPoolClosed)
+	})
+
+	t.Run("shutdown waits for the calls in progress", func(t *testing.T) {
+		// Arrange
+		c := newClient(t, newServer(t, newSolver(t, &fakeHarvester{delay: 100 * time.Millisecond})))
+		errs := make(chan error, 1)
+		go func() {
+			_, err := c.GetToken(context.Background())
+			errs <- err
+		}()
+		time.Sleep(50 * time.Millisecond)
+
+		// Act
+		err := c.Shutdown(context.Background())
+
+		// Assert
+		require.NoError(t, err)
+		require.NoError(t, <-errs)
+	})
+}
+
+func TestClient_Update(t *testing.T) {
+	c := newClient(t, "http://127.0.0.1:8080")
+
+	err := c.Update(captchasolve.WithSurplus(1))
+
+	require.ErrorIs(t, err, errors.ErrUnsupported)
+}
+
+func TestError_Is(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    *Error
+		target error
+	}{
+		{name: "timeout", err: &Error{StatusCode: 504, Code: "timeout"}, target: context.DeadlineExceeded},
+		{name: "budget", err: &Error{StatusCode: 429, Code: "budget_exceeded"}, target: captchasolve.ErrBudgetExceeded},
+		{name: "failed", err: &Error{StatusCode: 502, Code: "harvesters_failed"}, target: captchasolve.ErrAllHarvestersFailed},
+		{name: "closed", err: &Error{StatusCode: 503, Code: "unavailable", Message: captchasolve.ErrPoolClosed.Error()}, target: captchasolve.ErrPoolClosed},
+		{name: "quarantined", err: &Error{StatusCode: 503, Code: "unavailable", Message: "get token: " + captchasolve.ErrAllHarvestersQuarantined.Error()}, target: captchasolve.ErrAllHarvestersQuarantined},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.ErrorIs(t, tt.err, tt.target)
+		})
+	}
+
+	require.NotErrorIs(t, &Error{StatusCode: 503, Code: "unavailable", Message: captchasolve.ErrPoolClosed.Error()}, captchasolve.ErrNoHarvesters)
+	require.NotErrorIs(t, &Error{StatusCode: 504, Message: "Gateway Timeout"}, context.DeadlineExceeded)
+}
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		retryable bool
+	}{
+		{name: "unavailable", err: &Error{StatusCode: 503, Code: "unavailable"}, retryable: true},
+		{name: "quarantined", err: &Error{StatusCode: 503, Code: "unavailable", Message: captchasolve.ErrAllHarvestersQuarantined.Error()}, retryable: true},
+		{name: "closed", err: &Error{StatusCode: 503, Code: "unavailable", Message: captchasolve.ErrPoolClosed.Error()}},
+		{name: "no harvesters", err: &Error{StatusCode: 503, Code: "unavailable", Message: captchasolve.ErrNoHarvesters.Error()}},
+		{name: "proxy failed", err: &Error{StatusCode: 502, Message: "Bad Gateway"}, retryable: true},
+		{name: "harvesters failed", err: &Error{StatusCode: 502, Code: "harvesters_failed"}},
+		{name: "server timed out", err: &Error{StatusCode: 504, Code: "timeout"}},
+		{name: "unauthorized", err: &Error{StatusCode: 401, Code: "unauthorized"}},
+		{name: "connection refused", err: &url.Error{Op: "Get", URL: "http://127.0.0.1", Err: errors.New("connection refused")}, retryable: true},
+		{name: "cancelled", err: &url.Error{Op: "Get", URL: "http://127.0.0.1", Err: context.Canceled}},
+		{name: "bad response", err: errors.New("remote: decoding the response of GET /token: EOF")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.retryable, Retryable(tt.err))
+		})
+	}
+}
+
+func TestErrorClass(t *testing.T) {
+	assert.Equal(t, captchasolve.ErrorClassNoBalance, errorClass("no_balance"))
+	assert.Equal(t, captchasolve.ErrorClassRejected, errorClass("rejected"))
+	assert.Equal(t, captchasolve.ErrorClassUnknown, errorClass("unknown"))
+	assert.Equal(t, captchasolve.ErrorClassUnknown, errorClass("solar_flare"))
+}
@@ -0,0 +1,48 @@
+package captchasolve
+
+import captchatoolsgo "github.com/Matthew17-21/Captcha-Tools/captchatools-go"
+
+// ReportBadToken reports a token returned by GetToken that the site rejected, recording
+// ErrTokenRejected as a failure of the harvester that solved it. Strategies, such as
+// PriorityFailover, thus move away from providers whose tokens don't pass. Nothing is
+// recorded if the harvester isn't configured anymore, for example because Update
+// replaced it since.
+//
+// It returns ErrUnknownToken if the token wasn't harvested by a CaptchaSolve.
+//
+// Example:
+//
+//	token, err := solver.GetToken(ctx)
+//	...
+//	if rejected {
+//	    solver.ReportBadToken(token)
+//	}
+func (c *captchasolve) ReportBadToken(token *CaptchaAnswer) error {
+	if token == nil || token.harvester == nil {
+		return ErrUnknownToken
+	}
+
+	c.mu.Lock()
+	index := c.harvesterIndexLocked(token.harvester)
+	if index >= 0 {
+		WithFields(c.harvesterLogger(index, token.harvester), "token_id", token.Id()).Warn("Token was rejected")
+		c.recordFailureLocked(index, ErrTokenRejected)
+	}
+	c.mu.Unlock()
+	if index < 0 {
+		return nil
+	}
+	c.reportFailed(index, token.harvester, ErrTokenRejected)
+	return nil
+}
+
+// harvesterIndexLocked returns the index of h among the configured harvesters, or -1 if
+// it isn't configured. c.mu must be held.
+func (c *captchasolve) harvesterIndexLocked(h captchatoolsgo.Harvester) int {
+	for i, configured := range c.harvesters {
+		if sameHarvester(configured, h) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,59 @@
+package captchasolve
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReportBadToken(t *testing.T) {
+	t.Run("records a failure of the harvester that solved the token", func(t *testing.T) {
+		// Arrange
+		metrics := &recordingMetrics{}
+		h1, h2 := &fakeHarvester{}, &fakeHarvester{}
+		c := New(WithHarvester(h1), WithHarvester(h2), WithStrategy(PriorityFailover(1)), WithMetrics(metrics)).(*captchasolve)
+		defer c.Close()
+		token, err := c.GetToken(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "*captchasolve.fakeHarvester", token.Provider())
+
+		// Act
+		err = c.ReportBadToken(token)
+
+		// Assert
+		require.NoError(t, err)
+		stats := c.Stats()
+		assert.Equal(t, map[ErrorClass]int{ErrorClassRejected: 1}, stats[0].Failures)
+		assert.Empty(t, stats[1].Failures)
+		assert.ErrorIs(t, c.HarvesterStatus()[0].LastError, ErrTokenRejected)
+		assert.Equal(t, HarvesterActive, c.HarvesterStatus()[0].State, "rejected tokens don't quarantine")
+		assert.Equal(t, 1, metrics.failures[ErrorClassRejected])
+		_, h, _ := c.nextHarvester()
+		assert.Same(t, h2, h, "the strategy should fail over")
+	})
+
+	t.Run("ignores replaced harvesters", func(t *testing.T) {
+		// Arrange
+		c := New(WithHarvester(&fakeHarvester{})).(*captchasolve)
+		defer c.Close()
+		token, err := c.GetToken(context.Background())
+		require.NoError(t, err)
+		require.NoError(t, c.Update(WithHarvester(&fakeHarvester{})))
+
+		// Act
+		err = c.ReportBadToken(token)
+
+		// Assert
+		require.NoError(t, err)
+		assert.Empty(t, c.Stats()[0].Failures)
+	})
+
+	t.Run("rejects tokens not harvested by a solver", func(t *testing.T) {
+		c := New(WithHarvester(&fakeHarvester{})).(*captchasolve)
+
+		require.ErrorIs(t, c.ReportBadToken(&CaptchaAnswer{}), ErrUnknownToken)
+		require.ErrorIs(t, c.ReportBadToken(nil), ErrUnknownToken)
+	})
+}
@@ -0,0 +1,97 @@
+package captchasolve
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+)
+
+// RetryPolicy decides whether a failed harvester call is retried, and after how long.
+type RetryPolicy interface {
+	// Backoff is called after the given attempt, starting at 1, failed with err. It returns
+	// how long to wait before the next attempt, and false if the call shouldn't be retried.
+	Backoff(attempt int, err error) (time.Duration, bool)
+}
+
+// NoRetry is a RetryPolicy that never retries. It is the default.
+var NoRetry RetryPolicy = ExponentialBackoff{MaxAttempts: 1}
+
+// ExponentialBackoff is a RetryPolicy retrying failed calls with an exponentially growing,
+// jittered delay.
+type ExponentialBackoff struct {
+	// MaxAttempts is the total number of calls made, including the first one.
+	MaxAttempts int
+
+	// BaseDelay is the delay before the first retry. It doubles with every retry, up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+
+	// Jitter is the fraction, between 0 and 1, of the delay that is randomized so retries
+	// from concurrent solves don't hit the provider at the same time.
+	Jitter float64
+
+	// Retryable reports whether an error is worth retrying. DefaultRetryable is used when nil.
+	Retryable func(error) bool
+}
+
+// Backoff implements RetryPolicy.
+func (b ExponentialBackoff) Backoff(attempt int, err error) (time.Duration, bool) {
+	if attempt >= b.MaxAttempts {
+		return 0, false
+	}
+	retryable := b.Retryable
+	if retryable == nil {
+		retryable = DefaultRetryable
+	}
+	if !retryable(err) {
+		return 0, false
+	}
+
+	// Double the delay for every retry, making sure it doesn't overflow
+	delay := b.BaseDelay
+	for i := 1; i < attempt && (b.MaxDelay <= 0 || delay < b.MaxDelay); i++ {
+		delay *= 2
+	}
+	if b.MaxDelay > 0 && delay > b.MaxDelay {
+		delay = b.MaxDelay
+	}
+
+	// Randomize part of the delay
+	if jitter := min(max(b.Jitter, 0), 1); jitter > 0 && delay > 0 {
+		spread := time.Duration(float64(delay) * jitter)
+		delay = delay - spread + time.Duration(rand.Int64N(int64(2*spread)+1))
+	}
+	return delay, true
+}
+
+// DefaultRetryable retries every error except those that won't go away by retrying:
+// cancelled or timed out calls, and errors that get a harvester quarantined.
+func DefaultRetryable(err error) bool {
+	class := ClassifyError(err)
+	return class != ErrorClassTimeout && !shouldQuarantine(class)
+}
+
+// retryPolicy returns the retry policy of the harvester at index i.
+func (c *captchasolve) retryPolicy(i int) RetryPolicy {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if i < len(c.harvesterSettings) && c.harvesterSettings[i].retryPolicy != nil {
+		return c.harvesterSettings[i].retryPolicy
+	}
+	if c.retry != nil {
+		return c.retry
+	}
+	return NoRetry
+}
+
+// sleep waits for d, returning ctx.Err() if ctx is done first.
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
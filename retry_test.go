@@ -0,0 +1,151 @@
+package captchasolve
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	captchatoolsgo "github.com/Matthew17-21/Captcha-Tools/captchatools-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyHarvester is a fakeHarvester failing with err for its first failures calls.
+type flakyHarvester struct {
+	fakeHarvester
+	failures int32
+}
+
+func (f *flakyHarvester) GetTokenWithContext(ctx context.Context, additional ...*captchatoolsgo.AdditionalData) (*captchatoolsgo.CaptchaAnswer, error) {
+	tkn, err := f.fakeHarvester.GetTokenWithContext(ctx, additional...)
+	if err == nil && f.calls.Load() <= f.failures {
+		return nil, errors.New("ERROR_CAPTCHA_UNSOLVABLE")
+	}
+	return tkn, err
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	transientErr := errors.New("502 bad gateway")
+
+	t.Run("doubles the delay up to the cap", func(t *testing.T) {
+		b := ExponentialBackoff{MaxAttempts: 10, BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+
+		var delays []time.Duration
+		for attempt := 1; attempt <= 4; attempt++ {
+			delay, retry := b.Backoff(attempt, transientErr)
+			require.True(t, retry)
+			delays = append(delays, delay)
+		}
+
+		assert.Equal(t, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second}, delays)
+	})
+
+	t.Run("stops after max attempts", func(t *testing.T) {
+		b := ExponentialBackoff{MaxAttempts: 2}
+
+		_, retry := b.Backoff(1, transientErr)
+		assert.True(t, retry)
+		_, retry = b.Backoff(2, transientErr)
+		assert.False(t, retry)
+	})
+
+	t.Run("keeps the jittered delay within range", func(t *testing.T) {
+		b := ExponentialBackoff{MaxAttempts: 2, BaseDelay: time.Second, Jitter: 0.5}
+
+		for i := 0; i < 100; i++ {
+			delay, _ := b.Backoff(1, transientErr)
+			assert.GreaterOrEqual(t, delay, 500*time.Millisecond)
+			assert.LessOrEqual(t, delay, 1500*time.Millisecond)
+		}
+	})
+
+	t.Run("uses the retryable predicate", func(t *testing.T) {
+		b := ExponentialBackoff{MaxAttempts: 2, Retryable: func(error) bool { return false }}
+
+		_, retry := b.Backoff(1, transientErr)
+		assert.False(t, retry)
+	})
+
+	t.Run("never retries by default", func(t *testing.T) {
+		_, retry := NoRetry.Backoff(1, transientErr)
+		assert.False(t, retry)
+	})
+}
+
+func TestDefaultRetryable(t *testing.T) {
+	assert.True(t, DefaultRetryable(errors.New("502 bad gateway")))
+	assert.True(t, DefaultRetryable(errors.New("ERROR_CAPTCHA_UNSOLVABLE")))
+	assert.False(t, DefaultRetryable(context.DeadlineExceeded))
+	assert.False(t, DefaultRetryable(errors.New("ERROR_ZERO_BALANCE")))
+	assert.False(t, DefaultRetryable(errors.New("ERROR_KEY_DOES_NOT_EXIST")))
+}
+
+func TestHarvestToken_Retry(t *testing.T) {
+	t.Run("retries until a token is harvested", func(t *testing.T) {
+		h := &flakyHarvester{failures: 2}
+		c := New(WithHarvester(h), WithRetryPolicy(ExponentialBackoff{MaxAttempts: 3, BaseDelay: time.Millisecond})).(*captchasolve)
+		resultsChan := make(chan result, 1)
+
+		c.harvestToken(context.Background(), 0, h, resultsChan)
+
+		res := <-resultsChan
+		require.NoError(t, res.err)
+		assert.Equal(t, "fake-token", res.token.Token)
+		assert.EqualValues(t, 3, h.calls.Load())
+	})
+
+	t.Run("reports the number of attempts", func(t *testing.T) {
+		h := &flakyHarvester{failures: 5}
+		c := New(WithHarvester(h), WithRetryPolicy(ExponentialBackoff{MaxAttempts: 3, BaseDelay: time.Millisecond})).(*captchasolve)
+		resultsChan := make(chan result, 1)
+
+		c.harvestToken(context.Background(), 0, h, resultsChan)
+
+		res := <-resultsChan
+		var harvesterErr *HarvesterError
+		require.ErrorAs(t, res.err, &harvesterErr)
+		assert.Equal(t, 3, harvesterErr.Attempts)
+		assert.EqualValues(t, 3, h.calls.Load())
+	})
+
+	t.Run("harvester policy overrides the client policy", func(t *testing.T) {
+		h := &flakyHarvester{failures: 5}
+		c := New(
+			WithRetryPolicy(ExponentialBackoff{MaxAttempts: 3}),
+			WithHarvester(h, WithHarvesterRetryPolicy(NoRetry)),
+		).(*captchasolve)
+		resultsChan := make(chan result, 1)
+
+		c.harvestToken(context.Background(), 0, h, resultsChan)
+
+		<-resultsChan
+		assert.EqualValues(t, 1, h.calls.Load())
+	})
+
+	t.Run("doesn't retry quarantined harvesters", func(t *testing.T) {
+		h := &fakeHarvester{err: errors.New("ERROR_ZERO_BALANCE")}
+		always := func(error) bool { return true }
+		c := New(WithHarvester(h), WithRetryPolicy(ExponentialBackoff{MaxAttempts: 3, Retryable: always})).(*captchasolve)
+		resultsChan := make(chan result, 1)
+
+		c.harvestToken(context.Background(), 0, h, resultsChan)
+
+		<-resultsChan
+		assert.EqualValues(t, 1, h.calls.Load())
+	})
+
+	t.Run("stops waiting when cancelled", func(t *testing.T) {
+		h := &flakyHarvester{failures: 5}
+		c := New(WithHarvester(h), WithRetryPolicy(ExponentialBackoff{MaxAttempts: 3, BaseDelay: time.Hour})).(*captchasolve)
+		resultsChan := make(chan result, 1)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		c.harvestToken(ctx, 0, h, resultsChan)
+
+		res := <-resultsChan
+		require.Error(t, res.err)
+		assert.EqualValues(t, 1, h.calls.Load())
+	})
+}
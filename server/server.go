@@ -0,0 +1,372 @@
+// Package server exposes CaptchaSolve solvers over HTTP, so programs that can't link the
+// library, such as scrapers written in Python or Node, can request tokens from a local
+// service. The cmd/captchasolve-server binary serves it for a configuration file.
+//
+// Example:
+//
+//	registry, err := captchasolve.LoadConfig("captchasolve.yaml")
+//	...
+//	handler := server.New(registry, server.WithAPIKeys(os.Getenv("CAPTCHASOLVE_API_KEY")))
+//	http.ListenAndServe("127.0.0.1:8080", handler)
+//
+// Every endpoint takes the ID of the site in the site query parameter and answers with
+// JSON. Errors are reported as {"error": "...", "code": "..."} with a matching status:
+//
+//	GET  /token?site=ID[&timeout=30s]  Waits for a token, up to the timeout
+//	GET  /status[?site=ID]             Pool and harvester status of one or every site
+//	POST /clear?site=ID                Clears the queued tokens of a site
+//	POST /report?site=ID               Reports the token in the {"token": "..."} body as rejected
+//
+// When API keys are set, requests must carry one in the Authorization header, as a bearer
+// token, or in the X-API-Key header. Go programs can use the remote package, which
+// implements captchasolve.CaptchaSolve over these endpoints.
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/Matthew17-21/CaptchaSolve"
+	"github.com/Matthew17-21/CaptchaSolve/internal/serving"
+)
+
+// defaultMaxWait is how long GET /token waits for a token when no timeout is given, and
+// the longest timeout it accepts, unless set with WithMaxWait.
+const defaultMaxWait = 2 * time.Minute
+
+// Solvers holds the solvers served by the Handler, by site ID. *captchasolve.Registry
+// implements it.
+type Solvers interface {
+	Solver(id string) (captchasolve.CaptchaSolve, bool)
+	IDs() []string
+}
+
+// Sites is a fixed set of solvers, by site ID, for serving solvers created with
+// captchasolve.New.
+type Sites map[string]captchasolve.CaptchaSolve
+
+// Solver implements Solvers.
+func (s Sites) Solver(id string) (captchasolve.CaptchaSolve, bool) {
+	solver, ok := s[id]
+	return solver, ok
+}
+
+// IDs implements Solvers.
+func (s Sites) IDs() []string {
+	ids := make([]string, 0, len(s))
+	for id := range s {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// Handler is an http.Handler serving tokens from Solvers. See the package documentation
+// for its endpoints.
+type Handler struct {
+	solvers Solvers
+	apiKeys serving.Keys
+	maxWait time.Duration
+	logger  captchasolve.Logger
+	mux     *http.ServeMux
+	served  serving.Tokens // Tokens handed out, so they can be reported
+}
+
+// Option customizes a Handler.
+type Option func(h *Handler)
+
+// WithAPIKeys makes the Handler reject requests that don't carry one of keys. Empty keys
+// are ignored. Every request is accepted if no key is set.
+func WithAPIKeys(keys ...string) Option {
+	return func(h *Handler) {
+		h.apiKeys = append(h.apiKeys, serving.NewKeys(keys...)...)
+	}
+}
+
+// WithMaxWait sets how long GET /token waits for a token when no timeout is given, and
+// the longest timeout it accepts. Defaults to 2 minutes.
+func WithMaxWait(d time.Duration) Option {
+	return func(h *Handler) {
+		if d > 0 {
+			h.maxWait = d
+		}
+	}
+}
+
+// WithLogger sets the logger the Handler reports failed requests to. Nothing is logged
+// by default.
+func WithLogger(l captchasolve.Logger) Option {
+	return func(h *Handler) {
+		if l != nil {
+			h.logger = l
+		}
+	}
+}
+
+// New creates a Handler serving tokens from solvers.
+func New(solvers Solvers, opts ...Option) *Handler {
+	h := &Handler{
+		solvers: solvers,
+		maxWait: defaultMaxWait,
+		logger:  captchasolve.NewSilentLogger(),
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	h.mux = http.NewServeMux()
+	h.mux.HandleFunc("GET /token", h.getToken)
+	h.mux.HandleFunc("GET /status", h.getStatus)
+	h.mux.HandleFunc("POST /clear", h.clearTokens)
+	h.mux.HandleFunc("POST /report", h.reportToken)
+	return h
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := serving.KeyFromHeaders(r.Header.Get("Authorization"), r.Header.Get("X-API-Key"))
+	if !h.apiKeys.Allow(key) {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "missing or invalid API key")
+		return
+	}
+	h.mux.ServeHTTP(w, r)
+}
+
+// TokenResponse is the response of GET /token.
+type TokenResponse struct {
+	Site      string    `json:"site"`
+	Token     string    `json:"token"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	ID        int       `json:"id"`
+	Provider  string    `json:"provider"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// getToken serves GET /token, waiting for a token until the timeout.
+func (h *Handler) getToken(w http.ResponseWriter, r *http.Request) {
+	site, solver, ok := h.solver(w, r)
+	if !ok {
+		return
+	}
+	timeout := h.maxWait
+	if param := r.URL.Query().Get("timeout"); param != "" {
+		d, err := time.ParseDuration(param)
+		if err != nil || d <= 0 {
+			writeError(w, http.StatusBadRequest, "invalid_timeout", fmt.Sprintf("invalid timeout %q", param))
+			return
+		}
+		timeout = min(d, h.maxWait)
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+	token, err := solver.GetToken(ctx)
+	if err != nil {
+		if r.Context().Err() != nil {
+			return // The client is gone
+		}
+		captchasolve.WithFields(h.logger, "site", site, "error", err).Warn("Failed to get a token")
+		status, code := errorStatus(err)
+		writeError(w, status, code, err.Error())
+		return
+	}
+
+	h.served.Add(site, token)
+	writeJSON(w, http.StatusOK, TokenResponse{
+		Site:      site,
+		Token:     token.Token,
+		UserAgent: token.UserAgent,
+		ID:        token.Id(),
+		Provider:  token.Provider(),
+		ExpiresAt: token.ExpiresAt(),
+	})
+}
+
+// StatusResponse is the response of GET /status, by site ID.
+type StatusResponse struct {
+	Sites map[string]SiteStatus `json:"sites"`
+}
+
+// SiteStatus describes the pool and harvesters of a site.
+type SiteStatus struct {
+	Queued     int               `json:"queued"`
+	Waiters    int               `json:"waiters"`
+	InFlight   int               `json:"in_flight"`
+	Harvesters []HarvesterStatus `json:"harvesters"`
+}
+
+// HarvesterStatus describes the health and statistics of a harvester. Latencies are in
+// milliseconds.
+type HarvesterStatus struct {
+	Index       int            `json:"index"`
+	Provider    string         `json:"provider"`
+	State       string         `json:"state"`
+	Reason      string         `json:"reason,omitempty"`
+	LastError   string         `json:"last_error,omitempty"`
+	Successes   int            `json:"successes"`
+	Failures    map[string]int `json:"failures"`
+	SuccessRate float64        `json:"success_rate"`
+	P50         int64          `json:"p50_ms"`
+	P90         int64          `json:"p90_ms"`
+	P99         int64          `json:"p99_ms"`
+}
+
+// getStatus serves GET /status, for the requested site or every site.
+func (h *Handler) getStatus(w http.ResponseWriter, r *http.Request) {
+	ids := h.solvers.IDs()
+	if r.URL.Query().Has("site") {
+		site, _, ok := h.solver(w, r)
+		if !ok {
+			return
+		}
+		ids = []string{site}
+	}
+
+	response := StatusResponse{Sites: make(map[string]SiteStatus, len(ids))}
+	for _, id := range ids {
+		if solver, ok := h.solvers.Solver(id); ok { // Sites may be removed meanwhile
+			response.Sites[id] = siteStatus(solver)
+		}
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// siteStatus returns the status of solver.
+func siteStatus(solver captchasolve.CaptchaSolve) SiteStatus {
+	pool := solver.PoolStatus()
+	status := SiteStatus{Queued: pool.Queued, Waiters: pool.Waiters, InFlight: pool.InFlight}
+	stats := solver.Stats()
+	for i, health := range solver.HarvesterStatus() {
+		harvester := HarvesterStatus{
+			Index:    health.Index,
+			Provider: health.Provider,
+			State:    health.State.String(),
+			Failures: make(map[string]int),
+		}
+		if health.State == captchasolve.HarvesterQuarantined {
+			harvester.Reason = health.Reason.String()
+		}
+		if health.LastError != nil {
+			harvester.LastError = health.LastError.Error()
+		}
+		if i < len(stats) { // Harvesters may be swapped by Update meanwhile
+			harvester.Successes = stats[i].Successes
+			for class, n := range stats[i].Failures {
+				harvester.Failures[class.String()] = n
+			}
+			harvester.SuccessRate = stats[i].SuccessRate()
+			harvester.P50 = stats[i].P50.Milliseconds()
+			harvester.P90 = stats[i].P90.Milliseconds()
+			harvester.P99 = stats[i].P99.Milliseconds()
+		}
+		status.Harvesters = append(status.Harvesters, harvester)
+	}
+	return status
+}
+
+// clearTokens serves POST /clear, answering with the status of the site once cleared.
+func (h *Handler) clearTokens(w http.ResponseWriter, r *http.Request) {
+	site, solver, ok := h.solver(w, r)
+	if !ok {
+		return
+	}
+	solver.ClearTokens()
+	captchasolve.WithFields(h.logger, "site", site).Info("Cleared the tokens")
+	writeJSON(w, http.StatusOK, siteStatus(solver))
+}
+
+// ReportRequest is the body of POST /report.
+type ReportRequest struct {
+	Token string `json:"token"`
+}
+
+// ReportResponse is the response of POST /report.
+type ReportResponse struct {
+	Reported bool `json:"reported"`
+}
+
+// reportToken serves POST /report, reporting a token handed out for the site as rejected.
+func (h *Handler) reportToken(w http.ResponseWriter, r *http.Request) {
+	site, solver, ok := h.solver(w, r)
+	if !ok {
+		return
+	}
+	var request ReportRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&request); err != nil || request.Token == "" {
+		writeError(w, http.StatusBadRequest, "invalid_request", `the body must be {"token": "..."}`)
+		return
+	}
+
+	token, ok := h.served.Take(site, request.Token)
+	if !ok {
+		writeError(w, http.StatusNotFound, "unknown_token", "the token wasn't handed out for this site or has expired")
+		return
+	}
+
+	if err := solver.ReportBadToken(token); err != nil {
+		captchasolve.WithFields(h.logger, "site", site, "error", err).Warn("Failed to report a token")
+		status, code := errorStatus(err)
+		writeError(w, status, code, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, ReportResponse{Reported: true})
+}
+
+// solver returns the solver of the site requested by r, writing an error and returning
+// false if there is none.
+func (h *Handler) solver(w http.ResponseWriter, r *http.Request) (string, captchasolve.CaptchaSolve, bool) {
+	site := r.URL.Query().Get("site")
+	if site == "" {
+		writeError(w, http.StatusBadRequest, "missing_site", "the site query parameter is required")
+		return "", nil, false
+	}
+	solver, ok := h.solvers.Solver(site)
+	if !ok {
+		writeError(w, http.StatusNotFound, "unknown_site", fmt.Sprintf("unknown site %q", site))
+		return "", nil, false
+	}
+	return site, solver, true
+}
+
+// errorStatus returns the HTTP status and error code matching an error of a solver.
+func errorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout, "timeout"
+	case errors.Is(err, captchasolve.ErrBudgetExceeded):
+		return http.StatusTooManyRequests, "budget_exceeded"
+	case errors.Is(err, captchasolve.ErrAllHarvestersFailed):
+		return http.StatusBadGateway, "harvesters_failed"
+	case errors.Is(err, captchasolve.ErrPoolClosed),
+		errors.Is(err, captchasolve.ErrNoHarvesters),
+		errors.Is(err, captchasolve.ErrAllHarvestersQuarantined):
+		return http.StatusServiceUnavailable, "unavailable"
+	case errors.Is(err, captchasolve.ErrUnknownSite):
+		return http.StatusNotFound, "unknown_site"
+	default:
+		return http.StatusInternalServerError, "internal"
+	}
+}
+
+// ErrorResponse is the response of failed requests.
+type ErrorResponse struct {
+	Error string `json:"error"`
+	Code  string `json:"code"`
+}
+
+// writeError writes an ErrorResponse with the given status.
+func writeError(w http.ResponseWriter, status int, code, msg string) {
+	writeJSON(w, status, ErrorResponse{Error: msg, Code: code})
+}
+
+// writeJSON writes v as the JSON response, with the given status.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,242 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	captchatoolsgo "github.com/Matthew17-21/Captcha-Tools/captchatools-go"
+	"github.com/Matthew17-21/CaptchaSolve"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeHarvester is a harvester returning numbered tokens after a delay.
+type fakeHarvester struct {
+	calls atomic.Int32
+	delay time.Duration
+}
+
+func (f *fakeHarvester) GetToken(additional ...*captchatoolsgo.AdditionalData) (*captchatoolsgo.CaptchaAnswer, error) {
+	return f.GetTokenWithContext(context.Background(), additional...)
+}
+
+func (f *fakeHarvester) GetTokenWithContext(ctx context.Context, _ ...*captchatoolsgo.AdditionalData) (*captchatoolsgo.CaptchaAnswer, error) {
+	n := f.calls.Add(1)
+	select {
+	case <-time.After(f.delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return &captchatoolsgo.CaptchaAnswer{Token: "token-" + string(rune('0'+n)), UserAgent: "fake-agent"}, nil
+}
+
+func (f *fakeHarvester) GetBalance() (float32, error) { return 1, nil }
+
+// newServer starts a test server serving a solver for the "retailer" site, backed by h,
+// with the given options.
+func newServer(t *testing.T, h captchatoolsgo.Harvester, opts ...Option) (*httptest.Server, captchasolve.CaptchaSolve) {
+	t.Helper()
+	registry := captchasolve.NewRegistry()
+	require.NoError(t, registry.Register("retailer", captchasolve.Site{SiteKey: "key"}, captchasolve.WithHarvester(h)))
+	t.Cleanup(func() { registry.Close() })
+	server := httptest.NewServer(New(registry, opts...))
+	t.Cleanup(server.Close)
+	solver, _ := registry.Solver("retailer")
+	return server, solver
+}
+
+// do sends a request to the server, decoding the JSON response into v.
+func do(t *testing.T, server *httptest.Server, method, path, body string, v any, headers ...string) int {
+	t.Helper()
+	req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+	require.NoError(t, err)
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	resp, err := server.Client().Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(v))
+	return resp.StatusCode
+}
+
+func TestHandler_Token(t *testing.T) {
+	t.Run("serves a token", func(t *testing.T) {
+		// Arrange
+		server, _ := newServer(t, &fakeHarvester{})
+
+		// Act
+		var token TokenResponse
+		status := do(t, server, http.MethodGet, "/token?site=retailer", "", &token)
+
+		// Assert
+		require.Equal(t, http.StatusOK, status)
+		assert.Equal(t, "retailer", token.Site)
+		assert.Equal(t, "token-1", token.Token)
+		assert.Equal(t, "fake-agent", token.UserAgent)
+		assert.Equal(t, "*server.fakeHarvester", token.Provider)
+		assert.WithinDuration(t, time.Now().Add(2*time.Minute), token.ExpiresAt, 5*time.Second)
+	})
+
+	t.Run("times out", func(t *testing.T) {
+		// Arrange
+		server, _ := newServer(t, &fakeHarvester{delay: time.Hour})
+
+		// Act
+		var errResp ErrorResponse
+		start := time.Now()
+		status := do(t, server, http.MethodGet, "/token?site=retailer&timeout=50ms", "", &errResp)
+
+		// Assert
+		require.Equal(t, http.StatusGatewayTimeout, status)
+		assert.Equal(t, "timeout", errResp.Code)
+		assert.Less(t, time.Since(start), time.Second)
+	})
+
+	t.Run("caps the timeout", func(t *testing.T) {
+		// Arrange
+		server, _ := newServer(t, &fakeHarvester{delay: time.Hour}, WithMaxWait(50*time.Millisecond))
+
+		// Act
+		var errResp ErrorResponse
+		status := do(t, server, http.MethodGet, "/token?site=retailer&timeout=1h", "", &errResp)
+
+		// Assert
+		require.Equal(t, http.StatusGatewayTimeout, status)
+	})
+
+	t.Run("reports bad requests", func(t *testing.T) {
+		server, _ := newServer(t, &fakeHarvester{})
+
+		tests := []struct {
+			path   string
+			status int
+			code   string
+		}{
+			{path: "/token", status: http.StatusBadRequest, code: "missing_site"},
+			{path: "/token?site=shop", status: http.StatusNotFound, code: "unknown_site"},
+			{path: "/token?site=retailer&timeout=soon", status: http.StatusBadRequest, code: "invalid_timeout"},
+		}
+		for _, tt := range tests {
+			var errResp ErrorResponse
+			status := do(t, server, http.MethodGet, tt.path, "", &errResp)
+			require.Equal(t, tt.status, status, tt.path)
+			require.Equal(t, tt.code, errResp.Code, tt.path)
+		}
+	})
+}
+
+func TestHandler_APIKeys(t *testing.T) {
+	server, _ := newServer(t, &fakeHarvester{}, WithAPIKeys("secret", ""))
+
+	tests := []struct {
+		name    string
+		headers []string
+		status  int
+	}{
+		{name: "no key", status: http.StatusUnauthorized},
+		{name: "wrong key", headers: []string{"X-API-Key", "guess"}, status: http.StatusUnauthorized},
+		{name: "empty bearer", headers: []string{"Authorization", "Bearer "}, status: http.StatusUnauthorized},
+		{name: "X-API-Key", headers: []string{"X-API-Key", "secret"}, status: http.StatusOK},
+		{name: "bearer token", headers: []string{"Authorization", "Bearer secret"}, status: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var response map[string]any
+			status := do(t, server, http.MethodGet, "/status", "", &response, tt.headers...)
+			require.Equal(t, tt.status, status)
+		})
+	}
+}
+
+func TestHandler_Status(t *testing.T) {
+	// Arrange
+	server, _ := newServer(t, &fakeHarvester{})
+	var token TokenResponse
+	require.Equal(t, http.StatusOK, do(t, server, http.MethodGet, "/token?site=retailer", "", &token))
+
+	// Act
+	var response StatusResponse
+	status := do(t, server, http.MethodGet, "/status?site=retailer", "", &response)
+
+	// Assert
+	require.Equal(t, http.StatusOK, status)
+	require.Contains(t, response.Sites, "retailer")
+	site := response.Sites["retailer"]
+	assert.Zero(t, site.Waiters)
+	require.Len(t, site.Harvesters, 1)
+	assert.Equal(t, "active", site.Harvesters[0].State)
+	assert.Equal(t, 1, site.Harvesters[0].Successes)
+	assert.Equal(t, 1.0, site.Harvesters[0].SuccessRate)
+
+	var errResp ErrorResponse
+	require.Equal(t, http.StatusNotFound, do(t, server, http.MethodGet, "/status?site=shop", "", &errResp))
+}
+
+func TestHandler_Clear(t *testing.T) {
+	// Arrange
+	server, solver := newServer(t, &fakeHarvester{})
+	require.NoError(t, solver.Update(captchasolve.WithHarvester(&fakeHarvester{}), captchasolve.WithPrefill(2, 2)))
+	require.NoError(t, solver.Start(context.Background()))
+	require.Eventually(t, func() bool { return solver.PoolStatus().Queued == 2 }, time.Second, time.Millisecond)
+	require.NoError(t, solver.Update(captchasolve.WithHarvester(&fakeHarvester{})))
+
+	// Act
+	var site SiteStatus
+	status := do(t, server, http.MethodPost, "/clear?site=retailer", "", &site)
+
+	// Assert
+	require.Equal(t, http.StatusOK, status)
+	assert.Zero(t, site.Queued)
+	assert.Zero(t, solver.PoolStatus().Queued)
+}
+
+func TestHandler_Report(t *testing.T) {
+	t.Run("reports handed out tokens once", func(t *testing.T) {
+		// Arrange
+		server, solver := newServer(t, &fakeHarvester{})
+		var token TokenResponse
+		require.Equal(t, http.StatusOK, do(t, server, http.MethodGet, "/token?site=retailer", "", &token))
+
+		// Act
+		var response ReportResponse
+		status := do(t, server, http.MethodPost, "/report?site=retailer", `{"token": "`+token.Token+`"}`, &response)
+
+		// Assert
+		require.Equal(t, http.StatusOK, status)
+		assert.True(t, response.Reported)
+		assert.Equal(t, map[captchasolve.ErrorClass]int{captchasolve.ErrorClassRejected: 1}, solver.Stats()[0].Failures)
+
+		var errResp ErrorResponse
+		status = do(t, server, http.MethodPost, "/report?site=retailer", `{"token": "`+token.Token+`"}`, &errResp)
+		require.Equal(t, http.StatusNotFound, status)
+		require.Equal(t, "unknown_token", errResp.Code)
+	})
+
+	t.Run("rejects unknown tokens", func(t *testing.T) {
+		server, _ := newServer(t, &fakeHarvester{})
+
+		var errResp ErrorResponse
+		require.Equal(t, http.StatusNotFound, do(t, server, http.MethodPost, "/report?site=retailer", `{"token": "made-up"}`, &errResp))
+		require.Equal(t, http.StatusBadRequest, do(t, server, http.MethodPost, "/report?site=retailer", `token`, &errResp))
+		require.Equal(t, "invalid_request", errResp.Code)
+	})
+}
+
+func TestSites(t *testing.T) {
+	solver := captchasolve.New()
+	sites := Sites{"shop": solver, "retailer": solver}
+
+	got, ok := sites.Solver("shop")
+
+	require.True(t, ok)
+	require.Same(t, solver, got)
+	require.Equal(t, []string{"retailer", "shop"}, sites.IDs())
+}
@@ -0,0 +1,97 @@
+package captchasolve
+
+import (
+	"maps"
+	"slices"
+	"time"
+)
+
+// PoolStatus describes the tokens and solves of a solver at a point in time.
+type PoolStatus struct {
+	Queued   int // Number of queued tokens, whatever their fingerprint
+	Waiters  int // Number of callers waiting for a token
+	InFlight int // Number of solves started but not finished yet
+}
+
+// HarvesterStats holds the statistics of one of the configured harvesters. Counts cover the
+// lifetime of the solver, latencies cover its last solves.
+type HarvesterStats struct {
+	Index       int                // Position of the harvester in the order it was configured
+	Provider    string             // Type of the harvester
+	Successes   int                // Number of successful calls
+	Failures    map[ErrorClass]int // Number of failed calls, by class of error
+	P50         time.Duration      // Median latency of the last successful calls
+	P90         time.Duration      // 90th percentile latency of the last successful calls
+	P99         time.Duration      // 99th percentile latency of the last successful calls
+	LastSuccess time.Time          // When the harvester last returned a token
+}
+
+// TotalFailures returns the number of failed calls, whatever their class.
+func (s HarvesterStats) TotalFailures() int {
+	total := 0
+	for _, n := range s.Failures {
+		total += n
+	}
+	return total
+}
+
+// SuccessRate returns the share of calls that succeeded, between 0 and 1. It is 0 until a
+// call has been made.
+func (s HarvesterStats) SuccessRate() float64 {
+	calls := s.Successes + s.TotalFailures()
+	if calls == 0 {
+		return 0
+	}
+	return float64(s.Successes) / float64(calls)
+}
+
+// Stats returns the statistics of every configured harvester, in the order they were
+// configured.
+func (c *captchasolve) Stats() []HarvesterStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := make([]HarvesterStats, len(c.harvesters))
+	for i := range c.harvesters {
+		stats[i] = c.statsLocked(i)
+		failures := make(map[ErrorClass]int, len(stats[i].Failures))
+		maps.Copy(failures, stats[i].Failures)
+		stats[i].Failures = failures
+	}
+	return stats
+}
+
+// PoolStatus returns the number of queued tokens, waiting callers and in-flight solves.
+func (c *captchasolve) PoolStatus() PoolStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return PoolStatus{Queued: c.queuedLocked(), Waiters: len(c.waiters), InFlight: c.inFlight}
+}
+
+// statsLocked returns the statistics of the harvester at index i as of its last recorded
+// call. The Failures map is shared with the snapshot, so it must be copied before being
+// handed out. c.mu must be held.
+func (c *captchasolve) statsLocked(i int) HarvesterStats {
+	var stats HarvesterStats
+	if h, ok := c.health[i]; ok {
+		stats = h.stats
+	}
+	stats.Index = i
+	if i < len(c.harvesters) {
+		stats.Provider = providerName(c.harvesters[i])
+	}
+	return stats
+}
+
+// refreshStats rebuilds the snapshot of the statistics of the harvester.
+func (h *harvesterHealth) refreshStats() {
+	latencies := slices.Clone(h.latencies)
+	slices.Sort(latencies)
+	h.stats = HarvesterStats{
+		Successes:   h.successes,
+		Failures:    maps.Clone(h.failuresByClass),
+		P50:         sortedPercentile(latencies, 0.5),
+		P90:         sortedPercentile(latencies, 0.9),
+		P99:         sortedPercentile(latencies, 0.99),
+		LastSuccess: h.lastSuccess,
+	}
+}
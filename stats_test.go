@@ -0,0 +1,146 @@
+package captchasolve
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStats(t *testing.T) {
+	t.Run("reports every harvester", func(t *testing.T) {
+		// Arrange
+		c := New(WithHarvester(&fakeHarvester{}), WithHarvester(&fakeHarvester{})).(*captchasolve)
+
+		// Act
+		stats := c.Stats()
+
+		// Assert
+		require.Len(t, stats, 2)
+		assert.Equal(t, 1, stats[1].Index)
+		assert.Equal(t, "*captchasolve.fakeHarvester", stats[1].Provider)
+		assert.Zero(t, stats[1].Successes)
+		assert.Zero(t, stats[1].SuccessRate())
+	})
+
+	t.Run("counts successes and failures by class", func(t *testing.T) {
+		// Arrange
+		c := New(WithHarvester(&fakeHarvester{})).(*captchasolve)
+		before := time.Now()
+
+		// Act
+		c.recordCall(0, c.harvesters[0], time.Second, nil)
+		c.recordCall(0, c.harvesters[0], 0, errors.New("ERROR_CAPTCHA_UNSOLVABLE"))
+		c.recordCall(0, c.harvesters[0], 0, errors.New("ERROR_CAPTCHA_UNSOLVABLE"))
+		c.recordCall(0, c.harvesters[0], 0, errors.New("502 bad gateway"))
+
+		// Assert
+		stats := c.Stats()[0]
+		assert.Equal(t, 1, stats.Successes)
+		assert.Equal(t, map[ErrorClass]int{ErrorClassUnsolvable: 2, ErrorClassUnknown: 1}, stats.Failures)
+		assert.Equal(t, 3, stats.TotalFailures())
+		assert.Equal(t, 0.25, stats.SuccessRate())
+		assert.False(t, stats.LastSuccess.Before(before))
+	})
+
+	t.Run("reports latency percentiles", func(t *testing.T) {
+		// Arrange
+		c := New(WithHarvester(&fakeHarvester{})).(*captchasolve)
+
+		// Act
+		for i := 1; i <= 100; i++ {
+			c.recordCall(0, c.harvesters[0], time.Duration(i)*time.Second, nil)
+		}
+
+		// Assert
+		stats := c.Stats()[0]
+		assert.InDelta(t, 50*time.Second, stats.P50, float64(time.Second))
+		assert.InDelta(t, 90*time.Second, stats.P90, float64(time.Second))
+		assert.InDelta(t, 99*time.Second, stats.P99, float64(time.Second))
+	})
+
+	t.Run("survives a harvester being restored", func(t *testing.T) {
+		// Arrange
+		c := New(WithHarvester(&balanceHarvester{balance: 10})).(*captchasolve)
+		for i := 0; i < 5; i++ {
+			c.recordCall(0, c.harvesters[0], time.Second, nil)
+		}
+		c.recordCall(0, c.harvesters[0], 0, errors.New("ERROR_ZERO_BALANCE"))
+
+		// Act
+		c.recheckQuarantined(time.Now().Add(defaultQuarantineRecheck))
+
+		// Assert
+		require.Equal(t, HarvesterActive, c.HarvesterStatus()[0].State)
+		stats := c.Stats()[0]
+		assert.Equal(t, 5, stats.Successes)
+		assert.Equal(t, map[ErrorClass]int{ErrorClassNoBalance: 1}, stats.Failures)
+		assert.Equal(t, time.Second, stats.P50)
+	})
+
+	t.Run("returns a copy", func(t *testing.T) {
+		// Arrange
+		c := New(WithHarvester(&fakeHarvester{})).(*captchasolve)
+		c.recordCall(0, c.harvesters[0], 0, errors.New("502 bad gateway"))
+
+		// Act
+		c.Stats()[0].Failures[ErrorClassUnknown] = 10
+
+		// Assert
+		assert.Equal(t, 1, c.Stats()[0].Failures[ErrorClassUnknown])
+	})
+
+	t.Run("records solves", func(t *testing.T) {
+		// Arrange
+		c := New(WithHarvester(&fakeHarvester{delay: 10 * time.Millisecond})).(*captchasolve)
+		defer c.Close()
+
+		// Act
+		_, err := c.GetToken(context.Background())
+
+		// Assert
+		require.NoError(t, err)
+		stats := c.Stats()[0]
+		assert.Equal(t, 1, stats.Successes)
+		assert.GreaterOrEqual(t, stats.P50, 10*time.Millisecond)
+	})
+
+	t.Run("is available to strategies", func(t *testing.T) {
+		// Arrange
+		h1, h2 := &fakeHarvester{}, &fakeHarvester{}
+		c := New(WithHarvester(h1), WithHarvester(h2), WithStrategy(mostReliable{})).(*captchasolve)
+		c.recordCall(0, c.harvesters[0], 0, errors.New("502 bad gateway"))
+		c.recordCall(1, c.harvesters[1], time.Second, nil)
+
+		// Act
+		_, h, ok := c.nextHarvester()
+
+		// Assert
+		require.True(t, ok)
+		assert.Same(t, h2, h)
+	})
+}
+
+func TestPoolStatus(t *testing.T) {
+	// Arrange
+	c := New(WithHarvester(&fakeHarvester{})).(*captchasolve)
+	enqueueTokens(c, 2, 0)
+	c.addWaiter(newWaiter(""))
+	c.inFlight = 3
+
+	// Act
+	status := c.PoolStatus()
+
+	// Assert
+	assert.Equal(t, PoolStatus{Queued: 2, Waiters: 1, InFlight: 3}, status)
+}
+
+// mostReliable is a Strategy picking the harvester with the highest success rate.
+type mostReliable struct{}
+
+func (mostReliable) Pick(candidates []Candidate) int {
+	return lowestBy(candidates, func(c Candidate) float64 { return -c.Stats.SuccessRate() })
+}
@@ -0,0 +1,166 @@
+package captchasolve
+
+import (
+	"math"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	captchatoolsgo "github.com/Matthew17-21/Captcha-Tools/captchatools-go"
+)
+
+// Strategy picks the harvester used for each solve.
+//
+// Pick is called with the harvesters that aren't quarantined, in the order they were
+// configured, and returns the position in candidates of the one to use. It is called
+// while the solver holds its lock, so it must be fast and must not call back into the
+// solver. Strategies shared by several solvers must be safe for concurrent use, as the
+// built-in ones are.
+type Strategy interface {
+	Pick(candidates []Candidate) int
+}
+
+// Candidate describes a harvester a Strategy can pick. The Failures map of its Stats is
+// shared between picks and must not be modified.
+type Candidate struct {
+	Index     int                      // Position of the harvester in the order it was configured
+	Harvester captchatoolsgo.Harvester // The harvester itself
+	Priority  int                      // Priority set with WithHarvesterPriority, lower first
+	Weight    int                      // Weight set with WithHarvesterWeight
+	Cost      float64                  // Cost per solve set with WithHarvesterCost
+	Latency   time.Duration            // Average latency of its successful solves, 0 until one succeeds
+	Failures  int                      // Number of consecutive failed calls
+	Stats     HarvesterStats           // Statistics of the harvester, as returned by Stats
+}
+
+// RoundRobin returns a Strategy spreading consecutive solves across every harvester in
+// the order they were configured. It is the default.
+func RoundRobin() Strategy {
+	return &roundRobin{last: -1}
+}
+
+type roundRobin struct {
+	mu   sync.Mutex
+	last int // Index of the last picked harvester
+}
+
+func (r *roundRobin) Pick(candidates []Candidate) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Pick the first harvester after the last one, wrapping around
+	pick := 0
+	for i, cand := range candidates {
+		if cand.Index > r.last {
+			pick = i
+			break
+		}
+	}
+	r.last = candidates[pick].Index
+	return pick
+}
+
+// PriorityFailover returns a Strategy always picking the harvester with the lowest
+// priority, failing over to the next one once it has failed maxFailures times in a row.
+// Harvesters with the same priority are picked in the order they were configured.
+//
+// A harvester that failed over is used again once every harvester before it has failed
+// too, or once it's the only one left.
+func PriorityFailover(maxFailures int) Strategy {
+	// Make sure it is a valid amount
+	if maxFailures < 1 {
+		maxFailures = 1
+	}
+	return priorityFailover{maxFailures: maxFailures}
+}
+
+type priorityFailover struct {
+	maxFailures int
+}
+
+func (p priorityFailover) Pick(candidates []Candidate) int {
+	pick := -1
+	for i, cand := range candidates {
+		if cand.Failures >= p.maxFailures {
+			continue
+		}
+		if pick < 0 || cand.Priority < candidates[pick].Priority {
+			pick = i
+		}
+	}
+	if pick >= 0 {
+		return pick
+	}
+
+	// Every harvester failed over, retry the one that failed the least
+	return lowestBy(candidates, func(c Candidate) float64 { return float64(c.Failures) })
+}
+
+// WeightedRandom returns a Strategy picking harvesters at random, in proportion to their
+// weight. Harvesters without a weight count as having a weight of 1.
+func WeightedRandom() Strategy {
+	return weightedRandom{}
+}
+
+type weightedRandom struct{}
+
+func (weightedRandom) Pick(candidates []Candidate) int {
+	total := 0
+	for _, cand := range candidates {
+		total += max(cand.Weight, 1)
+	}
+	n := rand.IntN(total)
+	for i, cand := range candidates {
+		n -= max(cand.Weight, 1)
+		if n < 0 {
+			return i
+		}
+	}
+	return len(candidates) - 1
+}
+
+// LowestCost returns a Strategy picking the harvester with the lowest cost per solve.
+// Harvesters with the same cost are picked in the order they were configured.
+func LowestCost() Strategy {
+	return lowestCost{}
+}
+
+type lowestCost struct{}
+
+func (lowestCost) Pick(candidates []Candidate) int {
+	return lowestBy(candidates, func(c Candidate) float64 { return c.Cost })
+}
+
+// LowestLatency returns a Strategy picking the harvester with the lowest average solve
+// latency, divided by its success rate so that harvesters failing often lose their place.
+// Harvesters that haven't been called yet are picked first, so the latency of every
+// harvester gets measured, while those whose calls all failed are only picked once every
+// other harvester failed too.
+func LowestLatency() Strategy {
+	return lowestLatency{}
+}
+
+type lowestLatency struct{}
+
+func (lowestLatency) Pick(candidates []Candidate) int {
+	return lowestBy(candidates, func(c Candidate) float64 {
+		switch {
+		case c.Stats.Successes+c.Stats.TotalFailures() == 0:
+			return -1
+		case c.Stats.Successes == 0:
+			return math.Inf(1)
+		}
+		return float64(c.Latency) / c.Stats.SuccessRate()
+	})
+}
+
+// lowestBy returns the position of the first candidate with the lowest key.
+func lowestBy(candidates []Candidate, key func(Candidate) float64) int {
+	pick := 0
+	for i := 1; i < len(candidates); i++ {
+		if key(candidates[i]) < key(candidates[pick]) {
+			pick = i
+		}
+	}
+	return pick
+}
@@ -0,0 +1,179 @@
+package captchasolve
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	captchatoolsgo "github.com/Matthew17-21/Captcha-Tools/captchatools-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pickHarvesters returns the harvesters the solver picks for its next n solves.
+func pickHarvesters(t *testing.T, c *captchasolve, n int) []captchatoolsgo.Harvester {
+	t.Helper()
+	picked := make([]captchatoolsgo.Harvester, n)
+	for i := range picked {
+		_, h, ok := c.nextHarvester()
+		require.True(t, ok)
+		picked[i] = h
+	}
+	return picked
+}
+
+func TestRoundRobin(t *testing.T) {
+	h1, h2, h3 := &fakeHarvester{}, &fakeHarvester{}, &fakeHarvester{}
+	c := New(WithHarvester(h1), WithHarvester(h2), WithHarvester(h3), WithStrategy(RoundRobin())).(*captchasolve)
+
+	picked := pickHarvesters(t, c, 4)
+
+	assert.Equal(t, []captchatoolsgo.Harvester{h1, h2, h3, h1}, picked)
+}
+
+func TestPriorityFailover(t *testing.T) {
+	primary, backup, last := &fakeHarvester{}, &fakeHarvester{}, &fakeHarvester{}
+	c := New(
+		WithHarvester(last, WithHarvesterPriority(2)),
+		WithHarvester(primary, WithHarvesterPriority(0)),
+		WithHarvester(backup, WithHarvesterPriority(1)),
+		WithStrategy(PriorityFailover(2)),
+	).(*captchasolve)
+	transientErr := errors.New("502 bad gateway")
+
+	t.Run("uses the lowest priority", func(t *testing.T) {
+		assert.Equal(t, []captchatoolsgo.Harvester{primary, primary}, pickHarvesters(t, c, 2))
+	})
+
+	t.Run("fails over after consecutive failures", func(t *testing.T) {
+		c.recordCall(1, c.harvesters[1], 0, transientErr)
+		assert.Same(t, primary, pickHarvesters(t, c, 1)[0], "a single failure shouldn't fail over")
+
+		c.recordCall(1, c.harvesters[1], 0, transientErr)
+		assert.Same(t, backup, pickHarvesters(t, c, 1)[0])
+	})
+
+	t.Run("uses the least failing harvester once all failed over", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			c.recordCall(0, c.harvesters[0], 0, transientErr)
+			c.recordCall(2, c.harvesters[2], 0, transientErr)
+		}
+		assert.Same(t, primary, pickHarvesters(t, c, 1)[0])
+	})
+
+	t.Run("fails back once the primary succeeds", func(t *testing.T) {
+		c.recordCall(1, c.harvesters[1], time.Second, nil)
+		assert.Same(t, primary, pickHarvesters(t, c, 1)[0])
+	})
+}
+
+func TestWeightedRandom(t *testing.T) {
+	heavy, light, unweighted := &fakeHarvester{}, &fakeHarvester{}, &fakeHarvester{}
+	c := New(
+		WithHarvester(heavy, WithHarvesterWeight(8)),
+		WithHarvester(light, WithHarvesterWeight(1)),
+		WithHarvester(unweighted),
+		WithStrategy(WeightedRandom()),
+	).(*captchasolve)
+
+	counts := make(map[captchatoolsgo.Harvester]int)
+	for _, h := range pickHarvesters(t, c, 1000) {
+		counts[h]++
+	}
+
+	assert.InDelta(t, 800, counts[heavy], 100)
+	assert.InDelta(t, 100, counts[light], 60)
+	assert.InDelta(t, 100, counts[unweighted], 60, "harvesters without a weight should count as 1")
+}
+
+func TestLowestCost(t *testing.T) {
+	expensive, cheap, alsoCheap := &fakeHarvester{}, &fakeHarvester{}, &fakeHarvester{}
+	c := New(
+		WithHarvester(expensive, WithHarvesterCost(0.003)),
+		WithHarvester(cheap, WithHarvesterCost(0.001)),
+		WithHarvester(alsoCheap, WithHarvesterCost(0.001)),
+		WithStrategy(LowestCost()),
+	).(*captchasolve)
+
+	assert.Equal(t, []captchatoolsgo.Harvester{cheap, cheap}, pickHarvesters(t, c, 2))
+
+	c.recordCall(1, c.harvesters[1], 0, errors.New("ERROR_ZERO_BALANCE"))
+	assert.Same(t, alsoCheap, pickHarvesters(t, c, 1)[0], "quarantined harvesters should be skipped")
+}
+
+func TestLowestLatency(t *testing.T) {
+	slow, fast, unmeasured := &fakeHarvester{}, &fakeHarvester{}, &fakeHarvester{}
+	c := New(
+		WithHarvester(slow),
+		WithHarvester(fast),
+		WithHarvester(unmeasured),
+		WithStrategy(LowestLatency()),
+	).(*captchasolve)
+	c.recordCall(0, c.harvesters[0], 20*time.Second, nil)
+	c.recordCall(1, c.harvesters[1], 5*time.Second, nil)
+
+	assert.Same(t, unmeasured, pickHarvesters(t, c, 1)[0], "unmeasured harvesters should be tried first")
+
+	c.recordCall(2, c.harvesters[2], 10*time.Second, nil)
+	assert.Same(t, fast, pickHarvesters(t, c, 1)[0])
+
+	// The average follows the harvester getting slower
+	for i := 0; i < 10; i++ {
+		c.recordCall(1, c.harvesters[1], 30*time.Second, nil)
+	}
+	assert.Same(t, unmeasured, pickHarvesters(t, c, 1)[0])
+}
+
+func TestLowestLatency_Failing(t *testing.T) {
+	// Arrange
+	failing := &fakeHarvester{err: errors.New("ERROR_CAPTCHA_UNSOLVABLE")}
+	healthy := &fakeHarvester{}
+	c := New(WithHarvester(failing), WithHarvester(healthy), WithStrategy(LowestLatency()), WithSurplus(0)).(*captchasolve)
+	defer c.Close()
+	c.recordCall(1, c.harvesters[1], time.Second, nil)
+
+	// Act
+	failed := 0
+	for i := 0; i < 10; i++ {
+		if _, err := c.GetToken(context.Background()); err != nil {
+			failed++
+		}
+	}
+
+	// Assert
+	assert.LessOrEqual(t, failed, 1)
+	assert.EqualValues(t, 1, failing.calls.Load(), "the failing harvester should only be tried once")
+	assert.EqualValues(t, 10-failed, healthy.calls.Load())
+}
+
+func TestNextHarvester_Allocations(t *testing.T) {
+	// Arrange
+	c := New(WithHarvester(&fakeHarvester{}), WithHarvester(&fakeHarvester{}), WithHarvester(&fakeHarvester{})).(*captchasolve)
+	for i := 0; i < 3; i++ {
+		c.recordCall(i, c.harvesters[i], time.Second, nil)
+		c.recordCall(i, c.harvesters[i], 0, errors.New("502 bad gateway"))
+	}
+
+	// Act
+	allocs := testing.AllocsPerRun(100, func() { c.nextHarvester() })
+
+	// Assert
+	assert.LessOrEqual(t, allocs, 6.0, "the statistics should be computed when recorded, not on every pick")
+}
+
+func TestNextHarvester_InvalidPick(t *testing.T) {
+	h := &fakeHarvester{}
+	c := New(WithHarvester(h), WithStrategy(badStrategy{})).(*captchasolve)
+
+	index, picked, ok := c.nextHarvester()
+
+	require.True(t, ok)
+	assert.Equal(t, 0, index)
+	assert.Same(t, h, picked)
+}
+
+// badStrategy picks a candidate that doesn't exist.
+type badStrategy struct{}
+
+func (badStrategy) Pick(candidates []Candidate) int { return len(candidates) }
@@ -0,0 +1,63 @@
+package captchasolve
+
+import (
+	"context"
+	"time"
+)
+
+// defaultSweepInterval is how often expired tokens are evicted from the queue by default.
+const defaultSweepInterval = 30 * time.Second
+
+// sweeper periodically evicts expired tokens from the queue until ctx is cancelled.
+func (c *captchasolve) sweeper(ctx context.Context) {
+	c.logger.Info("Starting expiry sweeper, running every %v", c.sweepInterval)
+	ticker := time.NewTicker(c.sweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			c.logger.Info("Stopping expiry sweeper")
+			return
+		case <-ticker.C:
+			c.sweep(ctx)
+		}
+	}
+}
+
+// sweep evicts every expired token from the queue in one pass and, if configured,
+// replaces those solved without AdditionalData. Tokens solved with AdditionalData aren't
+// replaced, as the AdditionalData isn't kept: a token solved without it couldn't be handed
+// to the callers they were for. It returns the number of tokens evicted.
+func (c *captchasolve) sweep(ctx context.Context) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	queued := c.queue.Len()
+	n := c.evictExpiredLocked()
+	unkeyed := queued - c.queue.Len()
+	if unkeyed == 0 || !c.sweepRefill {
+		return n
+	}
+
+	// Replace the evicted tokens
+	if c.prefillMax > 0 {
+		c.signalRefill()
+	} else {
+		c.startSolvesLocked(ctx, unkeyed)
+	}
+	return n
+}
+
+// evictExpiredLocked removes expired tokens, and those about to expire, from the queue
+// and reports them as wasted. It returns the number of tokens evicted. c.mu must be held.
+func (c *captchasolve) evictExpiredLocked() int {
+	n := 0
+	c.forEachQueueLocked(func(q tokenQueue) {
+		n += q.RemoveFunc(func(tkn *CaptchaAnswer) bool { return !c.usable(tkn) })
+	})
+	if n > 0 {
+		WithFields(c.logger, "count", n).Warn("Evicted expired tokens that were never used")
+		c.reportExpired(n)
+		c.reportPoolLocked()
+	}
+	return n
+}
@@ -0,0 +1,110 @@
+package captchasolve
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	captchatoolsgo "github.com/Matthew17-21/Captcha-Tools/captchatools-go"
+	"github.com/stretchr/testify/require"
+)
+
+// enqueueTokens adds numValid fresh tokens and numExpired expired tokens to the queue,
+// interleaved.
+func enqueueTokens(c *captchasolve, numValid, numExpired int) {
+	for i := 0; i < max(numValid, numExpired); i++ {
+		if i < numExpired {
+			c.queue.Enqueue(&CaptchaAnswer{solvedAt: time.Now().Add(-time.Hour)})
+		}
+		if i < numValid {
+			c.queue.Enqueue(&CaptchaAnswer{CaptchaAnswer: captchatoolsgo.CaptchaAnswer{Token: "valid"}, solvedAt: time.Now()})
+		}
+	}
+}
+
+func TestEvictExpiredLocked(t *testing.T) {
+	// Arrange
+	c := New().(*captchasolve)
+	enqueueTokens(c, 2, 3)
+
+	// Act
+	n := c.evictExpiredLocked()
+
+	// Assert
+	require.Equal(t, 3, n)
+	require.Equal(t, 2, c.queue.Len())
+	for c.queue.Len() > 0 {
+		tkn, _ := c.queue.Dequeue()
+		require.Equal(t, "valid", tkn.Token)
+	}
+}
+
+func TestSweep(t *testing.T) {
+	t.Run("evicts without refilling", func(t *testing.T) {
+		// Arrange
+		h := &fakeHarvester{}
+		c := New(WithHarvester(h)).(*captchasolve)
+		defer c.Close()
+		enqueueTokens(c, 1, 2)
+
+		// Act
+		n := c.sweep(context.Background())
+
+		// Assert
+		require.Equal(t, 2, n)
+		require.Equal(t, 1, c.queue.Len())
+		require.Zero(t, h.calls.Load())
+	})
+
+	t.Run("replaces evicted tokens", func(t *testing.T) {
+		// Arrange
+		h := &fakeHarvester{}
+		c := New(WithHarvester(h), WithSweeper(time.Minute, true)).(*captchasolve)
+		defer c.Close()
+		enqueueTokens(c, 1, 2)
+
+		// Act
+		n := c.sweep(context.Background())
+
+		// Assert
+		require.Equal(t, 2, n)
+		require.Eventually(t, func() bool { return c.queue.Len() == 3 }, time.Second, time.Millisecond)
+		require.EqualValues(t, 2, h.calls.Load())
+	})
+
+	t.Run("doesn't replace tokens solved with additional data", func(t *testing.T) {
+		// Arrange
+		h := &fakeHarvester{}
+		c := New(WithHarvester(h), WithSweeper(time.Minute, true)).(*captchasolve)
+		defer c.Close()
+		c.mu.Lock()
+		expired := &CaptchaAnswer{solvedAt: time.Now().Add(-time.Hour), fingerprint: Fingerprint(&captchatoolsgo.AdditionalData{UserAgent: "ua"})}
+		require.NoError(t, c.enqueueLocked(expired))
+		c.mu.Unlock()
+
+		// Act
+		n := c.sweep(context.Background())
+
+		// Assert
+		require.Equal(t, 1, n)
+		require.NoError(t, c.Close()) // Waits for any solve started
+		require.Zero(t, h.calls.Load())
+	})
+}
+
+func TestSweeper(t *testing.T) {
+	// Arrange
+	c := New(WithSweeper(10*time.Millisecond, false)).(*captchasolve)
+	defer c.Close()
+	enqueueTokens(c, 1, 5)
+
+	// Act
+	require.NoError(t, c.Start(context.Background()))
+
+	// Assert
+	require.Eventually(t, func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.queue.Len() == 1
+	}, time.Second, time.Millisecond)
+}
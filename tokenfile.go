@@ -0,0 +1,406 @@
+package captchasolve
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	captchatoolsgo "github.com/Matthew17-21/Captcha-Tools/captchatools-go"
+	"github.com/Matthew17-21/CaptchaSolve/internal/queue"
+)
+
+// compactAfter is the number of records a token file may hold before it is compacted,
+// once more than half of them are stale.
+const compactAfter = 256
+
+// maxRecordSize bounds the size of a line of a token file.
+const maxRecordSize = 1 << 20
+
+// storedToken is a queued token as kept in a token file.
+type storedToken struct {
+	Token       string    `json:"token"`
+	UserAgent   string    `json:"user_agent,omitempty"`
+	SolvedAt    time.Time `json:"solved_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	Fingerprint string    `json:"fingerprint,omitempty"`
+	Provider    string    `json:"provider,omitempty"` // Type of the harvester that solved the token
+	Harvester   int       `json:"harvester"`          // Index of the harvester that solved the token, -1 if unknown
+	Seq         uint64    `json:"seq"`                // Number of the token in the order tokens were queued
+}
+
+// tokenRecord is a line of a token file: a token added to a queue, or the number of the
+// token removed from one.
+type tokenRecord struct {
+	Add    *storedToken `json:"add,omitempty"`
+	Remove uint64       `json:"remove,omitempty"`
+}
+
+// tokenLog is the append-only log of the changes made to the queues of an instance, from
+// which the tokens still queued are reloaded by the next instance using the same file. It
+// is compacted when opened, once it holds too many stale records, and when closed.
+type tokenLog struct {
+	path   string
+	logger Logger
+
+	// harvesterIndex returns the index of the harvester that solved a token. It is called
+	// with the mutex of the instance held, like every method of the queues.
+	harvesterIndex func(captchatoolsgo.Harvester) int
+
+	mu      sync.Mutex
+	file    *os.File                  // Nil once closed
+	live    map[uint64]*storedToken   // Queued tokens, by number
+	seqs    map[*CaptchaAnswer]uint64 // Number of the queued tokens
+	seq     uint64                    // Number of the last token added
+	records int                       // Number of records in the file
+}
+
+// openTokenLog opens the token log at path, creating it if needed, and returns it with
+// the tokens it holds that haven't expired, oldest first. A record cut short, as a crash
+// may leave behind, ends the log.
+func openTokenLog(path string, logger Logger) (*tokenLog, []storedToken, error) {
+	l := &tokenLog{
+		path:   path,
+		logger: logger,
+		live:   make(map[uint64]*storedToken),
+		seqs:   make(map[*CaptchaAnswer]uint64),
+	}
+	if err := l.read(); err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	var tokens []storedToken
+	for seq, st := range l.live {
+		if !st.ExpiresAt.After(now) {
+			delete(l.live, seq)
+			continue
+		}
+		tokens = append(tokens, *st)
+	}
+	slices.SortFunc(tokens, func(a, b storedToken) int { return cmp.Compare(a.Seq, b.Seq) })
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, nil, fmt.Errorf("captchasolve: opening token file: %w", err)
+	}
+	l.file = file
+	return l, tokens, nil
+}
+
+// read replays the records of the file, if it exists.
+func (l *tokenLog) read() error {
+	file, err := os.Open(l.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("captchasolve: reading token file: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, maxRecordSize)
+	for scanner.Scan() {
+		var record tokenRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			WithFields(l.logger, "path", l.path, "line", l.records+1, "error", err).Warn("Ignoring the end of the token file, which is corrupt")
+			return nil
+		}
+		l.records++
+		if record.Add != nil {
+			l.live[record.Add.Seq] = record.Add
+			l.seq = max(l.seq, record.Add.Seq)
+		} else {
+			delete(l.live, record.Remove)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		WithFields(l.logger, "path", l.path, "error", err).Warn("Ignoring the end of the token file, which can't be read")
+	}
+	return nil
+}
+
+// bind ties token to the token numbered seq read from the file.
+func (l *tokenLog) bind(token *CaptchaAnswer, seq uint64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.seqs[token] = seq
+}
+
+// add records token being queued. Tokens already recorded are ignored.
+func (l *tokenLog) add(token *CaptchaAnswer) {
+	index := -1
+	if token.harvester != nil && l.harvesterIndex != nil {
+		index = l.harvesterIndex(token.harvester)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.seqs[token]; ok {
+		return
+	}
+	l.seq++
+	st := &storedToken{
+		Token:       token.Token,
+		UserAgent:   token.UserAgent,
+		SolvedAt:    token.solvedAt,
+		ExpiresAt:   token.ExpiresAt(),
+		Fingerprint: token.fingerprint,
+		Provider:    token.Provider(),
+		Harvester:   index,
+		Seq:         l.seq,
+	}
+	l.live[st.Seq] = st
+	l.seqs[token] = st.Seq
+	l.writeLocked(tokenRecord{Add: st})
+}
+
+// remove records token being removed from its queue.
+func (l *tokenLog) remove(token *CaptchaAnswer) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	seq, ok := l.seqs[token]
+	if !ok {
+		return
+	}
+	delete(l.seqs, token)
+	delete(l.live, seq)
+	l.writeLocked(tokenRecord{Remove: seq})
+
+	if l.records > compactAfter && l.records > 2*len(l.live) {
+		l.compactLocked()
+	}
+}
+
+// writeLocked appends record to the file. Failures are logged: the tokens stay queued in
+// memory, they just won't be reloaded. l.mu must be held.
+func (l *tokenLog) writeLocked(record tokenRecord) {
+	if l.file == nil {
+		return
+	}
+	data, err := json.Marshal(record)
+	if err == nil {
+		_, err = l.file.Write(append(data, '\n'))
+	}
+	if err != nil {
+		WithFields(l.logger, "path", l.path, "error", err).Warn("Failed to write to the token file")
+		return
+	}
+	l.records++
+}
+
+// compact rewrites the file with a record for every queued token only.
+func (l *tokenLog) compact() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.compactLocked()
+}
+
+// compactLocked implements compact, replacing the file atomically so a crash never
+// leaves it half written. l.mu must be held.
+func (l *tokenLog) compactLocked() {
+	if l.file == nil {
+		return
+	}
+	if err := l.rewriteLocked(); err != nil {
+		WithFields(l.logger, "path", l.path, "error", err).Warn("Failed to compact the token file")
+	}
+}
+
+// rewriteLocked implements compactLocked. l.mu must be held.
+func (l *tokenLog) rewriteLocked() error {
+	tokens := make([]*storedToken, 0, len(l.live))
+	for _, st := range l.live {
+		tokens = append(tokens, st)
+	}
+	slices.SortFunc(tokens, func(a, b *storedToken) int { return cmp.Compare(a.Seq, b.Seq) })
+
+	tmp := l.path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(file)
+	enc := json.NewEncoder(w)
+	for _, st := range tokens {
+		if err = enc.Encode(tokenRecord{Add: st}); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, l.path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	// Append to the new file from now on
+	newFile, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	l.file.Close()
+	l.file = newFile
+	l.records = len(tokens)
+	return nil
+}
+
+// close compacts and closes the file. Changes made afterwards aren't recorded.
+func (l *tokenLog) close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	l.compactLocked()
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// persistentQueue is a token queue recording its changes to a tokenLog, so the tokens it
+// holds are reloaded by the next instance.
+type persistentQueue struct {
+	*queue.SliceQueue[*CaptchaAnswer]
+	log *tokenLog
+}
+
+func (q *persistentQueue) Enqueue(token *CaptchaAnswer) error {
+	if err := q.SliceQueue.Enqueue(token); err != nil {
+		return err
+	}
+	q.log.add(token)
+	return nil
+}
+
+func (q *persistentQueue) Dequeue() (*CaptchaAnswer, error) {
+	token, err := q.SliceQueue.Dequeue()
+	if err == nil {
+		q.log.remove(token)
+	}
+	return token, err
+}
+
+func (q *persistentQueue) RemoveFunc(remove func(*CaptchaAnswer) bool) int {
+	return q.SliceQueue.RemoveFunc(func(token *CaptchaAnswer) bool {
+		if !remove(token) {
+			return false
+		}
+		q.log.remove(token)
+		return true
+	})
+}
+
+func (q *persistentQueue) Clear() {
+	q.RemoveFunc(func(*CaptchaAnswer) bool { return true })
+}
+
+// SetCapacity implements resizableQueue, removing the oldest tokens that no longer fit
+// one by one so their removal is recorded.
+func (q *persistentQueue) SetCapacity(maxCapacity int) int {
+	removed := 0
+	for maxCapacity > 0 && q.Len() > maxCapacity {
+		if _, err := q.Dequeue(); err != nil {
+			break
+		}
+		removed++
+	}
+	q.SliceQueue.SetCapacity(maxCapacity)
+	return removed
+}
+
+// newQueue returns an empty queue bounded to the max capacity, recorded to the token file
+// if there is one.
+func (c *captchasolve) newQueue() tokenQueue {
+	q := queue.NewSliceQueue[*CaptchaAnswer](c.maxCapacity)
+	if c.tokenLog == nil {
+		return q
+	}
+	return &persistentQueue{SliceQueue: q, log: c.tokenLog}
+}
+
+// openTokenFile queues the tokens kept in the token file that haven't expired, and
+// records the changes made to the queues to it from now on. When more tokens were kept
+// than the pool can hold, the newest ones are queued.
+func (c *captchasolve) openTokenFile() error {
+	log, tokens, err := openTokenLog(c.tokenFile, c.logger)
+	if err != nil {
+		return err
+	}
+	log.harvesterIndex = c.harvesterIndexLocked
+	c.tokenLog = log
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.queue = c.newQueue()
+	skip := 0 // Oldest tokens that don't fit
+	if c.maxCapacity > 0 {
+		skip = max(len(tokens)-c.maxCapacity, 0)
+	}
+	restored := 0
+	for i, st := range tokens {
+		token := c.restoreTokenLocked(st)
+		log.bind(token, st.Seq)
+		if i < skip {
+			log.remove(token)
+			continue
+		}
+		if err := c.enqueueLocked(token); err != nil {
+			log.remove(token)
+			continue
+		}
+		restored++
+	}
+	log.compact()
+	if restored > 0 {
+		WithFields(c.logger, "count", restored, "path", c.tokenFile).Info("Reloaded queued tokens")
+	}
+	c.reportPoolLocked()
+	return nil
+}
+
+// restoreTokenLocked returns the token kept in the token file as st. It is attributed to
+// the harvester that solved it if that harvester is still configured at the same index,
+// and to its type of harvester otherwise. Its Id is lost. c.mu must be held.
+func (c *captchasolve) restoreTokenLocked(st storedToken) *CaptchaAnswer {
+	token := &CaptchaAnswer{
+		CaptchaAnswer: captchatoolsgo.CaptchaAnswer{Token: st.Token, UserAgent: st.UserAgent},
+		solvedAt:      st.SolvedAt,
+		expiresAt:     st.ExpiresAt,
+		fingerprint:   st.Fingerprint,
+	}
+	if st.Harvester >= 0 && st.Harvester < len(c.harvesters) && providerName(c.harvesters[st.Harvester]) == st.Provider {
+		token.harvester = c.harvesters[st.Harvester]
+	} else {
+		token.provider = st.Provider
+	}
+	return token
+}
+
+// closeTokenFile compacts and closes the token file, if there is one.
+func (c *captchasolve) closeTokenFile() error {
+	if c.tokenLog == nil {
+		return nil
+	}
+	if err := c.tokenLog.close(); err != nil {
+		return fmt.Errorf("captchasolve: closing token file: %w", err)
+	}
+	return nil
+}
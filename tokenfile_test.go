@@ -0,0 +1,268 @@
+package captchasolve
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	captchatoolsgo "github.com/Matthew17-21/Captcha-Tools/captchatools-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// queueTokens queues tokens in solver as if they had been harvested by h.
+func queueTokens(t *testing.T, solver *captchasolve, h captchatoolsgo.Harvester, tokens ...*CaptchaAnswer) {
+	t.Helper()
+	solver.mu.Lock()
+	defer solver.mu.Unlock()
+	for _, token := range tokens {
+		token.harvester = h
+		require.NoError(t, solver.enqueueLocked(token))
+	}
+}
+
+// newToken returns a token solved now, with the given fingerprint.
+func newToken(token, fingerprint string) *CaptchaAnswer {
+	return &CaptchaAnswer{
+		CaptchaAnswer: captchatoolsgo.CaptchaAnswer{Token: token, UserAgent: "agent"},
+		solvedAt:      time.Now(),
+		fingerprint:   fingerprint,
+	}
+}
+
+// writeTokenFile writes records to a token file and returns its path.
+func writeTokenFile(t *testing.T, records ...tokenRecord) string {
+	t.Helper()
+	var lines []string
+	for _, record := range records {
+		line, err := json.Marshal(record)
+		require.NoError(t, err)
+		lines = append(lines, string(line))
+	}
+	path := filepath.Join(t.TempDir(), "tokens.jsonl")
+	require.NoError(t, os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o600))
+	return path
+}
+
+// readTokenFile returns the records of the token file at path.
+func readTokenFile(t *testing.T, path string) []tokenRecord {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var records []tokenRecord
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		if line == "" {
+			continue
+		}
+		var record tokenRecord
+		require.NoError(t, json.Unmarshal([]byte(line), &record))
+		records = append(records, record)
+	}
+	return records
+}
+
+// storedAt returns a token kept in a token file, numbered seq, that expires at expiresAt.
+func storedAt(seq uint64, token string, expiresAt time.Time) tokenRecord {
+	return tokenRecord{Add: &storedToken{
+		Token:     token,
+		SolvedAt:  expiresAt.Add(-captchaTokenValidity),
+		ExpiresAt: expiresAt,
+		Provider:  "*captchasolve.fakeHarvester",
+		Harvester: 0,
+		Seq:       seq,
+	}}
+}
+
+func TestTokenFile(t *testing.T) {
+	t.Run("reloads the queued tokens", func(t *testing.T) {
+		// Arrange
+		path := filepath.Join(t.TempDir(), "tokens.jsonl")
+		h := &fakeHarvester{}
+		opts := []ClientOption{WithHarvester(h), WithTokenFile(path)}
+		solver := New(opts...).(*captchasolve)
+		queueTokens(t, solver, h, newToken("first", ""), newToken("second", ""), newToken("keyed", "fingerprint"))
+		_, err := solver.GetToken(context.Background())
+		require.NoError(t, err)
+		require.NoError(t, solver.Close())
+
+		// Act
+		reloaded := New(opts...).(*captchasolve)
+		defer reloaded.Close()
+
+		// Assert
+		require.Equal(t, PoolStatus{Queued: 2}, reloaded.PoolStatus())
+		token, err := reloaded.GetToken(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "second", token.Token)
+		assert.Equal(t, "agent", token.UserAgent)
+		assert.Equal(t, "*captchasolve.fakeHarvester", token.Provider())
+		assert.False(t, token.IsExpired())
+		assert.Zero(t, h.calls.Load(), "the token should come from the file")
+		require.NoError(t, reloaded.ReportBadToken(token), "the token should be tied to its harvester")
+		assert.Equal(t, 1, reloaded.Stats()[0].Failures[ErrorClassRejected])
+
+		reloaded.mu.Lock()
+		keyed := reloaded.keyed["fingerprint"]
+		reloaded.mu.Unlock()
+		require.NotNil(t, keyed)
+		assert.Equal(t, 1, keyed.Len())
+	})
+
+	t.Run("drops expired tokens and compacts the file", func(t *testing.T) {
+		// Arrange
+		path := writeTokenFile(t,
+			storedAt(1, "expired", time.Now().Add(-time.Second)),
+			storedAt(2, "used", time.Now().Add(time.Minute)),
+			storedAt(3, "valid", time.Now().Add(time.Minute)),
+			tokenRecord{Remove: 2},
+		)
+
+		// Act
+		solver := New(WithHarvester(&fakeHarvester{}), WithTokenFile(path)).(*captchasolve)
+		defer solver.Close()
+
+		// Assert
+		require.Equal(t, 1, solver.queue.Len())
+		records := readTokenFile(t, path)
+		require.Len(t, records, 1)
+		assert.Equal(t, "valid", records[0].Add.Token)
+		assert.EqualValues(t, 3, records[0].Add.Seq)
+	})
+
+	t.Run("keeps the newest tokens that fit", func(t *testing.T) {
+		// Arrange
+		expiresAt := time.Now().Add(time.Minute)
+		path := writeTokenFile(t, storedAt(1, "oldest", expiresAt), storedAt(2, "older", expiresAt), storedAt(3, "newest", expiresAt))
+
+		// Act
+		solver := New(WithHarvester(&fakeHarvester{}), WithTokenFile(path), WithMaxCapacity(2)).(*captchasolve)
+		defer solver.Close()
+
+		// Assert
+		assert.Len(t, readTokenFile(t, path), 2)
+		token, err := solver.GetToken(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "older", token.Token)
+	})
+
+	t.Run("attributes tokens of replaced harvesters to their provider", func(t *testing.T) {
+		// Arrange
+		path := writeTokenFile(t, storedAt(1, "token", time.Now().Add(time.Minute)))
+
+		// Act
+		solver := New(WithHarvester(&mockHarvester{}), WithTokenFile(path))
+		defer solver.Close()
+
+		// Assert
+		token, err := solver.GetToken(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "*captchasolve.fakeHarvester", token.Provider())
+		require.ErrorIs(t, solver.ReportBadToken(token), ErrUnknownToken, "the token wasn't solved by the harvester")
+	})
+
+	t.Run("ignores a corrupt end", func(t *testing.T) {
+		// Arrange
+		path := writeTokenFile(t, storedAt(1, "token", time.Now().Add(time.Minute)))
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o600)
+		require.NoError(t, err)
+		_, err = file.WriteString(`{"add": {"token": "cut sh`)
+		require.NoError(t, err)
+		require.NoError(t, file.Close())
+
+		// Act
+		solver := New(WithHarvester(&fakeHarvester{}), WithTokenFile(path)).(*captchasolve)
+		defer solver.Close()
+
+		// Assert
+		require.Equal(t, 1, solver.queue.Len())
+		require.Len(t, readTokenFile(t, path), 1, "the corrupt record should be compacted away")
+	})
+
+	t.Run("is kept on shutdown by default", func(t *testing.T) {
+		// Arrange
+		path := filepath.Join(t.TempDir(), "tokens.jsonl")
+		h := &fakeHarvester{}
+		solver := New(WithHarvester(h), WithTokenFile(path)).(*captchasolve)
+		queueTokens(t, solver, h, newToken("token", ""))
+
+		// Act
+		require.NoError(t, solver.Shutdown(context.Background()))
+
+		// Assert
+		require.Len(t, readTokenFile(t, path), 1)
+	})
+
+	t.Run("is cleared when discarding on shutdown", func(t *testing.T) {
+		// Arrange
+		path := filepath.Join(t.TempDir(), "tokens.jsonl")
+		h := &fakeHarvester{}
+		solver := New(WithShutdownPolicy(ShutdownDiscard), WithHarvester(h), WithTokenFile(path)).(*captchasolve)
+		queueTokens(t, solver, h, newToken("token", ""))
+
+		// Act
+		require.NoError(t, solver.Close())
+
+		// Assert
+		require.Empty(t, readTokenFile(t, path))
+	})
+
+	t.Run("records the tokens removed by Update", func(t *testing.T) {
+		// Arrange
+		path := filepath.Join(t.TempDir(), "tokens.jsonl")
+		h := &fakeHarvester{}
+		solver := New(WithHarvester(h), WithTokenFile(path), WithShutdownPolicy(ShutdownDrain)).(*captchasolve)
+		queueTokens(t, solver, h, newToken("oldest", ""), newToken("older", ""), newToken("newest", ""))
+
+		// Act
+		require.NoError(t, solver.Update(WithHarvester(h), WithMaxCapacity(1)))
+		require.NoError(t, solver.Close())
+
+		// Assert
+		records := readTokenFile(t, path)
+		require.Len(t, records, 1)
+		assert.Equal(t, "newest", records[0].Add.Token)
+	})
+
+	t.Run("reports a file that can't be opened", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "missing", "tokens.jsonl")
+
+		_, err := NewE(WithHarvester(&fakeHarvester{}), WithTokenFile(path))
+		require.ErrorContains(t, err, "opening token file")
+
+		solver := New(WithHarvester(&fakeHarvester{}), WithTokenFile(path))
+		defer solver.Close()
+		_, err = solver.GetToken(context.Background())
+		require.NoError(t, err, "New should keep the tokens in memory")
+	})
+}
+
+func TestTokenLog_Compaction(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "tokens.jsonl")
+	log, tokens, err := openTokenLog(path, NewSilentLogger())
+	require.NoError(t, err)
+	require.Empty(t, tokens)
+	defer log.close()
+	kept := newToken("kept", "")
+	log.add(kept)
+
+	// Act
+	for i := 0; i < compactAfter; i++ {
+		token := newToken("token", "")
+		log.add(token)
+		log.remove(token)
+	}
+
+	// Assert
+	records := readTokenFile(t, path)
+	require.Less(t, len(records), compactAfter, "the file should have been compacted")
+	assert.Equal(t, "kept", records[0].Add.Token)
+	_, tokens, err = openTokenLog(path, NewSilentLogger())
+	require.NoError(t, err)
+	require.Len(t, tokens, 1)
+	assert.Equal(t, "kept", tokens[0].Token)
+}
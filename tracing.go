@@ -0,0 +1,54 @@
+package captchasolve
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// tracerName is the name of the OpenTelemetry tracer the solver creates its spans with.
+const tracerName = "github.com/Matthew17-21/CaptchaSolve"
+
+// Attributes set on the spans.
+const (
+	attrFromQueue   = attribute.Key("captchasolve.from_queue")
+	attrSolves      = attribute.Key("captchasolve.solves")
+	attrHarvester   = attribute.Key("captchasolve.harvester.index")
+	attrProvider    = attribute.Key("captchasolve.harvester.provider")
+	attrAttempt     = attribute.Key("captchasolve.attempt")
+	attrTokenID     = attribute.Key("captchasolve.token.id")
+	attrTokenAge    = attribute.Key("captchasolve.token.age") // Seconds since the token was solved
+	attrHandedOff   = attribute.Key("captchasolve.handed_off")
+	attrCaptchaType = attribute.Key("captchasolve.captcha_type")
+)
+
+// startSpan starts a span as a child of the span in ctx, tagged with the captcha type if
+// it was set with WithCaptchaType. The span doesn't record anything unless a tracer
+// provider was set with WithTracerProvider.
+func (c *captchasolve) startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	if c.tracer == nil {
+		return ctx, noop.Span{}
+	}
+	if c.captchaType != "" {
+		attrs = append(attrs, attrCaptchaType.String(c.captchaType))
+	}
+	return c.tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// tokenAge returns the attribute recording how long ago token was solved.
+func tokenAge(token *CaptchaAnswer) attribute.KeyValue {
+	return attrTokenAge.Float64(time.Since(token.solvedAt).Seconds())
+}
+
+// endSpan ends span, recording err as its status if it isn't nil.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
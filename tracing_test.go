@@ -0,0 +1,134 @@
+package captchasolve
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// newRecordingTracerProvider returns a tracer provider recording every ended span.
+func newRecordingTracerProvider() (*sdktrace.TracerProvider, *tracetest.SpanRecorder) {
+	recorder := tracetest.NewSpanRecorder()
+	return sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)), recorder
+}
+
+// spanNamed returns the first ended span with the given name.
+func spanNamed(t *testing.T, recorder *tracetest.SpanRecorder, name string) sdktrace.ReadOnlySpan {
+	t.Helper()
+	for _, span := range recorder.Ended() {
+		if span.Name() == name {
+			return span
+		}
+	}
+	require.Failf(t, "span not found", "no %q span was ended", name)
+	return nil
+}
+
+// spanAttr returns the value of an attribute of span.
+func spanAttr(span sdktrace.ReadOnlySpan, key attribute.Key) attribute.Value {
+	for _, attr := range span.Attributes() {
+		if attr.Key == key {
+			return attr.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestTracing(t *testing.T) {
+	t.Run("traces a solve", func(t *testing.T) {
+		// Arrange
+		tp, recorder := newRecordingTracerProvider()
+		c := New(WithHarvester(&fakeHarvester{}), WithTracerProvider(tp), WithCaptchaType("v2")).(*captchasolve)
+
+		// Act
+		_, err := c.GetToken(context.Background())
+		require.NoError(t, err)
+		require.NoError(t, c.Close()) // Waits for the round to end
+
+		// Assert
+		getToken := spanNamed(t, recorder, "captchasolve.GetToken")
+		round := spanNamed(t, recorder, "captchasolve.startHarvesters")
+		attempt := spanNamed(t, recorder, "captchasolve.harvestToken")
+		handoff := spanNamed(t, recorder, "captchasolve.handoff")
+
+		assert.False(t, spanAttr(getToken, attrFromQueue).AsBool())
+		assert.Equal(t, getToken.SpanContext().SpanID(), round.Parent().SpanID())
+		assert.Equal(t, round.SpanContext().SpanID(), attempt.Parent().SpanID())
+		assert.Equal(t, round.SpanContext().SpanID(), handoff.Parent().SpanID())
+		assert.Equal(t, int64(1), spanAttr(round, attrSolves).AsInt64())
+		assert.Equal(t, int64(0), spanAttr(attempt, attrHarvester).AsInt64())
+		assert.Equal(t, int64(1), spanAttr(attempt, attrAttempt).AsInt64())
+		assert.True(t, spanAttr(handoff, attrHandedOff).AsBool())
+		assert.GreaterOrEqual(t, spanAttr(handoff, attrTokenAge).AsFloat64(), 0.0)
+		assert.GreaterOrEqual(t, spanAttr(getToken, attrTokenAge).AsFloat64(), 0.0)
+		for _, span := range []sdktrace.ReadOnlySpan{getToken, round, attempt, handoff} {
+			assert.Equal(t, "v2", spanAttr(span, attrCaptchaType).AsString(), span.Name())
+		}
+	})
+
+	t.Run("traces tokens served from the queue", func(t *testing.T) {
+		// Arrange
+		tp, recorder := newRecordingTracerProvider()
+		c := New(WithTracerProvider(tp)).(*captchasolve)
+		c.queue.Enqueue(&CaptchaAnswer{solvedAt: time.Now().Add(-30 * time.Second)})
+
+		// Act
+		_, err := c.GetToken(context.Background())
+
+		// Assert
+		require.NoError(t, err)
+		require.Len(t, recorder.Ended(), 1)
+		span := recorder.Ended()[0]
+		assert.True(t, spanAttr(span, attrFromQueue).AsBool())
+		assert.InDelta(t, 30, spanAttr(span, attrTokenAge).AsFloat64(), 1)
+		assert.Equal(t, attribute.Value{}, spanAttr(span, attrCaptchaType), "no captcha type was set")
+	})
+
+	t.Run("records errors and retries", func(t *testing.T) {
+		// Arrange
+		tp, recorder := newRecordingTracerProvider()
+		providerErr := errors.New("ERROR_CAPTCHA_UNSOLVABLE")
+		c := New(
+			WithHarvester(&fakeHarvester{err: providerErr}),
+			WithRetryPolicy(ExponentialBackoff{MaxAttempts: 2, BaseDelay: time.Millisecond}),
+			WithTracerProvider(tp),
+		).(*captchasolve)
+
+		// Act
+		_, err := c.GetToken(context.Background())
+		require.Error(t, err)
+		require.NoError(t, c.Close())
+
+		// Assert
+		var attempts []int64
+		for _, span := range recorder.Ended() {
+			if span.Name() == "captchasolve.harvestToken" {
+				attempts = append(attempts, spanAttr(span, attrAttempt).AsInt64())
+				assert.Equal(t, codes.Error, span.Status().Code)
+			}
+		}
+		assert.Equal(t, []int64{1, 2}, attempts)
+		assert.Equal(t, codes.Error, spanNamed(t, recorder, "captchasolve.GetToken").Status().Code)
+		assert.Equal(t, codes.Error, spanNamed(t, recorder, "captchasolve.startHarvesters").Status().Code)
+	})
+
+	t.Run("doesn't trace without a tracer provider", func(t *testing.T) {
+		// Arrange
+		c := New(WithHarvester(&fakeHarvester{})).(*captchasolve)
+		defer c.Close()
+
+		// Act
+		_, span := c.startSpan(context.Background(), "test")
+
+		// Assert
+		assert.False(t, span.IsRecording())
+	})
+}
@@ -0,0 +1,127 @@
+package captchasolve
+
+import (
+	"reflect"
+
+	captchatoolsgo "github.com/Matthew17-21/Captcha-Tools/captchatools-go"
+)
+
+// resizableQueue is implemented by the token queues whose capacity can be changed by
+// Update. SetCapacity removes the oldest tokens that no longer fit, returning how many.
+type resizableQueue interface {
+	SetCapacity(maxCapacity int) int
+}
+
+// Update replaces the configuration of the instance with the one described by the
+// options, as NewE would create it, without dropping the pool: queued tokens are kept and
+// in-flight solves finish with the harvester they started with. Their tokens are handed
+// off as usual. An error describing every problem with the configuration is returned, and
+// nothing is changed, if the options aren't valid.
+//
+// The harvesters, their settings and the Strategy, the pool limits, prefill targets,
+// validity windows, and the retry and hedge policies are swapped at once. If the max
+// capacity shrinks, the oldest queued tokens that no longer fit are discarded. Solves
+// already running or waiting for a slot count against the previous max goroutines.
+// Harvesters passed to both New and Update keep their health and statistics; those of
+// other harvesters start over. Once Start has been called, the pool is topped up to the
+// new prefill target right away; the surplus is solved on the next GetToken miss.
+//
+// The logger, metrics, tracer, captcha type, expiry sweeper, shutdown policy and token
+// file are set once by New and kept whatever the options say.
+//
+// Example, rotating an API key:
+//
+//	err := solver.Update(
+//	    WithHarvester(newCapmonster),
+//	    WithMaxCapacity(50),
+//	    WithPrefill(5, 10),
+//	)
+func (c *captchasolve) Update(opts ...ClientOption) error {
+	cfg := newConfig(opts...)
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return ErrPoolClosed
+	}
+
+	c.moveHealthLocked(cfg.harvesters)
+	if cfg.maxCapacity != c.maxCapacity {
+		c.resizeQueuesLocked(cfg.maxCapacity)
+	}
+	if cfg.maxGoroutines != c.maxGoroutines {
+		c.sem = nil // Created with the new limit by the next solve
+	}
+
+	// Only swap the settings that are read with c.mu held
+	c.harvesters, c.harvesterSettings, c.strategy = cfg.harvesters, cfg.harvesterSettings, cfg.strategy
+	c.retry, c.hedge = cfg.retry, cfg.hedge
+	c.maxCapacity, c.maxGoroutines, c.surplus, c.solveBudget = cfg.maxCapacity, cfg.maxGoroutines, cfg.surplus, cfg.solveBudget
+	c.prefillMin, c.prefillMax = cfg.prefillMin, cfg.prefillMax
+	c.tokenValidity, c.validityFunc, c.expiryMargin = cfg.tokenValidity, cfg.validityFunc, cfg.expiryMargin
+	c.quarantineRecheck, c.quarantineMaxRecheck = cfg.quarantineRecheck, cfg.quarantineMaxRecheck
+
+	c.logger.Info("Updated configuration")
+
+	// Top the pool up to the new targets once the workers are running, covering the
+	// waiters left without a solve. The surplus is left to the next GetToken miss.
+	c.startPrefillLocked()
+	c.signalRefill()
+	if c.workersCtx != nil {
+		c.startSolvesLocked(c.ctx, c.waitersFor("")-c.inFlightFor(""))
+	}
+	c.reportPoolLocked()
+	return nil
+}
+
+// moveHealthLocked moves the health records of the harvesters that are also in
+// harvesters to their new index, and drops the others. c.mu must be held.
+func (c *captchasolve) moveHealthLocked(harvesters []captchatoolsgo.Harvester) {
+	health := make(map[int]*harvesterHealth)
+	for i, h := range harvesters {
+		for j, old := range c.harvesters {
+			if record, ok := c.health[j]; ok && sameHarvester(h, old) {
+				health[i] = record
+				break
+			}
+		}
+	}
+	c.health = health
+}
+
+// resizeQueuesLocked changes the capacity of every queue, and discards the oldest tokens
+// until the pool fits in it. The discarded tokens are reported. c.mu must be held.
+func (c *captchasolve) resizeQueuesLocked(maxCapacity int) {
+	n := 0
+	c.forEachQueueLocked(func(q tokenQueue) {
+		if r, ok := q.(resizableQueue); ok {
+			n += r.SetCapacity(maxCapacity)
+		}
+	})
+	if maxCapacity > 0 {
+		n += c.discardOldestLocked(c.queuedLocked() - maxCapacity)
+	}
+	if n > 0 {
+		WithFields(c.logger, "count", n).Warn("Discarded the oldest tokens, which no longer fit in the queue")
+		c.reportDiscarded(n)
+	}
+}
+
+// replacedLocked reports whether Update replaced h, the harvester at index i when it was
+// picked, since. c.mu must be held.
+func (c *captchasolve) replacedLocked(i int, h captchatoolsgo.Harvester) bool {
+	return i >= len(c.harvesters) || !sameHarvester(c.harvesters[i], h)
+}
+
+// sameHarvester reports whether a and b are the same harvester. Harvesters of types that
+// can't be compared are never the same.
+func sameHarvester(a, b captchatoolsgo.Harvester) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	t := reflect.TypeOf(a)
+	return t == reflect.TypeOf(b) && t.Comparable() && a == b
+}
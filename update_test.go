@@ -0,0 +1,237 @@
+package captchasolve
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	captchatoolsgo "github.com/Matthew17-21/Captcha-Tools/captchatools-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdate(t *testing.T) {
+	t.Run("swaps harvesters keeping queued tokens", func(t *testing.T) {
+		// Arrange
+		oldHarvester, newHarvester := &fakeHarvester{}, &fakeHarvester{}
+		c := New(WithHarvester(oldHarvester)).(*captchasolve)
+		defer c.Close()
+		enqueueTokens(c, 1, 0)
+
+		// Act
+		err := c.Update(WithHarvester(newHarvester))
+
+		// Assert
+		require.NoError(t, err)
+		queued, err := c.GetToken(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "valid", queued.Token, "queued token should be kept")
+		_, err = c.GetToken(context.Background())
+		require.NoError(t, err)
+		assert.Zero(t, oldHarvester.calls.Load())
+		assert.EqualValues(t, 1, newHarvester.calls.Load())
+	})
+
+	t.Run("lets in-flight solves finish", func(t *testing.T) {
+		// Arrange
+		oldHarvester, newHarvester := &fakeHarvester{delay: 50 * time.Millisecond}, &fakeHarvester{delay: time.Hour}
+		c := New(WithHarvester(oldHarvester)).(*captchasolve)
+		defer c.Close()
+		tokens := make(chan *CaptchaAnswer, 1)
+		go func() {
+			token, err := c.GetToken(context.Background())
+			assert.NoError(t, err)
+			tokens <- token
+		}()
+		require.Eventually(t, func() bool { return oldHarvester.calls.Load() == 1 }, time.Second, time.Millisecond)
+
+		// Act
+		require.NoError(t, c.Update(WithHarvester(newHarvester)))
+
+		// Assert
+		require.Equal(t, "fake-token", (<-tokens).Token)
+		assert.Zero(t, newHarvester.calls.Load(), "the waiter was already covered")
+	})
+
+	t.Run("doesn't record failures of replaced harvesters", func(t *testing.T) {
+		// Arrange
+		oldHarvester := &fakeHarvester{delay: 50 * time.Millisecond, err: errors.New("ERROR_KEY_DOES_NOT_EXIST")}
+		c := New(WithHarvester(oldHarvester)).(*captchasolve)
+		defer c.Close()
+		errs := make(chan error, 1)
+		go func() {
+			_, err := c.GetToken(context.Background())
+			errs <- err
+		}()
+		require.Eventually(t, func() bool { return oldHarvester.calls.Load() == 1 }, time.Second, time.Millisecond)
+
+		// Act
+		require.NoError(t, c.Update(WithHarvester(&fakeHarvester{delay: time.Hour})))
+
+		// Assert
+		require.ErrorIs(t, <-errs, ErrAllHarvestersFailed)
+		status := c.HarvesterStatus()[0]
+		assert.Equal(t, HarvesterActive, status.State, "the new harvester must not be quarantined")
+		assert.NoError(t, status.LastError)
+	})
+
+	t.Run("keeps the health of kept harvesters", func(t *testing.T) {
+		// Arrange
+		kept := &fakeHarvester{}
+		c := New(WithHarvester(&fakeHarvester{}), WithHarvester(kept)).(*captchasolve)
+		c.recordCall(0, c.harvesters[0], 0, errors.New("502 bad gateway"))
+		c.recordCall(1, c.harvesters[1], 0, errors.New("ERROR_ZERO_BALANCE"))
+
+		// Act
+		require.NoError(t, c.Update(WithHarvester(kept), WithHarvester(&fakeHarvester{})))
+
+		// Assert
+		statuses := c.HarvesterStatus()
+		assert.Equal(t, HarvesterQuarantined, statuses[0].State)
+		assert.Equal(t, HarvesterActive, statuses[1].State)
+		assert.NoError(t, statuses[1].LastError)
+	})
+
+	t.Run("shrinking the capacity discards the oldest tokens", func(t *testing.T) {
+		// Arrange
+		metrics := &recordingMetrics{}
+		c := New(WithHarvester(&fakeHarvester{}), WithMetrics(metrics)).(*captchasolve)
+		for _, token := range []string{"a", "b", "c"} {
+			c.queue.Enqueue(&CaptchaAnswer{CaptchaAnswer: captchatoolsgo.CaptchaAnswer{Token: token}, solvedAt: time.Now()})
+		}
+
+		// Act
+		require.NoError(t, c.Update(WithHarvester(&fakeHarvester{}), WithMaxCapacity(2)))
+
+		// Assert
+		require.Equal(t, 2, c.queue.Len())
+		token, _ := c.queue.Dequeue()
+		assert.Equal(t, "b", token.Token)
+		assert.Equal(t, 1, metrics.discarded)
+	})
+
+	t.Run("shrinking the capacity bounds every fingerprint together", func(t *testing.T) {
+		// Arrange
+		c := New(WithHarvester(&fakeHarvester{})).(*captchasolve)
+		enqueueTokens(c, 1, 0)
+		enqueueKeyedToken(t, c, "token-a", &captchatoolsgo.AdditionalData{UserAgent: "ua-a"})
+		enqueueKeyedToken(t, c, "token-b", &captchatoolsgo.AdditionalData{UserAgent: "ua-b"})
+
+		// Act
+		require.NoError(t, c.Update(WithHarvester(&fakeHarvester{}), WithMaxCapacity(2)))
+
+		// Assert
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		assert.Equal(t, 2, c.queuedLocked())
+		assert.Zero(t, c.queue.Len(), "the oldest token should be discarded")
+	})
+
+	t.Run("applies the new max goroutines", func(t *testing.T) {
+		// Arrange
+		c := New(WithHarvester(&fakeHarvester{}), WithMaxGoroutines(1)).(*captchasolve)
+		release, err := c.acquire(context.Background())
+		require.NoError(t, err)
+		defer release()
+
+		// Act
+		require.NoError(t, c.Update(WithHarvester(&fakeHarvester{}), WithMaxGoroutines(2)))
+
+		// Assert
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		releaseNew, err := c.acquire(ctx)
+		require.NoError(t, err, "a slot should be free under the new limit")
+		releaseNew()
+	})
+
+	t.Run("starts the prefill worker once enabled", func(t *testing.T) {
+		// Arrange
+		h := &fakeHarvester{}
+		c := New(WithHarvester(h)).(*captchasolve)
+		defer c.Close()
+		require.NoError(t, c.Start(context.Background()))
+
+		// Act
+		require.NoError(t, c.Update(WithHarvester(h), WithPrefill(2, 2)))
+
+		// Assert
+		require.Eventually(t, func() bool {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			return c.queue.Len() == 2
+		}, time.Second, time.Millisecond)
+	})
+
+	t.Run("doesn't solve before being started", func(t *testing.T) {
+		// Arrange
+		h := &fakeHarvester{}
+		c := New(WithHarvester(h)).(*captchasolve)
+
+		// Act
+		require.NoError(t, c.Update(WithHarvester(h), WithSurplus(3), WithPrefill(2, 4)))
+		require.NoError(t, c.Close()) // Waits for any solve started
+
+		// Assert
+		assert.Zero(t, h.calls.Load())
+	})
+
+	t.Run("keeps settings set by New", func(t *testing.T) {
+		// Arrange
+		logger := NewLoggerWithLevel(0)
+		c := New(WithHarvester(&fakeHarvester{}), WithLogger(logger), WithShutdownPolicy(ShutdownDrain)).(*captchasolve)
+
+		// Act
+		require.NoError(t, c.Update(WithHarvester(&fakeHarvester{}), WithLogger(NewSilentLogger())))
+
+		// Assert
+		assert.Same(t, logger, c.logger)
+		assert.Equal(t, ShutdownDrain, c.shutdownPolicy)
+	})
+
+	t.Run("rejects invalid options", func(t *testing.T) {
+		// Arrange
+		h := &fakeHarvester{}
+		c := New(WithHarvester(h), WithMaxCapacity(5)).(*captchasolve)
+
+		// Act
+		err := c.Update(WithMaxCapacity(10))
+
+		// Assert
+		require.ErrorIs(t, err, ErrNoHarvesters)
+		assert.Equal(t, 5, c.maxCapacity, "configuration should be unchanged")
+		assert.Equal(t, []captchatoolsgo.Harvester{h}, c.harvesters)
+	})
+
+	t.Run("fails once closed", func(t *testing.T) {
+		c := New(WithHarvester(&fakeHarvester{})).(*captchasolve)
+		require.NoError(t, c.Close())
+
+		require.ErrorIs(t, c.Update(WithHarvester(&fakeHarvester{})), ErrPoolClosed)
+	})
+}
+
+func TestSameHarvester(t *testing.T) {
+	h := &fakeHarvester{}
+
+	assert.True(t, sameHarvester(h, h))
+	assert.False(t, sameHarvester(h, &fakeHarvester{}))
+	assert.False(t, sameHarvester(h, nil))
+	assert.False(t, sameHarvester(uncomparableHarvester{}, uncomparableHarvester{}), "must not panic")
+}
+
+// uncomparableHarvester is a harvester whose values can't be compared.
+type uncomparableHarvester struct {
+	tags []string
+}
+
+func (uncomparableHarvester) GetToken(...*captchatoolsgo.AdditionalData) (*captchatoolsgo.CaptchaAnswer, error) {
+	return nil, nil
+}
+
+func (uncomparableHarvester) GetTokenWithContext(context.Context, ...*captchatoolsgo.AdditionalData) (*captchatoolsgo.CaptchaAnswer, error) {
+	return nil, nil
+}
+
+func (uncomparableHarvester) GetBalance() (float32, error) { return 0, nil }
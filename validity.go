@@ -0,0 +1,36 @@
+package captchasolve
+
+import (
+	"time"
+
+	captchatoolsgo "github.com/Matthew17-21/Captcha-Tools/captchatools-go"
+)
+
+// defaultExpiryMargin is how long a token must remain valid for to be handed to a caller.
+const defaultExpiryMargin = 5 * time.Second
+
+// ValidityFunc returns how long a token solved by the given harvester remains valid for.
+// It can be used to apply different validity windows per harvester or captcha type, or to
+// use the expiry reported by the provider. Returning 0 falls back to the default validity.
+type ValidityFunc func(h captchatoolsgo.Harvester, answer *captchatoolsgo.CaptchaAnswer) time.Duration
+
+// validity returns how long a token solved by h remains valid for.
+func (c *captchasolve) validity(h captchatoolsgo.Harvester, answer *captchatoolsgo.CaptchaAnswer) time.Duration {
+	c.mu.Lock()
+	validityFunc, tokenValidity := c.validityFunc, c.tokenValidity
+	c.mu.Unlock()
+	if validityFunc != nil {
+		if d := validityFunc(h, answer); d > 0 {
+			return d
+		}
+	}
+	if tokenValidity > 0 {
+		return tokenValidity
+	}
+	return captchaTokenValidity
+}
+
+// usable reports whether a token has enough time left to be handed to a caller.
+func (c *captchasolve) usable(tkn *CaptchaAnswer) bool {
+	return tkn.TimeLeft() > c.expiryMargin
+}
@@ -0,0 +1,55 @@
+package captchasolve
+
+import (
+	"testing"
+	"time"
+
+	captchatoolsgo "github.com/Matthew17-21/Captcha-Tools/captchatools-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidity(t *testing.T) {
+	turnstile := &fakeHarvester{}
+	recaptcha := &fakeHarvester{}
+	perHarvester := func(h captchatoolsgo.Harvester, _ *captchatoolsgo.CaptchaAnswer) time.Duration {
+		if h == turnstile {
+			return TurnstileValidity
+		}
+		return 0
+	}
+
+	tests := []struct {
+		name      string
+		opts      []ClientOption
+		harvester captchatoolsgo.Harvester
+		expected  time.Duration
+	}{
+		{name: "default", harvester: recaptcha, expected: captchaTokenValidity},
+		{name: "global validity", opts: []ClientOption{WithTokenValidity(time.Minute)}, harvester: recaptcha, expected: time.Minute},
+		{name: "validity func", opts: []ClientOption{WithValidityFunc(perHarvester)}, harvester: turnstile, expected: TurnstileValidity},
+		{name: "validity func falls back", opts: []ClientOption{WithTokenValidity(time.Minute), WithValidityFunc(perHarvester)}, harvester: recaptcha, expected: time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			solver := New(tt.opts...).(*captchasolve)
+
+			// Act & Assert
+			require.Equal(t, tt.expected, solver.validity(tt.harvester, &captchatoolsgo.CaptchaAnswer{}))
+		})
+	}
+}
+
+func TestUsable(t *testing.T) {
+	// Arrange
+	solver := New(WithExpiryMargin(10 * time.Second)).(*captchasolve)
+	fresh := (&CaptchaAnswer{solvedAt: time.Now()}).withValidity(time.Minute)
+	almostExpired := (&CaptchaAnswer{solvedAt: time.Now()}).withValidity(3 * time.Second)
+	expired := (&CaptchaAnswer{solvedAt: time.Now().Add(-time.Hour)}).withValidity(time.Minute)
+
+	// Act & Assert
+	require.True(t, solver.usable(fresh))
+	require.False(t, solver.usable(almostExpired), "tokens within the margin shouldn't be handed out")
+	require.False(t, solver.usable(expired))
+}
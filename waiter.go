@@ -0,0 +1,102 @@
+package captchasolve
+
+import "slices"
+
+// waiter represents a GetToken caller that is blocked until a token is handed to it.
+//
+// The channel is buffered so a hand-off never blocks the goroutine delivering the
+// token; once a waiter has been removed from the waiters list it is guaranteed to
+// receive exactly one result, either a token or the error that ended the wait.
+type waiter struct {
+	ch          chan result
+	fingerprint string // Fingerprint of the tokens the caller can use
+}
+
+func newWaiter(fingerprint string) *waiter {
+	return &waiter{ch: make(chan result, 1), fingerprint: fingerprint}
+}
+
+// addWaiter registers w as the newest waiter. c.mu must be held.
+func (c *captchasolve) addWaiter(w *waiter) {
+	c.waiters = append(c.waiters, w)
+}
+
+// removeWaiter removes w from the waiters list and reports whether it was still
+// pending. A false return means a token has already been handed to w. c.mu must be held.
+func (c *captchasolve) removeWaiter(w *waiter) bool {
+	for i, pending := range c.waiters {
+		if pending == w {
+			c.waiters = append(c.waiters[:i], c.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// cancelWaiter is called when a waiting caller gives up. If a token was handed to the
+// waiter in the meantime, it is passed on instead of being lost.
+func (c *captchasolve) cancelWaiter(w *waiter) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	defer c.reportPoolLocked()
+	if c.removeWaiter(w) {
+		return
+	}
+	res := <-w.ch
+	if res.token == nil {
+		return
+	}
+	if err := c.deliverLocked(res.token); err != nil {
+		WithFields(c.logger, "token_id", res.token.Id(), "error", err).Warn("Discarding token handed to a cancelled caller")
+	}
+}
+
+// deliver hands a freshly harvested token to the oldest caller waiting for a token with
+// its fingerprint, or adds it to the queue when nobody is waiting.
+func (c *captchasolve) deliver(token *CaptchaAnswer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	defer c.reportPoolLocked()
+	return c.deliverLocked(token)
+}
+
+// deliverLocked is deliver with c.mu already held.
+func (c *captchasolve) deliverLocked(token *CaptchaAnswer) error {
+	for i, w := range c.waiters {
+		if w.fingerprint == token.fingerprint {
+			c.waiters = slices.Delete(c.waiters, i, i+1)
+			w.ch <- result{token: token}
+			return nil
+		}
+	}
+	if err := c.enqueueLocked(token); err != nil {
+		c.reportDiscarded(1)
+		return err
+	}
+	return nil
+}
+
+// failUncoveredWaiterLocked ends the wait of the newest caller waiting for a token with
+// the given fingerprint with err if there are more such waiters than in-flight solves
+// left to cover them. Tokens go to the oldest waiters first, so the newest one is the
+// one left without a solve. c.mu must be held.
+func (c *captchasolve) failUncoveredWaiterLocked(fingerprint string, err error) {
+	if c.waitersFor(fingerprint) <= max(c.inFlightFor(fingerprint), 0) {
+		return
+	}
+	for i := len(c.waiters) - 1; i >= 0; i-- {
+		if w := c.waiters[i]; w.fingerprint == fingerprint {
+			c.waiters = slices.Delete(c.waiters, i, i+1)
+			w.ch <- result{err: err}
+			return
+		}
+	}
+}
+
+// failWaitersLocked ends the wait of every waiting caller with err. c.mu must be held.
+func (c *captchasolve) failWaitersLocked(err error) {
+	for _, w := range c.waiters {
+		w.ch <- result{err: err}
+	}
+	c.waiters = nil
+}